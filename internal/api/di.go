@@ -8,4 +8,6 @@ import (
 var GraphSet = wire.NewSet(
 	u_handler.NewBaseHandler,
 	NewEnhanceApiHandler,
+	NewChainAdminHandler,
+	NewStreamHandler,
 )
@@ -0,0 +1,208 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/spf13/viper"
+
+	"nimbus-enhance-api/internal/conf"
+	"nimbus-enhance-api/internal/infra"
+)
+
+const testNoderealApiKey = "nodereal-secret"
+
+// fakeNode is an evm node of chain id 56 serving json-rpc over http and websocket.
+// Its head only moves when a test calls SetHead, new heads are pushed to every newHeads subscription.
+type fakeNode struct {
+	sync.Mutex
+	server     *rpc.Server
+	http       *httptest.Server
+	ws         *httptest.Server
+	head       uint64
+	calls      map[string]int
+	subscribes int
+	heads      map[*rpc.Subscription]*rpc.Notifier
+}
+
+func newFakeNode(t *testing.T) *fakeNode {
+	n := &fakeNode{
+		server: rpc.NewServer(),
+		head:   1,
+		calls:  make(map[string]int),
+		heads:  make(map[*rpc.Subscription]*rpc.Notifier),
+	}
+	if err := n.server.RegisterName("eth", &fakeEthService{node: n}); err != nil {
+		t.Fatal(err)
+	}
+	n.http = httptest.NewServer(n.server)
+	n.ws = httptest.NewServer(n.server.WebsocketHandler([]string{"*"}))
+	t.Cleanup(func() {
+		n.server.Stop()
+		n.ws.Close()
+		n.http.Close()
+	})
+	return n
+}
+
+func (n *fakeNode) URL() string {
+	return n.http.URL
+}
+
+func (n *fakeNode) WsURL() string {
+	return "ws://" + strings.TrimPrefix(n.ws.URL, "http://")
+}
+
+// SetHead moves the head and pushes it to the newHeads subscriptions
+func (n *fakeNode) SetHead(head uint64) {
+	n.Lock()
+	defer n.Unlock()
+	n.head = head
+	for sub, notifier := range n.heads {
+		_ = notifier.Notify(sub.ID, fakeEvmBlock(head))
+	}
+}
+
+// Calls counts the calls of a method, eth_getBlockByNumber is counted by block tag as well, e.g. eth_getBlockByNumber:latest
+func (n *fakeNode) Calls(method string) int {
+	n.Lock()
+	defer n.Unlock()
+	return n.calls[method]
+}
+
+// Subscribes counts the newHeads subscriptions made, Subscriptions the ones still open
+func (n *fakeNode) Subscribes() int {
+	n.Lock()
+	defer n.Unlock()
+	return n.subscribes
+}
+
+func (n *fakeNode) Subscriptions() int {
+	n.Lock()
+	defer n.Unlock()
+	return len(n.heads)
+}
+
+func (n *fakeNode) call(method string) {
+	n.Lock()
+	defer n.Unlock()
+	n.calls[method]++
+}
+
+// fakeEthService is registered in namespace eth, methods are named as go-ethereum maps them to json-rpc
+type fakeEthService struct {
+	node *fakeNode
+}
+
+func (s *fakeEthService) ChainId() hexutil.Uint64 {
+	s.node.call("eth_chainId")
+	return 56
+}
+
+// GetBlockByNumber answers null for blocks above the head, as nodes do
+func (s *fakeEthService) GetBlockByNumber(tag string, full bool) (map[string]interface{}, error) {
+	s.node.call("eth_getBlockByNumber")
+	s.node.call("eth_getBlockByNumber:" + tag)
+
+	s.node.Lock()
+	head := s.node.head
+	s.node.Unlock()
+	if tag == "latest" {
+		return fakeEvmBlock(head), nil
+	}
+	number, err := hexutil.DecodeUint64(tag)
+	if err != nil {
+		return nil, err
+	}
+	if number > head {
+		return nil, nil
+	}
+	return fakeEvmBlock(number), nil
+}
+
+// NewHeads is called by eth_subscribe of newHeads
+func (s *fakeEthService) NewHeads(ctx context.Context) (*rpc.Subscription, error) {
+	notifier, ok := rpc.NotifierFromContext(ctx)
+	if !ok {
+		return nil, rpc.ErrNotificationsUnsupported
+	}
+	sub := notifier.CreateSubscription()
+
+	s.node.Lock()
+	s.node.subscribes++
+	s.node.heads[sub] = notifier
+	s.node.Unlock()
+	go func() {
+		<-sub.Err()
+		s.node.Lock()
+		delete(s.node.heads, sub)
+		s.node.Unlock()
+	}()
+	return sub, nil
+}
+
+// fakeEvmBlock is block n with hash 0x<n>, as the blocks of the service tests
+func fakeEvmBlock(number uint64) map[string]interface{} {
+	res := map[string]interface{}{
+		"number":       hexutil.EncodeUint64(number),
+		"hash":         fmt.Sprintf("0x%x", number),
+		"parentHash":   "0x0",
+		"timestamp":    hexutil.EncodeUint64(1_600_000_000 + 12*number),
+		"miner":        "0x0000000000000000000000000000000000000000",
+		"gasUsed":      "0x0",
+		"gasLimit":     "0x1c9c380",
+		"transactions": []interface{}{},
+	}
+	if number > 0 {
+		res["parentHash"] = fmt.Sprintf("0x%x", number-1)
+	}
+	return res
+}
+
+// useTestProviders loads the providers with bsc served by the node under api key testNoderealApiKey,
+// other built-in chains are dropped so the registry never calls public endpoints.
+// Config, providers and viper are restored with the test.
+func useTestProviders(t *testing.T, node *fakeNode) {
+	config, providers := conf.Config, conf.Providers
+	t.Cleanup(func() {
+		conf.Config, conf.Providers = config, providers
+		viper.Reset()
+	})
+
+	providerConfig := filepath.Join(t.TempDir(), "providers.yaml")
+	content := fmt.Sprintf("chains:\n  bsc:\n    provider: nodereal\n    url_template: %s/v1/{api_key}\n", node.URL())
+	if err := os.WriteFile(providerConfig, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	viper.Set("PROVIDER_CONFIG", providerConfig)
+	viper.Set("NODEREAL_API_KEY", testNoderealApiKey)
+	viper.Set("CHAINBASE_API_KEY", "chainbase-secret")
+	if err := conf.LoadConfig(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	conf.Config = config
+
+	for chain := range conf.Providers.Chains {
+		if chain != "bsc" {
+			delete(conf.Providers.Chains, chain)
+		}
+	}
+}
+
+// newTestClientPool is a client pool closed with the test
+func newTestClientPool(t *testing.T) *infra.RpcClientPool {
+	clientPool, cleanup, err := infra.NewRpcClientPool()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(cleanup)
+	return clientPool
+}
@@ -0,0 +1,122 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/utils/tests"
+
+	"nimbus-enhance-api/internal/entity"
+	"nimbus-enhance-api/internal/repo"
+	"nimbus-enhance-api/internal/repo/gorm_scope"
+	"nimbus-enhance-api/internal/service"
+)
+
+// fakeChainRepo stores chains in memory. Scopes are built by a dry run of gorm, GetOne answers the chain
+// named by the first string condition and GetList answers every chain.
+type fakeChainRepo struct {
+	sync.Mutex
+	chains  map[string]*entity.Chain
+	creates int
+	updates int
+}
+
+var _ repo.ChainRepo = (*fakeChainRepo)(nil)
+
+func newFakeChainRepo(chains ...*entity.Chain) *fakeChainRepo {
+	r := &fakeChainRepo{chains: make(map[string]*entity.Chain)}
+	for i, item := range chains {
+		item.Base = entity.Base{ID: fmt.Sprint(i + 1)}
+		r.chains[item.Name] = item
+	}
+	return r
+}
+
+func (r *fakeChainRepo) S() *gorm_scope.ChainScope {
+	return gorm_scope.NewChain(gorm_scope.NewBase())
+}
+
+func (r *fakeChainRepo) GetOne(ctx context.Context, scopes ...func(db *gorm.DB) *gorm.DB) (*entity.Chain, error) {
+	db, err := gorm.Open(tests.DummyDialector{}, &gorm.Config{DryRun: true})
+	if err != nil {
+		return nil, err
+	}
+	stmt := db.Table("chains").Scopes(scopes...).Find(&[]map[string]interface{}{}).Statement
+
+	r.Lock()
+	defer r.Unlock()
+	for _, v := range stmt.Vars {
+		if name, ok := v.(string); ok {
+			if item, ok := r.chains[name]; ok {
+				res := *item
+				return &res, nil
+			}
+			break
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *fakeChainRepo) GetList(ctx context.Context, scopes ...func(db *gorm.DB) *gorm.DB) ([]*entity.Chain, error) {
+	r.Lock()
+	defer r.Unlock()
+	res := make([]*entity.Chain, 0, len(r.chains))
+	for _, item := range r.chains {
+		row := *item
+		res = append(res, &row)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Name < res[j].Name
+	})
+	return res, nil
+}
+
+func (r *fakeChainRepo) Create(ctx context.Context, item *entity.Chain) error {
+	r.Lock()
+	defer r.Unlock()
+	r.creates++
+	item.Base = entity.Base{ID: fmt.Sprint(len(r.chains) + 1)}
+	row := *item
+	r.chains[item.Name] = &row
+	return nil
+}
+
+func (r *fakeChainRepo) Update(ctx context.Context, item *entity.Chain) error {
+	r.Lock()
+	defer r.Unlock()
+	r.updates++
+	row := *item
+	r.chains[item.Name] = &row
+	return nil
+}
+
+// Chain returns the stored row of a chain, nil when the chain has none
+func (r *fakeChainRepo) Chain(name string) *entity.Chain {
+	r.Lock()
+	defer r.Unlock()
+	item, ok := r.chains[name]
+	if !ok {
+		return nil
+	}
+	res := *item
+	return &res
+}
+
+func (r *fakeChainRepo) Writes() (creates int, updates int) {
+	r.Lock()
+	defer r.Unlock()
+	return r.creates, r.updates
+}
+
+// newTestChainRegistry is the registry of the built-in chains left by useTestProviders overridden by the stored chains
+func newTestChainRegistry(t *testing.T, chainRepo repo.ChainRepo) service.ChainRegistry {
+	chainRegistry, err := service.NewChainRegistry(context.Background(), chainRepo, service.NewUpstreamHealth(), newTestClientPool(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return chainRegistry
+}
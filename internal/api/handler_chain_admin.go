@@ -0,0 +1,209 @@
+package api
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/tikivn/ultrago/u_handler"
+	"github.com/tikivn/ultrago/u_logger"
+
+	"nimbus-enhance-api/internal/conf"
+	"nimbus-enhance-api/internal/entity"
+	"nimbus-enhance-api/internal/service"
+	"nimbus-enhance-api/internal/setting"
+)
+
+func NewChainAdminHandler(
+	baseHandler *u_handler.BaseHandler,
+	chainRegistry service.ChainRegistry,
+	abiDecoder service.AbiDecoder,
+) *ChainAdminHandler {
+	return &ChainAdminHandler{
+		BaseHandler:   baseHandler,
+		chainRegistry: chainRegistry,
+		abiDecoder:    abiDecoder,
+	}
+}
+
+type ChainAdminHandler struct {
+	*u_handler.BaseHandler
+	chainRegistry service.ChainRegistry
+	abiDecoder    service.AbiDecoder
+}
+
+func (h *ChainAdminHandler) Route() chi.Router {
+	mux := chi.NewRouter()
+	mux.Use(h.authenticate)
+	mux.Get("/chains", h.handlerGetChains)
+	mux.Post("/chains", h.handlerCreateChain)
+	mux.Put("/chains/{chain}", h.handlerUpdateChain)
+	mux.Delete("/chains/{chain}", h.handlerDisableChain)
+	mux.Get("/abis/{chain}/{address}", h.handlerGetContractAbi)
+	mux.Put("/abis/{chain}/{address}", h.handlerSaveContractAbi)
+	return mux
+}
+
+// authenticate only lets through requests carrying ADMIN_API_KEY in header X-API-KEY,
+// admin api is closed when the key is not configured.
+func (h *ChainAdminHandler) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		apiKey := r.Header.Get("X-API-KEY")
+		if conf.Config.AdminApiKey == "" ||
+			subtle.ConstantTimeCompare([]byte(apiKey), []byte(conf.Config.AdminApiKey)) != 1 {
+			h.Unauthorized(w, r, fmt.Errorf("invalid api key"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (h *ChainAdminHandler) handlerGetChains(w http.ResponseWriter, r *http.Request) {
+	ctx, logger := u_logger.GetLogger(r.Context())
+
+	res, err := h.chainRegistry.GetChains(ctx)
+	if err != nil {
+		logger.Errorf("failed to get chains: %v", err)
+		h.Internal(w, r, fmt.Errorf("failed to get chains: %v", conf.Redact(err.Error())))
+		return
+	}
+	for i, item := range res {
+		res[i] = redactChain(item)
+	}
+	h.Success(w, r, res)
+}
+
+func (h *ChainAdminHandler) handlerCreateChain(w http.ResponseWriter, r *http.Request) {
+	ctx, logger := u_logger.GetLogger(r.Context())
+
+	var req entity.Chain
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger.Errorf("invalid request body: %v", err)
+		h.BadRequest(w, r, fmt.Errorf("invalid request body: %v", err))
+		return
+	}
+	req.Base = entity.Base{}
+
+	if err := h.chainRegistry.CreateChain(ctx, &req); err != nil {
+		logger.Errorf("failed to create chain %s: %v", req.Name, err)
+		h.chainError(w, r, fmt.Errorf("failed to create chain %s: %w", req.Name, err))
+		return
+	}
+	h.Success(w, r, redactChain(&req))
+}
+
+// handlerUpdateChain merges the request body onto the current chain: fields missing from the body keep their value,
+// methods are merged by name and a given upstreams list replaces the current one.
+func (h *ChainAdminHandler) handlerUpdateChain(w http.ResponseWriter, r *http.Request) {
+	var (
+		ctx, logger = u_logger.GetLogger(r.Context())
+		chain       = chi.URLParam(r, "chain")
+	)
+
+	req, err := h.chainRegistry.GetChain(ctx, service.Chain(chain))
+	if err != nil {
+		logger.Errorf("failed to get chain %s: %v", chain, err)
+		h.chainError(w, r, fmt.Errorf("failed to get chain %s: %w", chain, err))
+		return
+	}
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		logger.Errorf("invalid request body: %v", err)
+		h.BadRequest(w, r, fmt.Errorf("invalid request body: %v", err))
+		return
+	}
+	req.Base = entity.Base{}
+	req.Name = chain
+
+	if err := h.chainRegistry.UpdateChain(ctx, req); err != nil {
+		logger.Errorf("failed to update chain %s: %v", chain, err)
+		h.chainError(w, r, fmt.Errorf("failed to update chain %s: %w", chain, err))
+		return
+	}
+	h.Success(w, r, redactChain(req))
+}
+
+func (h *ChainAdminHandler) handlerDisableChain(w http.ResponseWriter, r *http.Request) {
+	var (
+		ctx, logger = u_logger.GetLogger(r.Context())
+		chain       = chi.URLParam(r, "chain")
+	)
+
+	if err := h.chainRegistry.DisableChain(ctx, service.Chain(chain)); err != nil {
+		logger.Errorf("failed to disable chain %s: %v", chain, err)
+		h.chainError(w, r, fmt.Errorf("failed to disable chain %s: %w", chain, err))
+		return
+	}
+	h.Success(w, r, chain)
+}
+
+func (h *ChainAdminHandler) handlerGetContractAbi(w http.ResponseWriter, r *http.Request) {
+	var (
+		ctx, logger = u_logger.GetLogger(r.Context())
+		chain       = chi.URLParam(r, "chain")
+		address     = chi.URLParam(r, "address")
+	)
+
+	res, err := h.abiDecoder.GetContractAbi(ctx, service.Chain(chain), address)
+	if err != nil {
+		logger.Errorf("failed to get abi of contract %s of chain %s: %v", address, chain, err)
+		h.chainError(w, r, fmt.Errorf("failed to get abi of contract %s of chain %s: %w", address, chain, err))
+		return
+	}
+	h.Success(w, r, res)
+}
+
+// handlerSaveContractAbi takes the abi json array of the contract as request body
+func (h *ChainAdminHandler) handlerSaveContractAbi(w http.ResponseWriter, r *http.Request) {
+	var (
+		ctx, logger = u_logger.GetLogger(r.Context())
+		chain       = chi.URLParam(r, "chain")
+		address     = chi.URLParam(r, "address")
+	)
+
+	var req json.RawMessage
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger.Errorf("invalid request body: %v", err)
+		h.BadRequest(w, r, fmt.Errorf("invalid request body: %v", err))
+		return
+	}
+
+	item := &entity.ContractAbi{Chain: chain, Address: address, Abi: req}
+	if err := h.abiDecoder.SaveContractAbi(ctx, item); err != nil {
+		logger.Errorf("failed to save abi of contract %s of chain %s: %v", address, chain, err)
+		h.chainError(w, r, fmt.Errorf("failed to save abi of contract %s of chain %s: %w", address, chain, err))
+		return
+	}
+	h.Success(w, r, item)
+}
+
+// chainError answers the status of err with its message redacted, messages may quote endpoints
+func (h *ChainAdminHandler) chainError(w http.ResponseWriter, r *http.Request, err error) {
+	redacted := errors.New(conf.Redact(err.Error()))
+	switch {
+	case errors.Is(err, setting.ErrNotSupportedChain),
+		errors.Is(err, setting.ErrAbiNotFound):
+		h.NotFound(w, r, redacted)
+	case errors.Is(err, setting.ErrChainAlreadyExisted),
+		errors.Is(err, setting.ErrInvalidChain),
+		errors.Is(err, setting.ErrInvalidAbi):
+		h.BadRequest(w, r, redacted)
+	default:
+		h.Internal(w, r, redacted)
+	}
+}
+
+// redactChain returns a copy of the chain with api keys hidden from its endpoints, built-in chains keep placeholder
+// {api_key} but chains stored before may carry the key itself.
+func redactChain(item *entity.Chain) *entity.Chain {
+	res := *item
+	res.Endpoint = conf.Redact(item.Endpoint)
+	res.Upstreams = nil
+	for _, upstream := range item.Upstreams {
+		upstream.Endpoint = conf.Redact(upstream.Endpoint)
+		res.Upstreams = append(res.Upstreams, upstream)
+	}
+	return &res
+}
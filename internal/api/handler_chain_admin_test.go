@@ -0,0 +1,177 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+	"github.com/tikivn/ultrago/u_handler"
+
+	"nimbus-enhance-api/internal/conf"
+	"nimbus-enhance-api/internal/entity"
+	"nimbus-enhance-api/internal/repo"
+	"nimbus-enhance-api/internal/service"
+)
+
+const testAdminApiKey = "admin-key"
+
+func TestChainAdminHandler_Chains(t *testing.T) {
+	convey.Convey("TestChainAdminHandler_Chains", t, func() {
+		node := newFakeNode(t)
+		useTestProviders(t, node)
+		conf.Config.AdminApiKey = testAdminApiKey
+
+		storedBsc := func(endpoint string) *entity.Chain {
+			return &entity.Chain{
+				Name:      "bsc",
+				Endpoint:  endpoint,
+				Upstreams: []entity.ChainUpstream{{Endpoint: endpoint, Weight: 2}},
+				Methods: map[string]string{
+					"chainId":          "eth_chainId",
+					"getBlockByNumber": "eth_getBlockByNumber",
+				},
+				IsEVM:          true,
+				ChainID:        56,
+				Enabled:        true,
+				DisplayName:    "BNB Smart Chain",
+				NativeCurrency: &entity.NativeCurrency{Name: "BNB", Symbol: "BNB", Decimals: 18},
+				Operations:     []string{service.OperationLatestBlock, service.OperationBlock},
+			}
+		}
+
+		convey.Convey("Partial update keeps the fields missing from the body", func() {
+			chainRepo := newFakeChainRepo(storedBsc(node.URL()))
+			server := newTestAdminServer(t, chainRepo)
+
+			status, body := doRequest(t, http.MethodPut, server.URL+"/chains/bsc", testAdminApiKey,
+				`{"display_name": "BSC", "methods": {"gasPrice": "eth_gasPrice"}}`)
+			convey.So(status, convey.ShouldEqual, http.StatusOK)
+			var res entity.Chain
+			decodeData(t, body, &res)
+			convey.So(res.DisplayName, convey.ShouldEqual, "BSC")
+
+			creates, updates := chainRepo.Writes()
+			convey.So(creates, convey.ShouldEqual, 0)
+			convey.So(updates, convey.ShouldEqual, 1)
+
+			row := chainRepo.Chain("bsc")
+			expected := storedBsc(node.URL())
+			expected.Base = entity.Base{ID: "1"}
+			expected.DisplayName = "BSC"
+			expected.Methods["gasPrice"] = "eth_gasPrice"
+			convey.So(row, convey.ShouldResemble, expected)
+		})
+
+		convey.Convey("Built-in chain gets its first row on update", func() {
+			chainRepo := newFakeChainRepo()
+			server := newTestAdminServer(t, chainRepo)
+
+			status, _ := doRequest(t, http.MethodPut, server.URL+"/chains/bsc", testAdminApiKey, `{"display_name": "BSC"}`)
+			convey.So(status, convey.ShouldEqual, http.StatusOK)
+			creates, updates := chainRepo.Writes()
+			convey.So(creates, convey.ShouldEqual, 1)
+			convey.So(updates, convey.ShouldEqual, 0)
+
+			// the endpoint keeps placeholder {api_key}, the key is never stored
+			row := chainRepo.Chain("bsc")
+			convey.So(row.ID, convey.ShouldNotBeEmpty)
+			convey.So(row.Endpoint, convey.ShouldEqual, node.URL()+"/v1/{api_key}")
+			convey.So(row.DisplayName, convey.ShouldEqual, "BSC")
+			convey.So(row.ChainID, convey.ShouldEqual, 56)
+			convey.So(row.Enabled, convey.ShouldBeTrue)
+			convey.So(row.Methods["getBlockByNumber"], convey.ShouldEqual, "eth_getBlockByNumber")
+
+			status, _ = doRequest(t, http.MethodPut, server.URL+"/chains/bsc", testAdminApiKey, `{"display_name": "BNB"}`)
+			convey.So(status, convey.ShouldEqual, http.StatusOK)
+			creates, updates = chainRepo.Writes()
+			convey.So(creates, convey.ShouldEqual, 1)
+			convey.So(updates, convey.ShouldEqual, 1)
+			convey.So(chainRepo.Chain("bsc").ID, convey.ShouldEqual, row.ID)
+		})
+
+		convey.Convey("Admin api is closed without ADMIN_API_KEY", func() {
+			server := newTestAdminServer(t, newFakeChainRepo())
+
+			status, _ := doRequest(t, http.MethodGet, server.URL+"/chains", "wrong-key", "")
+			convey.So(status, convey.ShouldEqual, http.StatusUnauthorized)
+
+			conf.Config.AdminApiKey = ""
+			status, _ = doRequest(t, http.MethodGet, server.URL+"/chains", "", "")
+			convey.So(status, convey.ShouldEqual, http.StatusUnauthorized)
+			status, _ = doRequest(t, http.MethodGet, server.URL+"/chains", testAdminApiKey, "")
+			convey.So(status, convey.ShouldEqual, http.StatusUnauthorized)
+		})
+
+		convey.Convey("Api keys of chains stored with the key are redacted in responses", func() {
+			chainRepo := newFakeChainRepo(storedBsc(node.URL() + "/v1/" + testNoderealApiKey))
+			server := newTestAdminServer(t, chainRepo)
+
+			status, body := doRequest(t, http.MethodGet, server.URL+"/chains", testAdminApiKey, "")
+			convey.So(status, convey.ShouldEqual, http.StatusOK)
+			convey.So(string(body), convey.ShouldNotContainSubstring, testNoderealApiKey)
+			var chains []*entity.Chain
+			decodeData(t, body, &chains)
+			convey.So(chains, convey.ShouldHaveLength, 1)
+			convey.So(chains[0].Endpoint, convey.ShouldEqual, node.URL()+"/v1/***")
+			convey.So(chains[0].Upstreams[0].Endpoint, convey.ShouldEqual, node.URL()+"/v1/***")
+
+			status, body = doRequest(t, http.MethodPut, server.URL+"/chains/bsc", testAdminApiKey, `{"display_name": "BSC"}`)
+			convey.So(status, convey.ShouldEqual, http.StatusOK)
+			convey.So(string(body), convey.ShouldNotContainSubstring, testNoderealApiKey)
+
+			// the stored endpoint itself is left untouched
+			convey.So(chainRepo.Chain("bsc").Endpoint, convey.ShouldEqual, node.URL()+"/v1/"+testNoderealApiKey)
+		})
+
+		convey.Convey("Unknown chain", func() {
+			server := newTestAdminServer(t, newFakeChainRepo())
+
+			status, _ := doRequest(t, http.MethodPut, server.URL+"/chains/unknown", testAdminApiKey, `{"display_name": "Unknown"}`)
+			convey.So(status, convey.ShouldEqual, http.StatusNotFound)
+		})
+	})
+}
+
+func newTestAdminServer(t *testing.T, chainRepo repo.ChainRepo) *httptest.Server {
+	handler := NewChainAdminHandler(u_handler.NewBaseHandler(), newTestChainRegistry(t, chainRepo), nil)
+	server := httptest.NewServer(handler.Route())
+	t.Cleanup(server.Close)
+	return server
+}
+
+// doRequest sends body as json with apiKey in header X-API-KEY, it returns the status and the response body
+func doRequest(t *testing.T, method string, url string, apiKey string, body string) (int, []byte) {
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-API-KEY", apiKey)
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res.StatusCode, data
+}
+
+// decodeData decodes the data of a success response into v
+func decodeData(t *testing.T, body []byte, v interface{}) {
+	var res struct {
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(body, &res); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(res.Data, v); err != nil {
+		t.Fatal(err)
+	}
+}
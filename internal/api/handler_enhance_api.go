@@ -1,36 +1,60 @@
 package api
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/ethereum/go-ethereum"
 	"github.com/go-chi/chi/v5"
 	"github.com/tikivn/ultrago/u_handler"
 	"github.com/tikivn/ultrago/u_logger"
 
 	"nimbus-enhance-api/internal/service"
+	"nimbus-enhance-api/internal/setting"
 )
 
 func NewEnhanceApiHandler(
 	baseHandler *u_handler.BaseHandler,
 	chainSvc service.ChainService,
+	upstreamMonitor service.UpstreamMonitor,
+	reorgTracker service.ReorgTracker,
+	chainStatsTracker service.ChainStatsTracker,
 ) *EnhanceApiHandler {
 	return &EnhanceApiHandler{
-		BaseHandler: baseHandler,
-		chainSvc:    chainSvc,
+		BaseHandler:       baseHandler,
+		chainSvc:          chainSvc,
+		upstreamMonitor:   upstreamMonitor,
+		reorgTracker:      reorgTracker,
+		chainStatsTracker: chainStatsTracker,
 	}
 }
 
 type EnhanceApiHandler struct {
 	*u_handler.BaseHandler
-	chainSvc service.ChainService
+	chainSvc          service.ChainService
+	upstreamMonitor   service.UpstreamMonitor
+	reorgTracker      service.ReorgTracker
+	chainStatsTracker service.ChainStatsTracker
 }
 
 func (h *EnhanceApiHandler) Route() chi.Router {
 	mux := chi.NewRouter()
 	mux.Get("/blocks/latest/{chain}", h.handlerGetLatestBlockByChain)
+	mux.Get("/blocks/{chain}", h.handlerGetBlockRange)
+	mux.Get("/blocks/{chain}/at", h.handlerGetBlockAtTimestamp)
+	mux.Get("/blocks/{chain}/{id}", h.handlerGetBlock)
+	mux.Get("/blocks/{chain}/{number}/raw", h.handlerGetBlockRawLogs)
+	mux.Get("/reorgs/{chain}", h.handlerGetReorgs)
+	mux.Get("/gas/{chain}", h.handlerGetGasEstimate)
 	mux.Get("/tx/total/{chain}", h.handlerCountTotalTxByChain)
 	mux.Get("/tx/{hash}", h.handlerSearchTxHash)
+	mux.Get("/chains", h.handlerGetChains)
+	mux.Get("/chains/health", h.handlerGetChainsHealth)
+	mux.Get("/chains/{chain}/stats", h.handlerGetChainStats)
 	return mux
 }
 
@@ -46,12 +70,314 @@ func (h *EnhanceApiHandler) handlerGetLatestBlockByChain(w http.ResponseWriter,
 		return
 	}
 
-	res, err := h.chainSvc.GetLatestBlock(ctx, service.Chain(chain))
+	if err := h.chainSvc.CheckOperation(service.Chain(chain), service.OperationLatestBlock); err != nil {
+		logger.Errorf("not supported latest block: %v", err)
+		h.BadRequest(w, r, fmt.Errorf("not supported latest block: %v", err))
+		return
+	}
+
+	res, err := h.chainSvc.GetLatestBlock(ctx, service.Chain(chain), h.RequestParamStr(r, "commitment"))
 	if err != nil {
 		logger.Errorf("failed to get latest block of chain %s: %v", chain, err)
+		if errors.Is(err, setting.ErrInvalidCommitment) || errors.Is(err, setting.ErrNotSupportedMethod) {
+			h.BadRequest(w, r, fmt.Errorf("failed to get latest block of chain %s: %v", chain, err))
+			return
+		}
 		h.Internal(w, r, fmt.Errorf("failed to get latest block of chain %s: %v", chain, err))
 		return
 	}
+	if !h.RequestParamBool(r, "raw", false) {
+		res.Raw = nil
+	}
+	h.Success(w, r, res)
+}
+
+func (h *EnhanceApiHandler) handlerGetBlock(w http.ResponseWriter, r *http.Request) {
+	var (
+		ctx, logger = u_logger.GetLogger(r.Context())
+		chain       = chi.URLParam(r, "chain")
+		includeTxs  = h.RequestParamBool(r, "include_txs", false)
+		raw         = h.RequestParamBool(r, "raw", false)
+	)
+
+	if chain == "" {
+		logger.Errorf("missing chain")
+		h.BadRequest(w, r, fmt.Errorf("missing chain"))
+		return
+	}
+
+	id, err := service.ParseBlockID(chi.URLParam(r, "id"))
+	if err != nil {
+		logger.Errorf("invalid block id: %v", err)
+		h.BadRequest(w, r, err)
+		return
+	}
+
+	if err := h.chainSvc.CheckOperation(service.Chain(chain), service.OperationBlock); err != nil {
+		logger.Errorf("not supported block: %v", err)
+		h.BadRequest(w, r, fmt.Errorf("not supported block: %v", err))
+		return
+	}
+
+	res, err := h.chainSvc.GetBlock(ctx, service.Chain(chain), id, includeTxs)
+	switch {
+	case errors.Is(err, ethereum.NotFound):
+		logger.Errorf("not found block %s of chain %s", id, chain)
+		h.NotFound(w, r, fmt.Errorf("not found block %s of chain %s", id, chain))
+		return
+	case errors.Is(err, setting.ErrNotSupportedMethod):
+		logger.Errorf("not supported block %s of chain %s: %v", id, chain, err)
+		h.BadRequest(w, r, fmt.Errorf("not supported block %s of chain %s: %v", id, chain, err))
+		return
+	case err != nil:
+		logger.Errorf("failed to get block %s of chain %s: %v", id, chain, err)
+		h.Internal(w, r, fmt.Errorf("failed to get block %s of chain %s: %v", id, chain, err))
+		return
+	}
+	if !raw {
+		res.Raw = nil
+	}
+	h.Success(w, r, res)
+}
+
+func (h *EnhanceApiHandler) handlerGetBlockRawLogs(w http.ResponseWriter, r *http.Request) {
+	var (
+		ctx, logger = u_logger.GetLogger(r.Context())
+		chain       = chi.URLParam(r, "chain")
+	)
+
+	if chain == "" {
+		logger.Errorf("missing chain")
+		h.BadRequest(w, r, fmt.Errorf("missing chain"))
+		return
+	}
+
+	number, err := strconv.ParseUint(chi.URLParam(r, "number"), 10, 64)
+	if err != nil {
+		logger.Errorf("invalid block number: %v", err)
+		h.BadRequest(w, r, fmt.Errorf("invalid block number: %v", err))
+		return
+	}
+
+	if err := h.chainSvc.CheckOperation(service.Chain(chain), service.OperationBlockRaw); err != nil {
+		logger.Errorf("not supported raw block: %v", err)
+		h.BadRequest(w, r, fmt.Errorf("not supported raw block: %v", err))
+		return
+	}
+
+	res, err := h.chainSvc.GetBlockRawLogs(ctx, service.Chain(chain), number)
+	switch {
+	case errors.Is(err, ethereum.NotFound):
+		logger.Errorf("not found block %d of chain %s", number, chain)
+		h.NotFound(w, r, fmt.Errorf("not found block %d of chain %s", number, chain))
+		return
+	case errors.Is(err, setting.ErrNotSupportedMethod):
+		logger.Errorf("not supported raw block %d of chain %s: %v", number, chain, err)
+		h.BadRequest(w, r, fmt.Errorf("not supported raw block %d of chain %s: %v", number, chain, err))
+		return
+	case err != nil:
+		logger.Errorf("failed to get raw block %d of chain %s: %v", number, chain, err)
+		h.Internal(w, r, fmt.Errorf("failed to get raw block %d of chain %s: %v", number, chain, err))
+		return
+	}
+	h.Success(w, r, res)
+}
+
+func (h *EnhanceApiHandler) handlerGetBlockRange(w http.ResponseWriter, r *http.Request) {
+	var (
+		ctx, logger = u_logger.GetLogger(r.Context())
+		chain       = chi.URLParam(r, "chain")
+		limit       = h.RequestParamInt(r, "limit", 0)
+		cursor      = h.RequestParamStr(r, "cursor")
+		raw         = h.RequestParamBool(r, "raw", false)
+	)
+
+	if chain == "" {
+		logger.Errorf("missing chain")
+		h.BadRequest(w, r, fmt.Errorf("missing chain"))
+		return
+	}
+
+	from, err := parseBlockNumberParam(r, "from")
+	if err != nil {
+		logger.Errorf("invalid from: %v", err)
+		h.BadRequest(w, r, err)
+		return
+	}
+	to, err := parseBlockNumberParam(r, "to")
+	if err != nil {
+		logger.Errorf("invalid to: %v", err)
+		h.BadRequest(w, r, err)
+		return
+	}
+
+	if err := h.chainSvc.CheckOperation(service.Chain(chain), service.OperationBlock); err != nil {
+		logger.Errorf("not supported block: %v", err)
+		h.BadRequest(w, r, fmt.Errorf("not supported block: %v", err))
+		return
+	}
+
+	res, err := h.chainSvc.GetBlockRange(ctx, service.Chain(chain), from, to, int(limit), cursor)
+	switch {
+	case errors.Is(err, setting.ErrInvalidBlockRange):
+		logger.Errorf("invalid block range of chain %s: %v", chain, err)
+		h.BadRequest(w, r, err)
+		return
+	case err != nil:
+		logger.Errorf("failed to get blocks of chain %s: %v", chain, err)
+		h.Internal(w, r, fmt.Errorf("failed to get blocks of chain %s: %v", chain, err))
+		return
+	}
+	if !raw {
+		for _, block := range res.Blocks {
+			block.Raw = nil
+		}
+	}
+	h.Success(w, r, res)
+}
+
+func (h *EnhanceApiHandler) handlerGetBlockAtTimestamp(w http.ResponseWriter, r *http.Request) {
+	var (
+		ctx, logger = u_logger.GetLogger(r.Context())
+		chain       = chi.URLParam(r, "chain")
+		raw         = h.RequestParamBool(r, "raw", false)
+	)
+
+	if chain == "" {
+		logger.Errorf("missing chain")
+		h.BadRequest(w, r, fmt.Errorf("missing chain"))
+		return
+	}
+
+	timestamp, err := parseTimestampParam(r, "timestamp")
+	if err != nil {
+		logger.Errorf("invalid timestamp: %v", err)
+		h.BadRequest(w, r, err)
+		return
+	}
+
+	if err := h.chainSvc.CheckOperation(service.Chain(chain), service.OperationBlock); err != nil {
+		logger.Errorf("not supported block: %v", err)
+		h.BadRequest(w, r, fmt.Errorf("not supported block: %v", err))
+		return
+	}
+
+	res, err := h.chainSvc.GetBlockAtTimestamp(ctx, service.Chain(chain), timestamp)
+	switch {
+	case errors.Is(err, ethereum.NotFound):
+		logger.Errorf("not found block at %d of chain %s: %v", timestamp, chain, err)
+		h.NotFound(w, r, fmt.Errorf("not found block at %d of chain %s", timestamp, chain))
+		return
+	case err != nil:
+		logger.Errorf("failed to get block at %d of chain %s: %v", timestamp, chain, err)
+		h.Internal(w, r, fmt.Errorf("failed to get block at %d of chain %s: %v", timestamp, chain, err))
+		return
+	}
+	if !raw {
+		res.Raw = nil
+	}
+	h.Success(w, r, res)
+}
+
+func (h *EnhanceApiHandler) handlerGetReorgs(w http.ResponseWriter, r *http.Request) {
+	var (
+		ctx, logger = u_logger.GetLogger(r.Context())
+		chain       = chi.URLParam(r, "chain")
+		limit       = h.RequestParamInt(r, "limit", 20)
+	)
+
+	if chain == "" {
+		logger.Errorf("missing chain")
+		h.BadRequest(w, r, fmt.Errorf("missing chain"))
+		return
+	}
+
+	if err := h.chainSvc.CheckOperation(service.Chain(chain), service.OperationBlock); err != nil {
+		logger.Errorf("not supported reorgs: %v", err)
+		h.BadRequest(w, r, fmt.Errorf("not supported reorgs: %v", err))
+		return
+	}
+
+	res, err := h.reorgTracker.GetReorgs(ctx, service.Chain(chain), int(limit))
+	if err != nil {
+		logger.Errorf("failed to get reorgs of chain %s: %v", chain, err)
+		h.Internal(w, r, fmt.Errorf("failed to get reorgs of chain %s: %v", chain, err))
+		return
+	}
+	h.Success(w, r, res)
+}
+
+func (h *EnhanceApiHandler) handlerGetChainStats(w http.ResponseWriter, r *http.Request) {
+	var (
+		ctx, logger = u_logger.GetLogger(r.Context())
+		chain       = chi.URLParam(r, "chain")
+		window      time.Duration
+	)
+
+	if chain == "" {
+		logger.Errorf("missing chain")
+		h.BadRequest(w, r, fmt.Errorf("missing chain"))
+		return
+	}
+
+	if value := h.RequestParamStr(r, "window"); value != "" {
+		var err error
+		if window, err = time.ParseDuration(value); err != nil {
+			logger.Errorf("invalid window %s: %v", value, err)
+			h.BadRequest(w, r, fmt.Errorf("invalid window %s: %v", value, err))
+			return
+		}
+	}
+
+	if err := h.chainSvc.CheckOperation(service.Chain(chain), service.OperationBlock); err != nil {
+		logger.Errorf("not supported chain stats: %v", err)
+		h.BadRequest(w, r, fmt.Errorf("not supported chain stats: %v", err))
+		return
+	}
+
+	res, err := h.chainStatsTracker.GetStats(ctx, service.Chain(chain), window)
+	switch {
+	case errors.Is(err, ethereum.NotFound):
+		logger.Errorf("not found stats of chain %s: %v", chain, err)
+		h.NotFound(w, r, fmt.Errorf("not found stats of chain %s: %v", chain, err))
+		return
+	case err != nil:
+		logger.Errorf("failed to get stats of chain %s: %v", chain, err)
+		h.Internal(w, r, fmt.Errorf("failed to get stats of chain %s: %v", chain, err))
+		return
+	}
+	h.Success(w, r, res)
+}
+
+func (h *EnhanceApiHandler) handlerGetGasEstimate(w http.ResponseWriter, r *http.Request) {
+	var (
+		ctx, logger = u_logger.GetLogger(r.Context())
+		chain       = chi.URLParam(r, "chain")
+	)
+
+	if chain == "" {
+		logger.Errorf("missing chain")
+		h.BadRequest(w, r, fmt.Errorf("missing chain"))
+		return
+	}
+
+	if err := h.chainSvc.CheckOperation(service.Chain(chain), service.OperationGas); err != nil {
+		logger.Errorf("not supported gas: %v", err)
+		h.BadRequest(w, r, fmt.Errorf("not supported gas: %v", err))
+		return
+	}
+
+	res, err := h.chainSvc.GetGasEstimate(ctx, service.Chain(chain))
+	switch {
+	case errors.Is(err, setting.ErrNotSupportedMethod):
+		logger.Errorf("not supported gas of chain %s: %v", chain, err)
+		h.BadRequest(w, r, fmt.Errorf("not supported gas of chain %s: %v", chain, err))
+		return
+	case err != nil:
+		logger.Errorf("failed to get gas of chain %s: %v", chain, err)
+		h.Internal(w, r, fmt.Errorf("failed to get gas of chain %s: %v", chain, err))
+		return
+	}
 	h.Success(w, r, res)
 }
 
@@ -68,14 +394,10 @@ func (h *EnhanceApiHandler) handlerCountTotalTxByChain(w http.ResponseWriter, r
 	}
 
 	chainName := service.Chain(chain)
-	switch chainName {
-	case service.ArbitrumNitro,
-		service.Near,
-		service.Klaytn:
-		logger.Errorf("not supported counting tx")
-		h.BadRequest(w, r, fmt.Errorf("not supported counting tx"))
+	if err := h.chainSvc.CheckOperation(chainName, service.OperationTxCount); err != nil {
+		logger.Errorf("not supported counting tx: %v", err)
+		h.BadRequest(w, r, fmt.Errorf("not supported counting tx: %v", err))
 		return
-	default:
 	}
 
 	res, err := h.chainSvc.CountTotalTxLast24h(ctx, chainName)
@@ -91,6 +413,9 @@ func (h *EnhanceApiHandler) handlerSearchTxHash(w http.ResponseWriter, r *http.R
 	var (
 		ctx, logger = u_logger.GetLogger(r.Context())
 		hash        = chi.URLParam(r, "hash")
+		firstMatch  = h.RequestParamBool(r, "first_match", false)
+		raw         = h.RequestParamBool(r, "raw", false)
+		chains      []service.Chain
 	)
 
 	if hash == "" {
@@ -99,8 +424,18 @@ func (h *EnhanceApiHandler) handlerSearchTxHash(w http.ResponseWriter, r *http.R
 		return
 	}
 
-	res, err := h.chainSvc.SearchTransactionHash(ctx, hash)
-	if err != nil {
+	for _, chain := range strings.Split(h.RequestParamStr(r, "chains"), ",") {
+		if chain = strings.TrimSpace(chain); chain != "" {
+			chains = append(chains, service.Chain(chain))
+		}
+	}
+
+	res, err := h.chainSvc.SearchTransactionHash(ctx, hash, chains, firstMatch, raw)
+	if errors.Is(err, setting.ErrNotSupportedChain) {
+		logger.Errorf("not supported tx search: %v", err)
+		h.BadRequest(w, r, fmt.Errorf("not supported tx search: %v", err))
+		return
+	} else if err != nil {
 		logger.Errorf("failed to get tx hash %s: %v", hash, err)
 		h.Internal(w, r, fmt.Errorf("failed to get tx hash %s: %v", hash, err))
 		return
@@ -111,3 +446,40 @@ func (h *EnhanceApiHandler) handlerSearchTxHash(w http.ResponseWriter, r *http.R
 	}
 	h.Success(w, r, res)
 }
+
+func (h *EnhanceApiHandler) handlerGetChains(w http.ResponseWriter, r *http.Request) {
+	h.Success(w, r, h.chainSvc.GetChains())
+}
+
+func (h *EnhanceApiHandler) handlerGetChainsHealth(w http.ResponseWriter, r *http.Request) {
+	h.Success(w, r, h.upstreamMonitor.GetStatuses())
+}
+
+// parseBlockNumberParam returns 0 when the query param is missing
+func parseBlockNumberParam(r *http.Request, key string) (uint64, error) {
+	value := r.URL.Query().Get(key)
+	if value == "" {
+		return 0, nil
+	}
+	res, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %s: %w", key, value, err)
+	}
+	return res, nil
+}
+
+// parseTimestampParam accepts unix seconds or RFC 3339 time
+func parseTimestampParam(r *http.Request, key string) (int64, error) {
+	value := r.URL.Query().Get(key)
+	if value == "" {
+		return 0, fmt.Errorf("missing %s", key)
+	}
+	if res, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return res, nil
+	}
+	res, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %s: %w", key, value, err)
+	}
+	return res.Unix(), nil
+}
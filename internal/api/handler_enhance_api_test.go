@@ -0,0 +1,57 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+	"github.com/tikivn/ultrago/u_handler"
+
+	"nimbus-enhance-api/internal/entity"
+	"nimbus-enhance-api/internal/repo"
+	"nimbus-enhance-api/internal/service"
+)
+
+func TestEnhanceApiHandler_CountTotalTxByChain(t *testing.T) {
+	convey.Convey("TestEnhanceApiHandler_CountTotalTxByChain", t, func() {
+		node := newFakeNode(t)
+		useTestProviders(t, node)
+
+		convey.Convey("Unknown chain", func() {
+			server := newTestEnhanceApiServer(t, newFakeChainRepo())
+
+			status, _ := doRequest(t, http.MethodGet, server.URL+"/tx/total/unknown", "", "")
+			convey.So(status, convey.ShouldEqual, http.StatusBadRequest)
+		})
+
+		convey.Convey("Chain without operation tx_count", func() {
+			server := newTestEnhanceApiServer(t, newFakeChainRepo(&entity.Chain{
+				Name:     "bsc",
+				Endpoint: node.URL(),
+				Methods: map[string]string{
+					"chainId":          "eth_chainId",
+					"getBlockByNumber": "eth_getBlockByNumber",
+				},
+				IsEVM:      true,
+				ChainID:    56,
+				Enabled:    true,
+				Operations: []string{service.OperationLatestBlock, service.OperationBlock},
+			}))
+
+			status, body := doRequest(t, http.MethodGet, server.URL+"/tx/total/bsc", "", "")
+			convey.So(status, convey.ShouldEqual, http.StatusBadRequest)
+			convey.So(string(body), convey.ShouldContainSubstring, service.OperationTxCount)
+		})
+	})
+}
+
+// newTestEnhanceApiServer serves the apis answered by the chain service alone, the trackers are left out
+func newTestEnhanceApiServer(t *testing.T, chainRepo repo.ChainRepo) *httptest.Server {
+	chainRegistry := newTestChainRegistry(t, chainRepo)
+	chainSvc := service.NewChainService(nil, nil, chainRegistry, newTestClientPool(t), nil)
+
+	server := httptest.NewServer(NewEnhanceApiHandler(u_handler.NewBaseHandler(), chainSvc, nil, nil, nil).Route())
+	t.Cleanup(server.Close)
+	return server
+}
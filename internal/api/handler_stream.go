@@ -0,0 +1,180 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/gorilla/websocket"
+	"github.com/tikivn/ultrago/u_handler"
+	"github.com/tikivn/ultrago/u_logger"
+
+	"nimbus-enhance-api/internal/entity"
+	"nimbus-enhance-api/internal/service"
+)
+
+const (
+	// streamHeartbeatInterval keeps idle streams open through proxies closing silent connections
+	streamHeartbeatInterval = 15 * time.Second
+	streamWriteTimeout      = 10 * time.Second
+)
+
+func NewStreamHandler(
+	baseHandler *u_handler.BaseHandler,
+	chainSvc service.ChainService,
+	blockStream service.BlockStream,
+) *StreamHandler {
+	return &StreamHandler{
+		BaseHandler: baseHandler,
+		chainSvc:    chainSvc,
+		blockStream: blockStream,
+		upgrader: websocket.Upgrader{
+			// stream api is public, the same as cors of the other apis
+			CheckOrigin: func(r *http.Request) bool { return true },
+		},
+	}
+}
+
+// StreamHandler serves long-lived streams, it is mounted outside the request timeout of the other apis
+type StreamHandler struct {
+	*u_handler.BaseHandler
+	chainSvc    service.ChainService
+	blockStream service.BlockStream
+	upgrader    websocket.Upgrader
+}
+
+func (h *StreamHandler) Route() chi.Router {
+	mux := chi.NewRouter()
+	mux.Get("/blocks/{chain}", h.handlerStreamBlocks)
+	return mux
+}
+
+// handlerStreamBlocks pushes new blocks over websocket when the request asks for an upgrade, over server-sent events otherwise
+func (h *StreamHandler) handlerStreamBlocks(w http.ResponseWriter, r *http.Request) {
+	var (
+		ctx, logger = u_logger.GetLogger(r.Context())
+		chain       = chi.URLParam(r, "chain")
+		raw         = h.RequestParamBool(r, "raw", false)
+	)
+
+	if chain == "" {
+		logger.Errorf("missing chain")
+		h.BadRequest(w, r, fmt.Errorf("missing chain"))
+		return
+	}
+
+	if err := h.chainSvc.CheckOperation(service.Chain(chain), service.OperationLatestBlock); err != nil {
+		logger.Errorf("not supported block stream: %v", err)
+		h.BadRequest(w, r, fmt.Errorf("not supported block stream: %v", err))
+		return
+	}
+
+	blocks, unsubscribe, err := h.blockStream.Subscribe(service.Chain(chain))
+	if err != nil {
+		logger.Errorf("failed to subscribe blocks of chain %s: %v", chain, err)
+		h.Internal(w, r, fmt.Errorf("failed to subscribe blocks of chain %s: %v", chain, err))
+		return
+	}
+	defer unsubscribe()
+
+	// blocks are shared by all subscribers, so raw is dropped on a copy
+	view := func(block *entity.Block) *entity.Block {
+		if raw {
+			return block
+		}
+		res := *block
+		res.Raw = nil
+		return &res
+	}
+
+	if websocket.IsWebSocketUpgrade(r) {
+		conn, err := h.upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			// upgrader has replied the error
+			logger.Errorf("failed to upgrade websocket of chain %s: %v", chain, err)
+			return
+		}
+		defer conn.Close()
+		h.streamWebsocket(conn, blocks, view)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		logger.Errorf("streaming unsupported")
+		h.Internal(w, r, fmt.Errorf("streaming unsupported"))
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(streamHeartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-heartbeat.C:
+			// sse comment line, ignored by clients
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+		case block, ok := <-blocks:
+			if !ok {
+				return
+			}
+			data, err := json.Marshal(view(block))
+			if err != nil {
+				logger.Errorf("failed to marshal block %d of chain %s: %v", block.Number, chain, err)
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "event: block\nid: %d\ndata: %s\n\n", block.Number, data); err != nil {
+				return
+			}
+		}
+		flusher.Flush()
+	}
+}
+
+// streamWebsocket writes blocks as json text messages until the client leaves or the stream ends.
+// Messages from the client are discarded, reading only detects the close.
+func (h *StreamHandler) streamWebsocket(conn *websocket.Conn, blocks <-chan *entity.Block, view func(*entity.Block) *entity.Block) {
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+
+	heartbeat := time.NewTicker(streamHeartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-closed:
+			return
+		case <-heartbeat.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(streamWriteTimeout)); err != nil {
+				return
+			}
+		case block, ok := <-blocks:
+			if !ok {
+				_ = conn.WriteControl(websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.CloseGoingAway, "stream closed"), time.Now().Add(streamWriteTimeout))
+				return
+			}
+			_ = conn.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+			if err := conn.WriteJSON(view(block)); err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/smartystreets/goconvey/convey"
+	"github.com/tikivn/ultrago/u_handler"
+
+	"nimbus-enhance-api/internal/conf"
+	"nimbus-enhance-api/internal/entity"
+	"nimbus-enhance-api/internal/repo"
+	"nimbus-enhance-api/internal/service"
+)
+
+func TestStreamHandler_StreamBlocks(t *testing.T) {
+	convey.Convey("TestStreamHandler_StreamBlocks", t, func() {
+		node := newFakeNode(t)
+		useTestProviders(t, node)
+		pollInterval := 20 * time.Millisecond
+		conf.Config.BlockPollInterval = pollInterval
+
+		convey.Convey("Clients of a chain without websocket upstream share one polling loop", func() {
+			server := newTestStreamServer(t, newFakeChainRepo())
+
+			first := connectSse(t, server.URL+"/blocks/bsc")
+			convey.So(first.Next(), convey.ShouldEqual, 1)
+			second := connectSse(t, server.URL+"/blocks/bsc")
+
+			// a loop of its own would publish block 1 to the second client first
+			node.SetHead(2)
+			convey.So(first.Next(), convey.ShouldEqual, 2)
+			convey.So(second.Next(), convey.ShouldEqual, 2)
+			convey.So(node.Calls("eth_getBlockByNumber:0x2"), convey.ShouldEqual, 1)
+			convey.So(node.Subscribes(), convey.ShouldEqual, 0)
+
+			first.Close()
+			second.Close()
+			convey.So(eventually(func() bool {
+				calls := node.Calls("eth_getBlockByNumber:latest")
+				time.Sleep(5 * pollInterval)
+				return node.Calls("eth_getBlockByNumber:latest") == calls
+			}), convey.ShouldBeTrue)
+
+			// a new feed starts from the latest block, the stopped one had published it already
+			third := connectSse(t, server.URL+"/blocks/bsc")
+			convey.So(third.Next(), convey.ShouldEqual, 2)
+		})
+
+		convey.Convey("Clients of a chain with websocket upstream share one newHeads subscription", func() {
+			server := newTestStreamServer(t, newFakeChainRepo(&entity.Chain{
+				Name:     "bsc",
+				Endpoint: node.URL(),
+				Upstreams: []entity.ChainUpstream{
+					{Endpoint: node.URL(), Weight: 1},
+					{Endpoint: node.WsURL(), Weight: 1},
+				},
+				Methods: map[string]string{
+					"chainId":          "eth_chainId",
+					"getBlockByNumber": "eth_getBlockByNumber",
+				},
+				IsEVM:   true,
+				ChainID: 56,
+				Enabled: true,
+			}))
+
+			first := connectSse(t, server.URL+"/blocks/bsc")
+			convey.So(eventually(func() bool { return node.Subscriptions() == 1 }), convey.ShouldBeTrue)
+			second := connectSse(t, server.URL+"/blocks/bsc")
+
+			node.SetHead(5)
+			convey.So(first.Next(), convey.ShouldEqual, 5)
+			convey.So(second.Next(), convey.ShouldEqual, 5)
+			convey.So(node.Subscribes(), convey.ShouldEqual, 1)
+			convey.So(node.Calls("eth_getBlockByNumber:latest"), convey.ShouldEqual, 0)
+
+			first.Close()
+			second.Close()
+			convey.So(eventually(func() bool { return node.Subscriptions() == 0 }), convey.ShouldBeTrue)
+		})
+
+		convey.Convey("Unknown chain", func() {
+			server := newTestStreamServer(t, newFakeChainRepo())
+
+			res, err := http.Get(server.URL + "/blocks/unknown")
+			convey.So(err, convey.ShouldBeNil)
+			defer res.Body.Close()
+			convey.So(res.StatusCode, convey.ShouldEqual, http.StatusBadRequest)
+		})
+	})
+}
+
+func newTestStreamServer(t *testing.T, chainRepo repo.ChainRepo) *httptest.Server {
+	chainRegistry := newTestChainRegistry(t, chainRepo)
+	clientPool := newTestClientPool(t)
+	blockStream, cleanup := service.NewBlockStream(chainRegistry, clientPool)
+	chainSvc := service.NewChainService(nil, nil, chainRegistry, clientPool, nil)
+
+	server := httptest.NewServer(NewStreamHandler(u_handler.NewBaseHandler(), chainSvc, blockStream).Route())
+	t.Cleanup(server.Close)
+	// streams end before the server waits for its requests
+	t.Cleanup(cleanup)
+	return server
+}
+
+// sseClient reads the block numbers of a server-sent events stream
+type sseClient struct {
+	cancel context.CancelFunc
+	ids    chan uint64
+}
+
+// connectSse returns once the stream is subscribed, the handler subscribes before answering the headers
+func connectSse(t *testing.T, url string) *sseClient {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("unexpected status %d of stream %s", res.StatusCode, url)
+	}
+
+	c := &sseClient{cancel: cancel, ids: make(chan uint64, 16)}
+	go func() {
+		defer res.Body.Close()
+		scanner := bufio.NewScanner(res.Body)
+		for scanner.Scan() {
+			if !strings.HasPrefix(scanner.Text(), "id: ") {
+				continue
+			}
+			id, err := strconv.ParseUint(strings.TrimPrefix(scanner.Text(), "id: "), 10, 64)
+			if err == nil {
+				c.ids <- id
+			}
+		}
+	}()
+	return c
+}
+
+// Next returns the number of the next block, 0 when none comes in time
+func (c *sseClient) Next() uint64 {
+	select {
+	case id := <-c.ids:
+		return id
+	case <-time.After(5 * time.Second):
+		return 0
+	}
+}
+
+func (c *sseClient) Close() {
+	c.cancel()
+}
+
+// eventually polls cond until it holds, false when it does not in time
+func eventually(cond func() bool) bool {
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if cond() {
+			return true
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return false
+}
@@ -14,9 +14,11 @@ import (
 
 func NewApp(
 	httpServer *HttpServer,
+	cronjob Cronjob,
 ) App {
 	return &app{
 		httpServer: httpServer,
+		cronjob:    cronjob,
 	}
 }
 
@@ -27,6 +29,7 @@ type App interface {
 
 type app struct {
 	httpServer *HttpServer
+	cronjob    Cronjob
 }
 
 func (a *app) Start(ctx context.Context) error {
@@ -47,6 +50,11 @@ func (a *app) Start(ctx context.Context) error {
 			return nil
 		})
 	})
+	eg.Go(func() error {
+		return u_graceful.BlockListen(childCtx, func() error {
+			return a.cronjob.Start(childCtx)
+		})
+	})
 
 	logger.Info("server started!")
 	return eg.Wait()
@@ -56,5 +64,8 @@ func (a *app) Stop(ctx context.Context) error {
 	ctx, logger := u_logger.GetLogger(ctx)
 	logger.Infof("stop listening http request on %s", setting.HttpPort)
 	err := a.httpServer.Shutdown(ctx)
+	if cronErr := a.cronjob.Stop(ctx); cronErr != nil && err == nil {
+		err = cronErr
+	}
 	return err
 }
@@ -6,11 +6,23 @@ import (
 
 	"github.com/go-co-op/gocron"
 	"github.com/tikivn/ultrago/u_logger"
+
+	"nimbus-enhance-api/internal/conf"
+	"nimbus-enhance-api/internal/service"
 )
 
-func NewCronjob() Cronjob {
+func NewCronjob(
+	chainRegistry service.ChainRegistry,
+	upstreamMonitor service.UpstreamMonitor,
+	reorgTracker service.ReorgTracker,
+	chainStatsTracker service.ChainStatsTracker,
+) Cronjob {
 	return &cronjob{
-		scheduler: gocron.NewScheduler(time.UTC),
+		scheduler:         gocron.NewScheduler(time.UTC),
+		chainRegistry:     chainRegistry,
+		upstreamMonitor:   upstreamMonitor,
+		reorgTracker:      reorgTracker,
+		chainStatsTracker: chainStatsTracker,
 	}
 }
 
@@ -20,23 +32,63 @@ type Cronjob interface {
 }
 
 type cronjob struct {
-	scheduler *gocron.Scheduler
+	scheduler         *gocron.Scheduler
+	chainRegistry     service.ChainRegistry
+	upstreamMonitor   service.UpstreamMonitor
+	reorgTracker      service.ReorgTracker
+	chainStatsTracker service.ChainStatsTracker
 }
 
 func (c *cronjob) Start(ctx context.Context) error {
 	ctx, logger := u_logger.GetLogger(ctx)
 
+	// reload chains so changes from other instances are applied without restart
 	j, err := c.scheduler.SingletonMode().
-		Cron("*/15 * * * *").
+		Every(conf.Config.ChainReloadInterval).
+		WaitForSchedule().
+		Do(func() {
+			if err := c.chainRegistry.Reload(ctx); err != nil {
+				logger.Errorf("failed to reload chains: %v", err)
+			}
+		})
+	if err != nil {
+		logger.Errorf("failed to registered cronjob %#v: %v", j, err)
+		return err
+	}
+
+	// probe upstreams so lagging or down ones are skipped by failover
+	j, err = c.scheduler.SingletonMode().
+		Every(conf.Config.UpstreamProbeInterval).
+		Do(func() {
+			if err := c.upstreamMonitor.Probe(ctx); err != nil {
+				logger.Errorf("failed to probe upstreams: %v", err)
+			}
+		})
+	if err != nil {
+		logger.Errorf("failed to registered cronjob %#v: %v", j, err)
+		return err
+	}
+
+	// track recent block hashes so reorganized blocks are dropped from caches
+	j, err = c.scheduler.SingletonMode().
+		Every(conf.Config.ReorgTrackInterval).
+		Do(func() {
+			if err := c.reorgTracker.Track(ctx); err != nil {
+				logger.Errorf("failed to track reorgs: %v", err)
+			}
+		})
+	if err != nil {
+		logger.Errorf("failed to registered cronjob %#v: %v", j, err)
+		return err
+	}
+
+	// sample recent blocks for the block time and throughput stats of every chain
+	j, err = c.scheduler.SingletonMode().
+		Every(conf.Config.ChainStatsInterval).
 		Do(func() {
-			// TODO: write function to do cronjob
-			//logger.Infof("start updating new token")
-			//err := a.tokenUpdateSvc.SyncTokens(ctx, carbon.Now(carbon.UTC).StartOfDay().ToStdTime())
-			//if err != nil {
-			//	logger.Errorf("failed to sync new tokens: %v", err)
-			//	return
-			//}
-			//logger.Infof("done updating new token")
+			if err := c.chainStatsTracker.Track(ctx); err != nil {
+				logger.Errorf("failed to sample chain stats: %v", err)
+			}
 		})
 	if err != nil {
 		logger.Errorf("failed to registered cronjob %#v: %v", j, err)
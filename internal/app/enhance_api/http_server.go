@@ -10,10 +10,15 @@ import (
 	"github.com/hellofresh/health-go/v5"
 
 	"nimbus-enhance-api/internal/api"
+	"nimbus-enhance-api/internal/service"
 )
 
 func NewHttpServer(
-	enhanceApiHandler *api.EnhanceApiHandler) *HttpServer {
+	enhanceApiHandler *api.EnhanceApiHandler,
+	chainAdminHandler *api.ChainAdminHandler,
+	streamHandler *api.StreamHandler,
+	upstreamMonitor service.UpstreamMonitor,
+	blockStream service.BlockStream) *HttpServer {
 	globalMux := chi.NewRouter()
 	globalMux.Mount("/debug", middleware.Profiler())
 
@@ -32,13 +37,30 @@ func NewHttpServer(
 		Name:    "nimbus_enhance_api",
 		Version: "v1.0",
 	}),
+		health.WithChecks(health.Config{
+			Name:      "chain_upstreams",
+			SkipOnErr: true,
+			Check:     upstreamMonitor.Check,
+		}),
 	)
 	mux.Get("/", h.HandlerFunc)
 	mux.Mount("/api/v1", enhanceApiHandler.Route())
+	mux.Mount("/api/v1/admin", chainAdminHandler.Route())
+
+	// streams stay open as long as clients listen, so they have no request timeout
+	streamMux := globalMux.Group(nil)
+	streamMux.Use(middleware.RequestID)
+	streamMux.Use(middleware.RealIP)
+	streamMux.Use(middleware.Logger)
+	streamMux.Use(middleware.Recoverer)
+	streamMux.Use(CorsPublic)
+	streamMux.Mount("/api/v1/stream", streamHandler.Route())
 
 	server := &http.Server{
 		Handler: globalMux,
 	}
+	// shutdown waits for active connections, end the streams first
+	server.RegisterOnShutdown(blockStream.Close)
 	return &HttpServer{server}
 }
 
@@ -3,6 +3,7 @@ package conf
 import (
 	"reflect"
 	"strings"
+	"time"
 
 	"github.com/creasty/defaults"
 	"github.com/spf13/viper"
@@ -35,6 +36,45 @@ type config struct {
 	RedisDB      int    `mapstructure:"REDIS_DB" default:"0"`
 	RedisUser    string `mapstructure:"REDIS_USER" default:"-"`
 	RedisPass    string `mapstructure:"REDIS_PASS" default:"-"`
+
+	// chain registry
+	ChainReloadInterval time.Duration `mapstructure:"CHAIN_RELOAD_INTERVAL" default:"30s"`
+	AdminApiKey         string        `mapstructure:"ADMIN_API_KEY" default:""`
+	// chain ids reported by upstreams are verified again after this ttl
+	ChainIDVerifyTTL time.Duration `mapstructure:"CHAIN_ID_VERIFY_TTL" default:"1h"`
+
+	// upstream health check
+	UpstreamProbeInterval time.Duration `mapstructure:"UPSTREAM_PROBE_INTERVAL" default:"15s"`
+	UpstreamProbeTimeout  time.Duration `mapstructure:"UPSTREAM_PROBE_TIMEOUT" default:"10s"`
+	UpstreamMaxHeadLag    uint64        `mapstructure:"UPSTREAM_MAX_HEAD_LAG" default:"20"`
+
+	// rpc client pool
+	RpcMaxConnections    int           `mapstructure:"RPC_MAX_CONNECTIONS" default:"32"`
+	RpcClientIdleTimeout time.Duration `mapstructure:"RPC_CLIENT_IDLE_TIMEOUT" default:"5m"`
+
+	// blocks deeper than this below the head are cached without expiration
+	BlockImmutableDepth uint64 `mapstructure:"BLOCK_IMMUTABLE_DEPTH" default:"128"`
+
+	// pending transactions are cached shortly, so they are looked up again once mined
+	PendingTxCacheTTL time.Duration `mapstructure:"PENDING_TX_CACHE_TTL" default:"5s"`
+
+	// 4-byte selectors loaded into postgres by the migration to decode transaction inputs
+	FunctionSignatureFile string `mapstructure:"FUNCTION_SIGNATURE_FILE" default:"resources/function_signatures.tsv"`
+
+	// block stream polls chains without websocket upstream
+	BlockPollInterval time.Duration `mapstructure:"BLOCK_POLL_INTERVAL" default:"3s"`
+
+	// reorg tracker compares the hashes of the last blocks of every chain
+	ReorgTrackInterval time.Duration `mapstructure:"REORG_TRACK_INTERVAL" default:"15s"`
+	ReorgTrackDepth    uint64        `mapstructure:"REORG_TRACK_DEPTH" default:"64"`
+
+	// stats tracker samples the latest blocks of every chain for block time and throughput
+	ChainStatsInterval        time.Duration `mapstructure:"CHAIN_STATS_INTERVAL" default:"1m"`
+	ChainStatsWindow          time.Duration `mapstructure:"CHAIN_STATS_WINDOW" default:"1h"`
+	ChainStatsBlocksPerSample uint64        `mapstructure:"CHAIN_STATS_BLOCKS_PER_SAMPLE" default:"10"`
+
+	// providers, api keys are read from the envs or secret files named in the provider config
+	ProviderConfig string `mapstructure:"PROVIDER_CONFIG" default:""`
 }
 
 func (c *config) IsLocal() bool {
@@ -80,6 +120,11 @@ func LoadConfig(path string) error {
 		return err
 	}
 
+	if err := loadProviders(Config.ProviderConfig); err != nil {
+		return err
+	}
+	addRedactHook()
+
 	return nil
 }
 
@@ -0,0 +1,279 @@
+package conf
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"github.com/tikivn/ultrago/u_logger"
+)
+
+const (
+	ProviderNodereal  = "nodereal"
+	ProviderChainbase = "chainbase"
+
+	redactedSecret = "***"
+)
+
+// Providers holds rpc providers and the provider serving each built-in chain,
+// the built-in definitions are overridden by the file at PROVIDER_CONFIG.
+var Providers = defaultProviders()
+
+// Provider is a node or data provider, {api_key} and {chain} in UrlTemplate are replaced when building an endpoint.
+// The api key is read from env ApiKeyEnv, or from the mounted secret file ApiKeyFile (or env <ApiKeyEnv>_FILE).
+type Provider struct {
+	UrlTemplate string `mapstructure:"url_template"`
+	ApiKeyEnv   string `mapstructure:"api_key_env"`
+	ApiKeyFile  string `mapstructure:"api_key_file"`
+
+	apiKey string
+}
+
+// ChainProvider binds a chain to a provider, UrlTemplate and api key settings override the ones of the provider.
+type ChainProvider struct {
+	Provider    string `mapstructure:"provider"`
+	UrlTemplate string `mapstructure:"url_template"`
+	ApiKeyEnv   string `mapstructure:"api_key_env"`
+	ApiKeyFile  string `mapstructure:"api_key_file"`
+
+	apiKey string
+}
+
+type providerConfig struct {
+	Providers map[string]*Provider      `mapstructure:"providers"`
+	Chains    map[string]*ChainProvider `mapstructure:"chains"`
+
+	secrets []string
+}
+
+func defaultProviders() *providerConfig {
+	nodereal := func(urlTemplate string) *ChainProvider {
+		return &ChainProvider{Provider: ProviderNodereal, UrlTemplate: urlTemplate}
+	}
+	public := func(url string) *ChainProvider {
+		return &ChainProvider{UrlTemplate: url}
+	}
+	return &providerConfig{
+		Providers: map[string]*Provider{
+			ProviderNodereal: {
+				UrlTemplate: "https://open-platform.nodereal.io/{api_key}/{chain}/",
+				ApiKeyEnv:   "NODEREAL_API_KEY",
+			},
+			ProviderChainbase: {
+				UrlTemplate: "https://api.chainbase.online/v1",
+				ApiKeyEnv:   "CHAINBASE_API_KEY",
+			},
+		},
+		Chains: map[string]*ChainProvider{
+			"bsc":            nodereal("https://bsc-mainnet.nodereal.io/v1/{api_key}"),
+			"ethereum":       nodereal("https://eth-mainnet.nodereal.io/v1/{api_key}"),
+			"polygon":        nodereal("https://polygon-mainnet.nodereal.io/v1/{api_key}"),
+			"optimism":       nodereal("https://opt-mainnet.nodereal.io/v1/{api_key}"),
+			"arbitrum":       nodereal(""),
+			"avalanche":      nodereal("https://open-platform.nodereal.io/{api_key}/avalanche-c/ext/bc/C/rpc"),
+			"arbitrum-nitro": nodereal(""),
+			"fantom":         nodereal(""),
+			"solana":         nodereal(""),
+			"near":           nodereal(""),
+			"klaytn":         nodereal(""),
+			"opbnb":          nodereal("https://opbnb-mainnet.nodereal.io/v1/{api_key}"),
+			"base":           public("https://mainnet.base.org"),
+			"zksync-era":     public("https://mainnet.era.zksync.io"),
+			"linea":          public("https://rpc.linea.build"),
+			"scroll":         public("https://rpc.scroll.io"),
+			"gnosis":         public("https://rpc.gnosischain.com"),
+			"mantle":         public("https://rpc.mantle.xyz"),
+			"aptos":          public("https://fullnode.mainnet.aptoslabs.com"),
+			"sui":            public("https://fullnode.mainnet.sui.io"),
+			"tron":           public("https://api.trongrid.io"),
+			"bitcoin":        public("https://bitcoin-rpc.publicnode.com"),
+			"litecoin":       public("https://litecoin-rpc.publicnode.com"),
+			"dogecoin":       public("https://dogecoin-rpc.publicnode.com"),
+		},
+	}
+}
+
+// loadProviders merges the provider file into the built-in definitions and reads every api key,
+// it fails when a provider or chain requiring a key has none.
+func loadProviders(path string) error {
+	providers := defaultProviders()
+	if path != "" {
+		v := viper.New()
+		v.SetConfigFile(path)
+		if err := v.ReadInConfig(); err != nil {
+			return fmt.Errorf("cannot read provider config %s: %v", path, err)
+		}
+
+		var file providerConfig
+		if err := v.Unmarshal(&file); err != nil {
+			return fmt.Errorf("invalid provider config %s: %v", path, err)
+		}
+		for name, provider := range file.Providers {
+			providers.Providers[name] = provider
+		}
+		for chain, chainProvider := range file.Chains {
+			providers.Chains[chain] = chainProvider
+		}
+	}
+
+	var secrets []string
+	for name, provider := range providers.Providers {
+		apiKey, err := readApiKey(provider.ApiKeyEnv, provider.ApiKeyFile)
+		if err != nil {
+			return fmt.Errorf("missing api key of provider %s: %v", name, err)
+		}
+		provider.apiKey = apiKey
+		secrets = append(secrets, apiKey)
+	}
+	for chain, chainProvider := range providers.Chains {
+		if _, ok := providers.Providers[chainProvider.Provider]; !ok && chainProvider.UrlTemplate == "" {
+			return fmt.Errorf("chain %s uses unknown provider %s", chain, chainProvider.Provider)
+		}
+		apiKey, err := readApiKey(chainProvider.ApiKeyEnv, chainProvider.ApiKeyFile)
+		if err != nil {
+			return fmt.Errorf("missing api key of chain %s: %v", chain, err)
+		}
+		chainProvider.apiKey = apiKey
+		secrets = append(secrets, apiKey)
+	}
+
+	// longest first, so a key containing another one is redacted entirely
+	sort.Slice(secrets, func(i, j int) bool {
+		return len(secrets[i]) > len(secrets[j])
+	})
+	providers.secrets = secrets[:0]
+	for _, secret := range secrets {
+		if secret != "" {
+			providers.secrets = append(providers.secrets, secret)
+		}
+	}
+
+	Providers = providers
+	return nil
+}
+
+// readApiKey returns empty key when neither env nor file is configured, otherwise the key must exist.
+func readApiKey(env string, file string) (string, error) {
+	if env == "" && file == "" {
+		return "", nil
+	}
+	if env != "" {
+		if apiKey := strings.TrimSpace(viper.GetString(env)); apiKey != "" {
+			return apiKey, nil
+		}
+		if file == "" {
+			file = viper.GetString(env + "_FILE")
+		}
+	}
+	if file == "" {
+		return "", fmt.Errorf("env %s is empty", env)
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return "", fmt.Errorf("cannot read secret file: %v", err)
+	}
+	apiKey := strings.TrimSpace(string(data))
+	if apiKey == "" {
+		return "", fmt.Errorf("secret file %s is empty", file)
+	}
+	return apiKey, nil
+}
+
+// ApiKey returns the api key of a provider
+func (c *providerConfig) ApiKey(name string) string {
+	provider, ok := c.Providers[name]
+	if !ok {
+		return ""
+	}
+	return provider.apiKey
+}
+
+// Url returns the url of a provider without chain
+func (c *providerConfig) Url(name string) string {
+	provider, ok := c.Providers[name]
+	if !ok {
+		return ""
+	}
+	return renderUrl(provider.UrlTemplate, provider.apiKey, "")
+}
+
+// ChainEndpoint builds the rpc endpoint of a chain from its provider, false when no provider serves the chain.
+func (c *providerConfig) ChainEndpoint(chain string) (string, bool) {
+	endpoint, ok := c.ChainEndpointTemplate(chain)
+	if !ok {
+		return "", false
+	}
+	return c.RenderChainEndpoint(chain, endpoint), true
+}
+
+// ChainEndpointTemplate is the endpoint of a chain keeping placeholder {api_key}, so it can be stored and shown
+// without the secret. RenderChainEndpoint fills the key when the endpoint is called.
+func (c *providerConfig) ChainEndpointTemplate(chain string) (string, bool) {
+	chainProvider, ok := c.Chains[chain]
+	if !ok {
+		return "", false
+	}
+	urlTemplate := chainProvider.UrlTemplate
+	if provider, ok := c.Providers[chainProvider.Provider]; ok && urlTemplate == "" {
+		urlTemplate = provider.UrlTemplate
+	}
+	return strings.ReplaceAll(urlTemplate, "{chain}", chain), true
+}
+
+// RenderChainEndpoint fills {api_key} and {chain} of an endpoint with the api key of the chain provider,
+// endpoints without placeholder are returned as is.
+func (c *providerConfig) RenderChainEndpoint(chain string, endpoint string) string {
+	var apiKey string
+	if chainProvider, ok := c.Chains[chain]; ok {
+		apiKey = chainProvider.apiKey
+		if provider, ok := c.Providers[chainProvider.Provider]; ok && apiKey == "" {
+			apiKey = provider.apiKey
+		}
+	}
+	return renderUrl(endpoint, apiKey, chain)
+}
+
+func renderUrl(urlTemplate string, apiKey string, chain string) string {
+	return strings.NewReplacer("{api_key}", apiKey, "{chain}", chain).Replace(urlTemplate)
+}
+
+// Redact hides every provider api key in s, use it on any text which may include an endpoint.
+func Redact(s string) string {
+	for _, secret := range Providers.secrets {
+		s = strings.ReplaceAll(s, secret, redactedSecret)
+	}
+	return s
+}
+
+var redactHookOnce sync.Once
+
+// addRedactHook redacts api keys from every log line of the application logger
+func addRedactHook() {
+	redactHookOnce.Do(func() {
+		u_logger.NewLogger().Logger.AddHook(&redactHook{})
+	})
+}
+
+type redactHook struct{}
+
+func (h *redactHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (h *redactHook) Fire(entry *logrus.Entry) error {
+	entry.Message = Redact(entry.Message)
+	for k, v := range entry.Data {
+		switch val := v.(type) {
+		case string:
+			entry.Data[k] = Redact(val)
+		case error:
+			entry.Data[k] = Redact(val.Error())
+		}
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package conf
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+	"github.com/spf13/viper"
+)
+
+func TestLoadProviders(t *testing.T) {
+	convey.Convey("TestLoadProviders", t, func() {
+		dir := t.TempDir()
+		defer viper.Reset()
+
+		convey.Convey("Missing api key", func() {
+			viper.Set("NODEREAL_API_KEY", "")
+			viper.Set("CHAINBASE_API_KEY", "chainbase-secret")
+
+			err := loadProviders("")
+			convey.So(err, convey.ShouldNotBeNil)
+			convey.So(err.Error(), convey.ShouldContainSubstring, ProviderNodereal)
+		})
+
+		convey.Convey("Api key from env and secret file", func() {
+			secretFile := filepath.Join(dir, "nodereal_api_key")
+			convey.So(os.WriteFile(secretFile, []byte("nodereal-secret\n"), 0600), convey.ShouldBeNil)
+			viper.Set("NODEREAL_API_KEY_FILE", secretFile)
+			viper.Set("CHAINBASE_API_KEY", "chainbase-secret")
+
+			err := loadProviders("")
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(Providers.ApiKey(ProviderChainbase), convey.ShouldEqual, "chainbase-secret")
+
+			endpoint, ok := Providers.ChainEndpoint("bsc")
+			convey.So(ok, convey.ShouldBeTrue)
+			convey.So(endpoint, convey.ShouldEqual, "https://bsc-mainnet.nodereal.io/v1/nodereal-secret")
+
+			endpoint, ok = Providers.ChainEndpoint("near")
+			convey.So(ok, convey.ShouldBeTrue)
+			convey.So(endpoint, convey.ShouldEqual, "https://open-platform.nodereal.io/nodereal-secret/near/")
+
+			err = errors.New(`Post "` + endpoint + `": dial tcp: i/o timeout`)
+			convey.So(Redact(err.Error()), convey.ShouldEqual, `Post "https://open-platform.nodereal.io/***/near/": dial tcp: i/o timeout`)
+		})
+
+		convey.Convey("Per-chain override from provider config", func() {
+			configFile := filepath.Join(dir, "providers.yaml")
+			convey.So(os.WriteFile(configFile, []byte(`
+providers:
+  ankr:
+    url_template: https://rpc.ankr.com/{chain}/{api_key}
+    api_key_env: ANKR_API_KEY
+chains:
+  fantom:
+    provider: ankr
+  solana:
+    url_template: https://api.mainnet-beta.solana.com
+`), 0600), convey.ShouldBeNil)
+			viper.Set("NODEREAL_API_KEY", "nodereal-secret")
+			viper.Set("CHAINBASE_API_KEY", "chainbase-secret")
+			viper.Set("ANKR_API_KEY", "ankr-secret")
+
+			err := loadProviders(configFile)
+			convey.So(err, convey.ShouldBeNil)
+
+			endpoint, _ := Providers.ChainEndpoint("fantom")
+			convey.So(endpoint, convey.ShouldEqual, "https://rpc.ankr.com/fantom/ankr-secret")
+			endpoint, _ = Providers.ChainEndpoint("solana")
+			convey.So(endpoint, convey.ShouldEqual, "https://api.mainnet-beta.solana.com")
+			endpoint, _ = Providers.ChainEndpoint("bsc")
+			convey.So(Redact(endpoint), convey.ShouldEqual, "https://bsc-mainnet.nodereal.io/v1/***")
+
+			endpoint, _ = Providers.ChainEndpointTemplate("fantom")
+			convey.So(endpoint, convey.ShouldEqual, "https://rpc.ankr.com/fantom/{api_key}")
+			convey.So(Providers.RenderChainEndpoint("fantom", endpoint), convey.ShouldEqual, "https://rpc.ankr.com/fantom/ankr-secret")
+			convey.So(Providers.RenderChainEndpoint("fantom", "https://fantom.example.com"), convey.ShouldEqual, "https://fantom.example.com")
+		})
+	})
+}
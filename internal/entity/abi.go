@@ -0,0 +1,76 @@
+package entity
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/tikivn/ultrago/u_validator"
+)
+
+// sources of decoded functions
+const (
+	AbiSourceContract  = "contract_abi"
+	AbiSourceSignature = "signature_db"
+	AbiSourceBuiltin   = "builtin"
+)
+
+// FunctionSignature is a 4-byte selector of the signature database, a selector may have several signatures
+type FunctionSignature struct {
+	Base
+	Selector  string `json:"selector"` // 0x prefixed 4 bytes hex
+	Signature string `json:"signature"`
+}
+
+// ContractAbi is the abi uploaded for a contract, it takes precedence over the signature database
+type ContractAbi struct {
+	Base
+	Chain   string          `json:"chain" validate:"required"`
+	Address string          `json:"address" validate:"required"`
+	Abi     json.RawMessage `json:"abi" validate:"required"`
+}
+
+func (c *ContractAbi) Validate() error {
+	if err := u_validator.Struct(c); err != nil {
+		return err
+	}
+	if !common.IsHexAddress(c.Address) {
+		return fmt.Errorf("invalid contract address %v", c.Address)
+	}
+	// addresses are matched lowercase, checksum casing is not kept
+	c.Address = strings.ToLower(c.Address)
+	return nil
+}
+
+// DecodedInput lists every function the selector of a transaction input may call,
+// one function when the contract has an uploaded abi.
+type DecodedInput struct {
+	Selector  string            `json:"selector"`
+	Functions []DecodedFunction `json:"functions"`
+}
+
+// DecodedFunction has no params when the input does not decode with the signature
+type DecodedFunction struct {
+	Name      string         `json:"name"`
+	Signature string         `json:"signature"`
+	Source    string         `json:"source"`
+	Params    []DecodedParam `json:"params,omitempty"`
+}
+
+// DecodedParam values are json friendly: integers as decimal strings, addresses and bytes as hex,
+// tuples as objects keyed by component name.
+type DecodedParam struct {
+	Name  string      `json:"name,omitempty"`
+	Type  string      `json:"type"`
+	Value interface{} `json:"value"`
+}
+
+// DecodedLog is the event of a log, decoded with the abi uploaded for the emitting contract or the built-in
+// token and dex events. Indexed dynamic params (strings, bytes, arrays and tuples) are the hash stored in the topic.
+type DecodedLog struct {
+	Name      string         `json:"name"`
+	Signature string         `json:"signature"`
+	Source    string         `json:"source"`
+	Params    []DecodedParam `json:"params"`
+}
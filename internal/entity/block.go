@@ -0,0 +1,32 @@
+package entity
+
+// Block is the same view of a block on every chain, fields a chain has no concept of are left empty.
+// Raw keeps the block as answered by the upstream.
+type Block struct {
+	Chain        string      `json:"chain"`
+	Number       uint64      `json:"number"`
+	Hash         string      `json:"hash"`
+	ParentHash   string      `json:"parent_hash,omitempty"`
+	Timestamp    int64       `json:"timestamp"` // unix seconds
+	TxCount      *uint64     `json:"tx_count,omitempty"`
+	Producer     string      `json:"producer,omitempty"`
+	GasUsed      *uint64     `json:"gas_used,omitempty"`
+	GasLimit     *uint64     `json:"gas_limit,omitempty"`
+	Transactions interface{} `json:"transactions,omitempty"`
+	Raw          interface{} `json:"raw,omitempty"`
+
+	Finality *BlockFinality `json:"finality,omitempty"` // latest blocks only
+}
+
+// BlockFinality tells how final a latest block is. Heights has the head number of every commitment level
+// the upstream answered, Confirmations counts the blocks on top of the block up to the most recent head.
+type BlockFinality struct {
+	Commitment    string            `json:"commitment"`
+	Heights       map[string]uint64 `json:"heights"`
+	Confirmations uint64            `json:"confirmations"`
+}
+
+func (b *Block) WithTxCount(count uint64) *Block {
+	b.TxCount = &count
+	return b
+}
@@ -0,0 +1,59 @@
+package entity
+
+import (
+	"fmt"
+
+	"github.com/tikivn/ultrago/u_validator"
+)
+
+type Chain struct {
+	Base
+	Name           string            `json:"name" validate:"required"`
+	Endpoint       string            `json:"endpoint" validate:"required,url"`
+	Upstreams      []ChainUpstream   `json:"upstreams,omitempty" validate:"omitempty,dive"`
+	Methods        map[string]string `json:"methods" validate:"required"`
+	IsEVM          bool              `json:"is_evm"`
+	ChainID        uint64            `json:"chain_id,omitempty"`
+	Enabled        bool              `json:"enabled"`
+	DisplayName    string            `json:"display_name,omitempty"`
+	Family         string            `json:"family,omitempty" validate:"omitempty,oneof=evm solana near aptos sui tron utxo"`
+	NativeCurrency *NativeCurrency   `json:"native_currency,omitempty"`
+	Operations     []string          `json:"operations,omitempty"`
+}
+
+type NativeCurrency struct {
+	Name     string `json:"name"`
+	Symbol   string `json:"symbol"`
+	Decimals int    `json:"decimals"`
+}
+
+// ChainUpstream is a weighted rpc endpoint, calls of a chain are balanced over its upstreams.
+type ChainUpstream struct {
+	Endpoint string `json:"endpoint" validate:"required,url"`
+	Weight   int    `json:"weight" validate:"required,gt=0"`
+}
+
+func (c *Chain) Validate() error {
+	if err := u_validator.Struct(c); err != nil {
+		return err
+	}
+
+	if _, ok := c.Methods["getBlockByNumber"]; !ok {
+		return fmt.Errorf("missing method getBlockByNumber of chain %v", c.Name)
+	}
+
+	// chain id is verified against every upstream, so the method must exist
+	if _, ok := c.Methods["chainId"]; c.ChainID > 0 && !ok {
+		return fmt.Errorf("missing method chainId of chain %v", c.Name)
+	}
+
+	return nil
+}
+
+// GetUpstreams falls back to the single endpoint when no upstream is configured.
+func (c *Chain) GetUpstreams() []ChainUpstream {
+	if len(c.Upstreams) > 0 {
+		return c.Upstreams
+	}
+	return []ChainUpstream{{Endpoint: c.Endpoint, Weight: 1}}
+}
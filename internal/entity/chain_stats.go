@@ -0,0 +1,34 @@
+package entity
+
+// ChainStats is the activity of a chain over a rolling window, computed from the blocks sampled by the stats tracker.
+// Metrics a chain has no data for, such as gas of non EVM chains, are omitted.
+type ChainStats struct {
+	Chain          string   `json:"chain"`
+	Window         int64    `json:"window"` // seconds
+	FromBlock      uint64   `json:"from_block"`
+	ToBlock        uint64   `json:"to_block"`
+	SampledBlocks  int      `json:"sampled_blocks"`
+	AvgBlockTime   *float64 `json:"avg_block_time,omitempty"` // seconds
+	TPS            *float64 `json:"tps,omitempty"`
+	GasUtilization *float64 `json:"gas_utilization,omitempty"` // gas used over gas limit, 0 to 1
+	MedianTxCount  *uint64  `json:"median_tx_count,omitempty"`
+	UpdatedAt      int64    `json:"updated_at"` // unix seconds of the last sampled block
+}
+
+// BlockSample is the part of a block kept by the stats tracker
+type BlockSample struct {
+	Number    uint64  `json:"number"`
+	Timestamp int64   `json:"timestamp"`
+	TxCount   *uint64 `json:"tx_count,omitempty"`
+	GasUsed   *uint64 `json:"gas_used,omitempty"`
+	GasLimit  *uint64 `json:"gas_limit,omitempty"`
+}
+
+func (s *BlockSample) FromEntity(block *Block) *BlockSample {
+	s.Number = block.Number
+	s.Timestamp = block.Timestamp
+	s.TxCount = block.TxCount
+	s.GasUsed = block.GasUsed
+	s.GasLimit = block.GasLimit
+	return s
+}
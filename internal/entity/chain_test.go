@@ -0,0 +1,73 @@
+package entity
+
+import (
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestChainEntity(t *testing.T) {
+	convey.Convey("TestChainEntity", t, func() {
+
+		convey.Convey("Invalid endpoint", func() {
+			chain := &Chain{
+				Name:     "bsc",
+				Endpoint: "bsc-mainnet",
+				Methods: map[string]string{
+					"getBlockByNumber": "eth_getBlockByNumber",
+				},
+				IsEVM:   true,
+				Enabled: true,
+			}
+
+			err := chain.Validate()
+			convey.So(err, convey.ShouldNotBeNil)
+		})
+
+		convey.Convey("Missing method getBlockByNumber", func() {
+			chain := &Chain{
+				Name:     "bsc",
+				Endpoint: "https://bsc-dataseed.binance.org",
+				Methods: map[string]string{
+					"getTransactionReceipt": "eth_getTransactionReceipt",
+				},
+				IsEVM:   true,
+				Enabled: true,
+			}
+
+			err := chain.Validate()
+			convey.So(err, convey.ShouldNotBeNil)
+		})
+
+		convey.Convey("Missing method chainId of chain with chain id", func() {
+			chain := &Chain{
+				Name:     "base",
+				Endpoint: "https://mainnet.base.org",
+				Methods: map[string]string{
+					"getBlockByNumber": "eth_getBlockByNumber",
+				},
+				IsEVM:   true,
+				ChainID: 8453,
+				Enabled: true,
+			}
+
+			err := chain.Validate()
+			convey.So(err, convey.ShouldNotBeNil)
+		})
+
+		convey.Convey("Success case", func() {
+			chain := &Chain{
+				Name:     "bsc",
+				Endpoint: "https://bsc-dataseed.binance.org",
+				Methods: map[string]string{
+					"getBlockByNumber": "eth_getBlockByNumber",
+				},
+				IsEVM:   true,
+				Enabled: true,
+			}
+
+			err := chain.Validate()
+			convey.So(err, convey.ShouldBeNil)
+		})
+	})
+}
@@ -0,0 +1,21 @@
+package entity
+
+// GasEstimate suggests fees for a transaction to be included slowly, in the usual time or fast.
+// Fees are in the smallest unit of the chain, wei per gas on EVM chains and micro-lamports per compute unit on solana.
+type GasEstimate struct {
+	Chain       string `json:"chain"`
+	BlockNumber uint64 `json:"block_number,omitempty"` // latest block the estimate is based on, when known
+	Unit        string `json:"unit"`
+	Slow        GasFee `json:"slow"`
+	Standard    GasFee `json:"standard"`
+	Fast        GasFee `json:"fast"`
+}
+
+// GasFee is either an EIP-1559 fee with base fee, priority fee and max fee, the gas price of legacy chains,
+// or the priority fee alone on solana.
+type GasFee struct {
+	BaseFee     *uint64 `json:"base_fee,omitempty"`
+	PriorityFee *uint64 `json:"priority_fee,omitempty"`
+	MaxFee      *uint64 `json:"max_fee,omitempty"`
+	GasPrice    *uint64 `json:"gas_price,omitempty"`
+}
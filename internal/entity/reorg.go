@@ -0,0 +1,18 @@
+package entity
+
+// Reorg is a chain reorganization seen by the reorg tracker. Orphaned blocks were replaced by NewBlocks
+// at the same numbers, both branches share the block ForkNumber.
+type Reorg struct {
+	Chain          string       `json:"chain"`
+	DetectedAt     int64        `json:"detected_at"` // unix seconds
+	Depth          int          `json:"depth"`
+	ForkNumber     uint64       `json:"fork_number"`
+	OrphanedBlocks []ReorgBlock `json:"orphaned_blocks"`
+	NewBlocks      []ReorgBlock `json:"new_blocks"`
+	OrphanedTxs    []string     `json:"orphaned_txs,omitempty"`
+}
+
+type ReorgBlock struct {
+	Number uint64 `json:"number"`
+	Hash   string `json:"hash"`
+}
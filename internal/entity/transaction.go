@@ -0,0 +1,31 @@
+package entity
+
+// transaction statuses
+const (
+	TxStatusSuccess = "success"
+	TxStatusFailed  = "failed"
+	TxStatusPending = "pending"
+)
+
+// Transaction is the same view of a transaction on every chain, fields a chain does not answer are left empty.
+// Value and Fee are decimal strings in the smallest unit of the native currency (wei, lamports, yoctoNEAR).
+// Method summarizes what the transaction calls: the 4-byte selector of EVM contract calls, the instructions
+// of solana transactions or the actions of near receipts. Raw keeps the transaction as answered by the upstream.
+type Transaction struct {
+	Hash        string  `json:"hash"`
+	Chain       string  `json:"chain"`
+	BlockNumber *uint64 `json:"block_number,omitempty"`
+	BlockHash   string  `json:"block_hash,omitempty"`
+	Timestamp   int64   `json:"timestamp,omitempty"` // unix seconds
+	From        string  `json:"from,omitempty"`
+	To          string  `json:"to,omitempty"`
+	Value       string  `json:"value,omitempty"`
+	Fee         string  `json:"fee,omitempty"`
+	Status      string  `json:"status,omitempty"`
+	Method      string  `json:"method,omitempty"`
+	Input       string  `json:"input,omitempty"` // evm call data
+
+	DecodedInput *DecodedInput `json:"decoded_input,omitempty"`
+	Logs         []LogData     `json:"logs,omitempty"` // evm receipt logs
+	Raw          interface{}   `json:"raw,omitempty"`
+}
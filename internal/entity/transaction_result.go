@@ -3,6 +3,7 @@ package entity
 import (
 	"encoding/hex"
 	"encoding/json"
+	"sort"
 	"strconv"
 	"time"
 
@@ -12,6 +13,7 @@ import (
 
 type BlockResult struct {
 	Block           *types.Block                       `json:"block"`
+	Hash            string                             `json:"hash"` // answered by the node, the header type may miss fields of later forks
 	Transactions    []*TransactionResult               `json:"transactions"`
 	transactionMaps map[common.Hash]*TransactionResult `json:"-"`
 }
@@ -27,6 +29,9 @@ func (b *BlockResult) toTransactions() []*TransactionResult {
 	for _, transaction := range b.transactionMaps {
 		b.Transactions = append(b.Transactions, transaction)
 	}
+	sort.Slice(b.Transactions, func(i, j int) bool {
+		return b.Transactions[i].Index < b.Transactions[j].Index
+	})
 	return b.Transactions
 }
 
@@ -74,7 +79,9 @@ func (b *BlockRawLogs) FromEntity(block *types.Block) *BlockRawLogs {
 	b.ReceiptHash = block.ReceiptHash().Hex()
 	b.UncleHash = block.UncleHash().Hex()
 	b.ExtraData = string(block.Extra())
-	b.BaseFee = block.BaseFee().String()
+	if block.BaseFee() != nil {
+		b.BaseFee = block.BaseFee().String()
+	}
 	b.NumberOfTx = len(block.Transactions())
 	logsBloom, err := block.Bloom().MarshalText()
 	if err == nil {
@@ -183,15 +190,16 @@ func (r *ReceiptRawLogs) FromEntity(receipt *types.Receipt) *ReceiptRawLogs {
 }
 
 type LogData struct {
-	Address          string   `json:"address"`
-	Topics           []string `json:"topics"`
-	Data             string   `json:"data"`
-	BlockNumber      string   `json:"blockNumber"`
-	TransactionHash  string   `json:"transactionHash"`
-	TransactionIndex string   `json:"transactionIndex"`
-	BlockHash        string   `json:"blockHash"`
-	LogIndex         string   `json:"logIndex"`
-	Removed          bool     `json:"removed"`
+	Address          string      `json:"address"`
+	Topics           []string    `json:"topics"`
+	Data             string      `json:"data"`
+	BlockNumber      string      `json:"blockNumber"`
+	TransactionHash  string      `json:"transactionHash"`
+	TransactionIndex string      `json:"transactionIndex"`
+	BlockHash        string      `json:"blockHash"`
+	LogIndex         string      `json:"logIndex"`
+	Removed          bool        `json:"removed"`
+	Decoded          *DecodedLog `json:"decoded,omitempty"`
 }
 
 func (d *LogData) FromEntity(log *types.Log) *LogData {
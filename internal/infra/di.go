@@ -7,4 +7,5 @@ import (
 var GraphSet = wire.NewSet(
 	NewPostgresSession,
 	NewRedisClient,
+	NewRpcClientPool,
 )
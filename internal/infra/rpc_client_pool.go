@@ -0,0 +1,249 @@
+package infra
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	nearclient "github.com/eteu-technologies/near-api-go/pkg/client"
+	"github.com/ethereum/go-ethereum/rpc"
+	solanaclient "github.com/portto/solana-go-sdk/client"
+	solanarpc "github.com/portto/solana-go-sdk/rpc"
+	"github.com/tikivn/ultrago/u_logger"
+	"golang.org/x/sync/singleflight"
+
+	"nimbus-enhance-api/internal/conf"
+)
+
+// NewRpcClientPool keeps one long-lived client per endpoint on top of a shared keep-alive transport,
+// so requests to a node reuse connections instead of paying a TLS handshake each time.
+func NewRpcClientPool() (*RpcClientPool, func(), error) {
+	if conf.Config.RpcClientIdleTimeout <= 0 {
+		return nil, nil, fmt.Errorf("invalid RPC_CLIENT_IDLE_TIMEOUT %v: must be positive", conf.Config.RpcClientIdleTimeout)
+	}
+	pool := newRpcClientPool(
+		newRpcTransport(conf.Config.RpcMaxConnections, conf.Config.RpcClientIdleTimeout),
+		conf.Config.RpcClientIdleTimeout,
+	)
+	go pool.evictIdle()
+
+	cleanup := func() {
+		pool.Close()
+	}
+	return pool, cleanup, nil
+}
+
+func newRpcTransport(maxConnections int, idleTimeout time.Duration) *http.Transport {
+	return &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          maxConnections * 4,
+		MaxIdleConnsPerHost:   maxConnections,
+		MaxConnsPerHost:       maxConnections,
+		IdleConnTimeout:       idleTimeout,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+}
+
+func newRpcClientPool(transport *http.Transport, idleTimeout time.Duration) *RpcClientPool {
+	return &RpcClientPool{
+		transport:   transport,
+		httpClient:  &http.Client{Transport: transport},
+		idleTimeout: idleTimeout,
+		clients:     make(map[string]*pooledClient),
+		done:        make(chan struct{}),
+	}
+}
+
+type RpcClientPool struct {
+	sync.Mutex
+	transport   *http.Transport
+	httpClient  *http.Client
+	idleTimeout time.Duration
+	clients     map[string]*pooledClient // key is kind:endpoint
+	dials       singleflight.Group
+	done        chan struct{}
+	closeOnce   sync.Once
+}
+
+type pooledClient struct {
+	client   interface{}
+	close    func()
+	lastUsed time.Time
+	refs     int // callers holding the client, it is not evicted while in use
+}
+
+// GetRpcClient returns the shared json-rpc client of endpoint, callers must not close it
+// but call release once done, so websocket clients are not evicted while in use.
+func (p *RpcClientPool) GetRpcClient(ctx context.Context, endpoint string) (*rpc.Client, func(), error) {
+	client, release, err := p.get("rpc", endpoint, func() (interface{}, func(), error) {
+		u, err := url.Parse(endpoint)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		var client *rpc.Client
+		switch u.Scheme {
+		case "http", "https":
+			client, err = rpc.DialHTTPWithClient(endpoint, p.httpClient)
+		default:
+			client, err = rpc.DialContext(ctx, endpoint)
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		return client, client.Close, nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return client.(*rpc.Client), release, nil
+}
+
+// GetSolanaClient returns the shared solana client of endpoint. Solana and near clients only wrap the shared
+// http client and have nothing to close, so they are released at once.
+func (p *RpcClientPool) GetSolanaClient(endpoint string) *solanaclient.Client {
+	client, release, _ := p.get("solana", endpoint, func() (interface{}, func(), error) {
+		client := solanaclient.New(
+			solanarpc.WithEndpoint(endpoint),
+			solanarpc.WithHTTPClient(p.httpClient),
+		)
+		return client, func() {}, nil
+	})
+	release()
+	return client.(*solanaclient.Client)
+}
+
+func (p *RpcClientPool) GetNearClient(endpoint string) (*nearclient.Client, error) {
+	client, release, err := p.get("near", endpoint, func() (interface{}, func(), error) {
+		client, err := nearclient.NewClient(endpoint)
+		if err != nil {
+			return nil, nil, err
+		}
+		return &client, func() {}, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	release()
+	return client.(*nearclient.Client), nil
+}
+
+// GetHttpClient returns the shared http client for nodes serving rest api
+func (p *RpcClientPool) GetHttpClient() *http.Client {
+	return p.httpClient
+}
+
+// get dials outside of the pool lock, so a hanging dial only blocks callers of the same endpoint,
+// concurrent callers of an endpoint share one dial.
+func (p *RpcClientPool) get(kind string, endpoint string, dial func() (interface{}, func(), error)) (interface{}, func(), error) {
+	key := kind + ":" + endpoint
+	for {
+		if client, release, ok := p.acquire(key); ok {
+			return client, release, nil
+		}
+
+		_, err, _ := p.dials.Do(key, func() (interface{}, error) {
+			client, close, err := dial()
+			if err != nil {
+				return nil, err
+			}
+
+			p.Lock()
+			defer p.Unlock()
+			if _, ok := p.clients[key]; ok {
+				close()
+				return nil, nil
+			}
+			p.clients[key] = &pooledClient{
+				client:   client,
+				close:    close,
+				lastUsed: time.Now(),
+			}
+			return nil, nil
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+}
+
+// acquire takes a reference on the pooled client of key, false when it is not pooled yet
+func (p *RpcClientPool) acquire(key string) (interface{}, func(), bool) {
+	p.Lock()
+	defer p.Unlock()
+	item, ok := p.clients[key]
+	if !ok {
+		return nil, nil, false
+	}
+	item.refs++
+	item.lastUsed = time.Now()
+
+	var once sync.Once
+	release := func() {
+		once.Do(func() {
+			p.Lock()
+			defer p.Unlock()
+			item.refs--
+			item.lastUsed = time.Now()
+		})
+	}
+	return item.client, release, true
+}
+
+// evictIdle closes clients not used for idleTimeout and not held by any caller until the pool is closed.
+// Idle connections are left to the IdleConnTimeout of the transport, they are shared with the clients still pooled.
+func (p *RpcClientPool) evictIdle() {
+	ticker := time.NewTicker(p.idleTimeout / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.done:
+			return
+		case <-ticker.C:
+			p.evict(time.Now().Add(-p.idleTimeout))
+		}
+	}
+}
+
+func (p *RpcClientPool) evict(before time.Time) {
+	p.Lock()
+	defer p.Unlock()
+	for key, item := range p.clients {
+		if item.refs == 0 && item.lastUsed.Before(before) {
+			item.close()
+			delete(p.clients, key)
+		}
+	}
+}
+
+func (p *RpcClientPool) Size() int {
+	p.Lock()
+	defer p.Unlock()
+	return len(p.clients)
+}
+
+func (p *RpcClientPool) Close() {
+	p.closeOnce.Do(func() {
+		logger := u_logger.NewLogger()
+		logger.Infof("close rpc client pool")
+		close(p.done)
+
+		p.Lock()
+		defer p.Unlock()
+		for key, item := range p.clients {
+			item.close()
+			delete(p.clients, key)
+		}
+		p.transport.CloseIdleConnections()
+	})
+}
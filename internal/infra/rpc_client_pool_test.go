@@ -0,0 +1,193 @@
+package infra
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/smartystreets/goconvey/convey"
+
+	"nimbus-enhance-api/internal/conf"
+)
+
+func serveTestRpc(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":"0x1"}`))
+}
+
+func newTestRpcServer() *httptest.Server {
+	return httptest.NewTLSServer(http.HandlerFunc(serveTestRpc))
+}
+
+func newTestTransport() *http.Transport {
+	transport := newRpcTransport(32, time.Minute)
+	transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	return transport
+}
+
+func TestRpcClientPool(t *testing.T) {
+	convey.Convey("TestRpcClientPool", t, func() {
+		server := newTestRpcServer()
+		defer server.Close()
+
+		pool := newRpcClientPool(newTestTransport(), time.Minute)
+		defer pool.Close()
+
+		convey.Convey("Reuse client of the same endpoint", func() {
+			client1, release1, err := pool.GetRpcClient(context.Background(), server.URL)
+			convey.So(err, convey.ShouldBeNil)
+			defer release1()
+			client2, release2, err := pool.GetRpcClient(context.Background(), server.URL)
+			convey.So(err, convey.ShouldBeNil)
+			defer release2()
+			convey.So(client1, convey.ShouldEqual, client2)
+			convey.So(pool.GetSolanaClient(server.URL), convey.ShouldNotBeNil)
+			convey.So(pool.Size(), convey.ShouldEqual, 2)
+
+			var res string
+			err = client1.CallContext(context.Background(), &res, "eth_blockNumber")
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(res, convey.ShouldEqual, "0x1")
+		})
+
+		convey.Convey("Evict idle clients", func() {
+			_, release, err := pool.GetRpcClient(context.Background(), server.URL)
+			convey.So(err, convey.ShouldBeNil)
+
+			// clients in use are kept however long ago they were taken
+			pool.evict(time.Now().Add(time.Second))
+			convey.So(pool.Size(), convey.ShouldEqual, 1)
+
+			release()
+			release()
+			pool.evict(time.Now().Add(-time.Minute))
+			convey.So(pool.Size(), convey.ShouldEqual, 1)
+
+			pool.evict(time.Now().Add(time.Second))
+			convey.So(pool.Size(), convey.ShouldEqual, 0)
+		})
+
+		convey.Convey("Eviction keeps the connections of pooled clients", func() {
+			var conns int32
+			server := httptest.NewUnstartedServer(http.HandlerFunc(serveTestRpc))
+			server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+				if state == http.StateNew {
+					atomic.AddInt32(&conns, 1)
+				}
+			}
+			server.StartTLS()
+			defer server.Close()
+
+			call := func() {
+				client, release, err := pool.GetRpcClient(context.Background(), server.URL)
+				convey.So(err, convey.ShouldBeNil)
+				defer release()
+				var res string
+				convey.So(client.CallContext(context.Background(), &res, "eth_blockNumber"), convey.ShouldBeNil)
+			}
+			call()
+			pool.evict(time.Now().Add(-time.Minute))
+			call()
+			convey.So(atomic.LoadInt32(&conns), convey.ShouldEqual, 1)
+		})
+
+		convey.Convey("Hanging dial does not block other endpoints", func() {
+			var (
+				dialing = make(chan struct{})
+				unblock = make(chan struct{})
+				dials   int32
+				done    = make(chan error, 2)
+			)
+			slowDial := func() (interface{}, func(), error) {
+				atomic.AddInt32(&dials, 1)
+				close(dialing)
+				<-unblock
+				return "slow", func() {}, nil
+			}
+			go func() {
+				_, _, err := pool.get("rpc", "wss://slow", slowDial)
+				done <- err
+			}()
+			<-dialing
+
+			// a second caller of the same endpoint waits for the dial running
+			go func() {
+				_, _, err := pool.get("rpc", "wss://slow", slowDial)
+				done <- err
+			}()
+
+			client, release, err := pool.GetRpcClient(context.Background(), server.URL)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(client, convey.ShouldNotBeNil)
+			release()
+
+			close(unblock)
+			convey.So(<-done, convey.ShouldBeNil)
+			convey.So(<-done, convey.ShouldBeNil)
+			convey.So(atomic.LoadInt32(&dials), convey.ShouldEqual, 1)
+			convey.So(pool.Size(), convey.ShouldEqual, 2)
+		})
+	})
+}
+
+func TestNewRpcClientPool(t *testing.T) {
+	convey.Convey("TestNewRpcClientPool", t, func() {
+		idleTimeout := conf.Config.RpcClientIdleTimeout
+		defer func() { conf.Config.RpcClientIdleTimeout = idleTimeout }()
+
+		// a ticker of zero period panics, the eviction loop needs a positive timeout
+		conf.Config.RpcClientIdleTimeout = 0
+		_, _, err := NewRpcClientPool()
+		convey.So(err, convey.ShouldNotBeNil)
+	})
+}
+
+// BenchmarkRpcClient compares dialing a client per call, as adapters did before, with the pooled client.
+func BenchmarkRpcClient(b *testing.B) {
+	server := newTestRpcServer()
+	defer server.Close()
+
+	b.Run("dial per call", func(b *testing.B) {
+		// rpc.DialContext uses the default transport, which keeps only 2 idle connections per host
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+		defer transport.CloseIdleConnections()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				client, err := rpc.DialHTTPWithClient(server.URL, &http.Client{Transport: transport})
+				if err != nil {
+					b.Fatal(err)
+				}
+				var res string
+				if err := client.CallContext(context.Background(), &res, "eth_blockNumber"); err != nil {
+					b.Fatal(err)
+				}
+				client.Close()
+			}
+		})
+	})
+
+	b.Run("pooled", func(b *testing.B) {
+		pool := newRpcClientPool(newTestTransport(), time.Minute)
+		defer pool.Close()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				client, release, err := pool.GetRpcClient(context.Background(), server.URL)
+				if err != nil {
+					b.Fatal(err)
+				}
+				var res string
+				if err := client.CallContext(context.Background(), &res, "eth_blockNumber"); err != nil {
+					b.Fatal(err)
+				}
+				release()
+			}
+		})
+	})
+}
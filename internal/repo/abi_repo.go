@@ -0,0 +1,25 @@
+package repo
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+
+	"nimbus-enhance-api/internal/entity"
+	"nimbus-enhance-api/internal/repo/gorm_scope"
+)
+
+type FunctionSignatureRepo interface {
+	S() *gorm_scope.FunctionSignatureScope
+	GetList(ctx context.Context, scopes ...func(db *gorm.DB) *gorm.DB) ([]*entity.FunctionSignature, error)
+	// CreateBatch skips signatures already stored
+	CreateBatch(ctx context.Context, entities []*entity.FunctionSignature) error
+}
+
+type ContractAbiRepo interface {
+	S() *gorm_scope.ContractAbiScope
+	GetOne(ctx context.Context, scopes ...func(db *gorm.DB) *gorm.DB) (*entity.ContractAbi, error)
+	GetList(ctx context.Context, scopes ...func(db *gorm.DB) *gorm.DB) ([]*entity.ContractAbi, error)
+	// Save replaces the abi of the contract when one is stored
+	Save(ctx context.Context, entity *entity.ContractAbi) error
+}
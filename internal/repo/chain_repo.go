@@ -0,0 +1,18 @@
+package repo
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+
+	"nimbus-enhance-api/internal/entity"
+	"nimbus-enhance-api/internal/repo/gorm_scope"
+)
+
+type ChainRepo interface {
+	S() *gorm_scope.ChainScope
+	GetOne(ctx context.Context, scopes ...func(db *gorm.DB) *gorm.DB) (*entity.Chain, error)
+	GetList(ctx context.Context, scopes ...func(db *gorm.DB) *gorm.DB) ([]*entity.Chain, error)
+	Create(ctx context.Context, entity *entity.Chain) error
+	Update(ctx context.Context, entity *entity.Chain) error
+}
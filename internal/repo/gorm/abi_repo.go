@@ -0,0 +1,213 @@
+package gorm
+
+import (
+	"context"
+	"encoding/json"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"nimbus-enhance-api/internal/entity"
+	"nimbus-enhance-api/internal/repo"
+	"nimbus-enhance-api/internal/repo/gorm_scope"
+	"nimbus-enhance-api/internal/setting"
+)
+
+// functionSignatureBatchSize bounds the rows of one insert of the signature database
+const functionSignatureBatchSize = 500
+
+func NewFunctionSignatureRepo(
+	baseRepo *baseRepo,
+	s *gorm_scope.FunctionSignatureScope,
+) repo.FunctionSignatureRepo {
+	return &functionSignatureRepo{
+		baseRepo: baseRepo,
+		s:        s,
+	}
+}
+
+type functionSignatureRepo struct {
+	*baseRepo
+	s *gorm_scope.FunctionSignatureScope
+}
+
+func (repo *functionSignatureRepo) S() *gorm_scope.FunctionSignatureScope {
+	return repo.s
+}
+
+func (repo *functionSignatureRepo) GetList(ctx context.Context, scopes ...func(db *gorm.DB) *gorm.DB) ([]*entity.FunctionSignature, error) {
+	if len(scopes) == 0 {
+		return nil, setting.MissingConditionErr
+	}
+	db, cancel := repo.WithTimeoutCtx(ctx)
+	defer cancel()
+
+	var rows []*FunctionSignatureDao
+	q := db.Model(&FunctionSignatureDao{}).
+		Scopes(scopes...).
+		Find(&rows)
+	if err := q.Error; err != nil {
+		return nil, err
+	}
+
+	res := make([]*entity.FunctionSignature, 0, q.RowsAffected)
+	for _, row := range rows {
+		res = append(res, row.toStruct())
+	}
+	return res, nil
+}
+
+func (repo *functionSignatureRepo) CreateBatch(ctx context.Context, entities []*entity.FunctionSignature) error {
+	if len(entities) == 0 {
+		return nil
+	}
+	rows := make([]*FunctionSignatureDao, 0, len(entities))
+	for _, item := range entities {
+		rows = append(rows, new(FunctionSignatureDao).fromStruct(item))
+	}
+	db, cancel := repo.WithTimeoutCtx(ctx)
+	defer cancel()
+	return createFunctionSignatures(db, rows)
+}
+
+// createFunctionSignatures is shared with the migration, which loads the bundled signatures without repo
+func createFunctionSignatures(db *gorm.DB, rows []*FunctionSignatureDao) error {
+	q := db.Clauses(clause.OnConflict{DoNothing: true}).
+		CreateInBatches(rows, functionSignatureBatchSize)
+	return q.Error
+}
+
+type FunctionSignatureDao struct {
+	BaseDao
+	Selector  string `gorm:"column:selector;type:varchar(10);not null;index:idx_function_signature_selector;uniqueIndex:idx_function_signature"`
+	Signature string `gorm:"column:signature;type:text;not null;uniqueIndex:idx_function_signature"`
+}
+
+func (dao *FunctionSignatureDao) TableName() string {
+	return "function_signatures"
+}
+
+func (dao *FunctionSignatureDao) fromStruct(item *entity.FunctionSignature) *FunctionSignatureDao {
+	dao.BaseDao = *new(BaseDao).fromEntity(&item.Base)
+	dao.Selector = item.Selector
+	dao.Signature = item.Signature
+	return dao
+}
+
+func (dao *FunctionSignatureDao) toStruct() *entity.FunctionSignature {
+	return &entity.FunctionSignature{
+		Base:      *dao.BaseDao.toEntity(),
+		Selector:  dao.Selector,
+		Signature: dao.Signature,
+	}
+}
+
+func NewContractAbiRepo(
+	baseRepo *baseRepo,
+	s *gorm_scope.ContractAbiScope,
+) repo.ContractAbiRepo {
+	return &contractAbiRepo{
+		baseRepo: baseRepo,
+		s:        s,
+	}
+}
+
+type contractAbiRepo struct {
+	*baseRepo
+	s *gorm_scope.ContractAbiScope
+}
+
+func (repo *contractAbiRepo) S() *gorm_scope.ContractAbiScope {
+	return repo.s
+}
+
+func (repo *contractAbiRepo) GetOne(ctx context.Context, scopes ...func(db *gorm.DB) *gorm.DB) (*entity.ContractAbi, error) {
+	if len(scopes) == 0 {
+		return nil, setting.MissingConditionErr
+	}
+	db, cancel := repo.WithTimeoutCtx(ctx)
+	defer cancel()
+
+	var row ContractAbiDao
+	q := db.Model(&ContractAbiDao{}).
+		Scopes(scopes...).
+		First(&row)
+	if err := q.Error; err != nil {
+		return nil, err
+	}
+	return row.toStruct(), nil
+}
+
+func (repo *contractAbiRepo) GetList(ctx context.Context, scopes ...func(db *gorm.DB) *gorm.DB) ([]*entity.ContractAbi, error) {
+	if len(scopes) == 0 {
+		return nil, setting.MissingConditionErr
+	}
+	db, cancel := repo.WithTimeoutCtx(ctx)
+	defer cancel()
+
+	var rows []*ContractAbiDao
+	q := db.Model(&ContractAbiDao{}).
+		Scopes(scopes...).
+		Find(&rows)
+	if err := q.Error; err != nil {
+		return nil, err
+	}
+
+	res := make([]*entity.ContractAbi, 0, q.RowsAffected)
+	for _, row := range rows {
+		res = append(res, row.toStruct())
+	}
+	return res, nil
+}
+
+func (repo *contractAbiRepo) Save(ctx context.Context, entity *entity.ContractAbi) error {
+	row := new(ContractAbiDao).fromStruct(entity)
+	db, cancel := repo.WithTimeoutCtx(ctx)
+	defer cancel()
+
+	q := db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "chain"}, {Name: "address"}},
+		DoUpdates: clause.AssignmentColumns([]string{"abi", "updated_at"}),
+	}).Create(&row)
+	if q.Error != nil {
+		return q.Error
+	}
+
+	// a replaced abi keeps the id of the stored row
+	var saved ContractAbiDao
+	q = db.Model(&ContractAbiDao{}).
+		Scopes(repo.s.FilterChain(row.Chain), repo.s.FilterAddress(row.Address)).
+		First(&saved)
+	if q.Error == nil {
+		entity.Base = *saved.BaseDao.toEntity()
+	}
+	return q.Error
+}
+
+type ContractAbiDao struct {
+	BaseDao
+	Chain   string `gorm:"column:chain;type:varchar(50);not null;uniqueIndex:idx_contract_abi"`
+	Address string `gorm:"column:address;type:varchar(42);not null;uniqueIndex:idx_contract_abi"`
+	Abi     string `gorm:"column:abi;type:jsonb;not null"`
+}
+
+func (dao *ContractAbiDao) TableName() string {
+	return "contract_abis"
+}
+
+func (dao *ContractAbiDao) fromStruct(item *entity.ContractAbi) *ContractAbiDao {
+	dao.BaseDao = *new(BaseDao).fromEntity(&item.Base)
+	dao.Chain = item.Chain
+	dao.Address = item.Address
+	dao.Abi = string(item.Abi)
+	return dao
+}
+
+func (dao *ContractAbiDao) toStruct() *entity.ContractAbi {
+	return &entity.ContractAbi{
+		Base:    *dao.BaseDao.toEntity(),
+		Chain:   dao.Chain,
+		Address: dao.Address,
+		Abi:     json.RawMessage(dao.Abi),
+	}
+}
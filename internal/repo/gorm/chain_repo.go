@@ -0,0 +1,158 @@
+package gorm
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+
+	"nimbus-enhance-api/internal/entity"
+	"nimbus-enhance-api/internal/repo"
+	"nimbus-enhance-api/internal/repo/gorm_scope"
+	"nimbus-enhance-api/internal/setting"
+)
+
+func NewChainRepo(
+	baseRepo *baseRepo,
+	s *gorm_scope.ChainScope,
+) repo.ChainRepo {
+	return &chainRepo{
+		baseRepo: baseRepo,
+		s:        s,
+	}
+}
+
+type chainRepo struct {
+	*baseRepo
+	s *gorm_scope.ChainScope
+}
+
+func (repo *chainRepo) S() *gorm_scope.ChainScope {
+	return repo.s
+}
+
+func (repo *chainRepo) GetOne(ctx context.Context, scopes ...func(db *gorm.DB) *gorm.DB) (*entity.Chain, error) {
+	if len(scopes) == 0 {
+		return nil, setting.MissingConditionErr
+	}
+	db, cancel := repo.WithTimeoutCtx(ctx)
+	defer cancel()
+
+	var row ChainDao
+	q := db.Model(&ChainDao{}).
+		Scopes(scopes...).
+		First(&row)
+	if err := q.Error; err != nil {
+		return nil, err
+	}
+	return row.toStruct()
+}
+
+func (repo *chainRepo) GetList(ctx context.Context, scopes ...func(db *gorm.DB) *gorm.DB) ([]*entity.Chain, error) {
+	if len(scopes) == 0 {
+		return nil, setting.MissingConditionErr
+	}
+	db, cancel := repo.WithTimeoutCtx(ctx)
+	defer cancel()
+
+	var rows []*ChainDao
+	q := db.Model(&ChainDao{}).
+		Scopes(scopes...).
+		Find(&rows)
+	if err := q.Error; err != nil {
+		return nil, err
+	}
+
+	res := make([]*entity.Chain, 0, q.RowsAffected)
+	for _, row := range rows {
+		item, err := row.toStruct()
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, item)
+	}
+	return res, nil
+}
+
+func (repo *chainRepo) Create(ctx context.Context, entity *entity.Chain) error {
+	row, err := new(ChainDao).fromStruct(entity)
+	if err != nil {
+		return err
+	}
+	db, cancel := repo.WithTimeoutCtx(ctx)
+	defer cancel()
+
+	q := db.Create(&row)
+	if q.Error == nil {
+		entity.Base = *row.BaseDao.toEntity()
+	}
+	return q.Error
+}
+
+// Update writes every column of the chain, so zero values such as a disabled flag are persisted as well.
+func (repo *chainRepo) Update(ctx context.Context, entity *entity.Chain) error {
+	row, err := new(ChainDao).fromStruct(entity)
+	if err != nil {
+		return err
+	}
+	db, cancel := repo.WithTimeoutCtx(ctx)
+	defer cancel()
+
+	q := db.Model(row).
+		Select("*").
+		Omit("id", "created_at").
+		Updates(row)
+	return q.Error
+}
+
+type ChainDao struct {
+	BaseDao
+	Name           string                 `gorm:"column:name;type:varchar(50);not null;uniqueIndex:idx_chain_name"`
+	Endpoint       string                 `gorm:"column:endpoint;type:text;not null"`
+	Upstreams      []entity.ChainUpstream `gorm:"column:upstreams;type:jsonb;serializer:json"`
+	Methods        map[string]string      `gorm:"column:methods;type:jsonb;not null;serializer:json"`
+	IsEVM          bool                   `gorm:"column:is_evm;type:boolean;not null"`
+	ChainID        uint64                 `gorm:"column:chain_id;type:bigint"`
+	Enabled        bool                   `gorm:"column:enabled;type:boolean;not null"`
+	DisplayName    string                 `gorm:"column:display_name;type:varchar(100)"`
+	Family         string                 `gorm:"column:family;type:varchar(20)"`
+	NativeCurrency *entity.NativeCurrency `gorm:"column:native_currency;type:jsonb;serializer:json"`
+	Operations     []string               `gorm:"column:operations;type:jsonb;serializer:json"`
+}
+
+func (dao *ChainDao) TableName() string {
+	return "chains"
+}
+
+func (dao *ChainDao) fromStruct(item *entity.Chain) (*ChainDao, error) {
+	dao.BaseDao = *new(BaseDao).fromEntity(&item.Base)
+	dao.Name = item.Name
+	dao.Endpoint = item.Endpoint
+	dao.Upstreams = item.Upstreams
+	dao.Methods = item.Methods
+	dao.IsEVM = item.IsEVM
+	dao.ChainID = item.ChainID
+	dao.DisplayName = item.DisplayName
+	dao.Family = item.Family
+	dao.NativeCurrency = item.NativeCurrency
+	dao.Operations = item.Operations
+	dao.Enabled = item.Enabled
+
+	return dao, nil
+}
+
+func (dao *ChainDao) toStruct() (*entity.Chain, error) {
+	return &entity.Chain{
+		Base:           *dao.BaseDao.toEntity(),
+		Name:           dao.Name,
+		Endpoint:       dao.Endpoint,
+		Upstreams:      dao.Upstreams,
+		Methods:        dao.Methods,
+		IsEVM:          dao.IsEVM,
+		ChainID:        dao.ChainID,
+		Enabled:        dao.Enabled,
+		DisplayName:    dao.DisplayName,
+		Family:         dao.Family,
+		NativeCurrency: dao.NativeCurrency,
+		Operations:     dao.Operations,
+	}, nil
+}
@@ -8,4 +8,7 @@ var GraphSet = wire.NewSet(
 	NewBaseRepo,
 	NewTransactor,
 	NewDailyMetaRepo,
+	NewChainRepo,
+	NewFunctionSignatureRepo,
+	NewContractAbiRepo,
 )
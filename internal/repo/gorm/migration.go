@@ -1,10 +1,15 @@
 package gorm
 
 import (
+	"bufio"
 	"context"
+	"fmt"
+	"os"
+	"strings"
 	"time"
 
 	"github.com/tikivn/ultrago/u_logger"
+	"gorm.io/gorm"
 
 	"nimbus-enhance-api/internal/conf"
 	"nimbus-enhance-api/internal/infra"
@@ -33,10 +38,60 @@ func RunMigration(ctx context.Context) error {
 
 	err = db.WithContext(ctx).AutoMigrate(
 		&DailyMetaDao{},
+		&ChainDao{},
+		&FunctionSignatureDao{},
+		&ContractAbiDao{},
 	)
 	if err != nil {
 		logger.Fatal(err)
 		return err
 	}
+
+	if err := loadFunctionSignatures(ctx, db, conf.Config.FunctionSignatureFile); err != nil {
+		logger.Errorf("failed to load function signatures: %v", err)
+		return err
+	}
+	return nil
+}
+
+// loadFunctionSignatures inserts the selectors of the bundled signature file, one tab separated selector and
+// signature per line, lines starting with # are comments. Signatures already stored are skipped.
+func loadFunctionSignatures(ctx context.Context, db *gorm.DB, path string) error {
+	ctx, logger := u_logger.GetLogger(ctx)
+	if path == "" {
+		return nil
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var (
+		rows    []*FunctionSignatureDao
+		scanner = bufio.NewScanner(file)
+	)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.SplitN(text, "\t", 2)
+		if len(fields) != 2 || len(fields[0]) != 10 || !strings.HasPrefix(fields[0], "0x") {
+			return fmt.Errorf("invalid function signature at line %d of %s", line, path)
+		}
+		rows = append(rows, &FunctionSignatureDao{
+			Selector:  strings.ToLower(fields[0]),
+			Signature: strings.TrimSpace(fields[1]),
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	if err := createFunctionSignatures(db.WithContext(ctx), rows); err != nil {
+		return err
+	}
+	logger.Infof("loaded %d function signatures from %s", len(rows), path)
 	return nil
 }
@@ -0,0 +1,45 @@
+package gorm_scope
+
+import (
+	"gorm.io/gorm"
+)
+
+type FunctionSignatureScope struct {
+	*base
+}
+
+func NewFunctionSignature(b *base) *FunctionSignatureScope {
+	return &FunctionSignatureScope{base: b}
+}
+
+func (s *FunctionSignatureScope) FilterSelector(selector string) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("selector = ?", selector)
+	}
+}
+
+type ContractAbiScope struct {
+	*base
+}
+
+func NewContractAbi(b *base) *ContractAbiScope {
+	return &ContractAbiScope{base: b}
+}
+
+func (s *ContractAbiScope) FilterChain(chain string) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("chain = ?", chain)
+	}
+}
+
+func (s *ContractAbiScope) FilterAddress(address string) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("address = ?", address)
+	}
+}
+
+func (s *ContractAbiScope) FilterAddresses(addresses []string) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("address IN ?", addresses)
+	}
+}
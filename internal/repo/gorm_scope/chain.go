@@ -0,0 +1,25 @@
+package gorm_scope
+
+import (
+	"gorm.io/gorm"
+)
+
+type ChainScope struct {
+	*base
+}
+
+func NewChain(b *base) *ChainScope {
+	return &ChainScope{base: b}
+}
+
+func (s *ChainScope) FilterName(name string) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("name = ?", name)
+	}
+}
+
+func (s *ChainScope) FilterEnabled(enabled bool) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("enabled = ?", enabled)
+	}
+}
@@ -7,4 +7,7 @@ import (
 var GraphSet = wire.NewSet(
 	NewBase,
 	NewDailyMeta,
+	NewChain,
+	NewFunctionSignature,
+	NewContractAbi,
 )
@@ -0,0 +1,536 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/tikivn/ultrago/u_logger"
+	"gorm.io/gorm"
+
+	"nimbus-enhance-api/internal/entity"
+	"nimbus-enhance-api/internal/infra"
+	"nimbus-enhance-api/internal/repo"
+	"nimbus-enhance-api/internal/repo/redis"
+	"nimbus-enhance-api/internal/setting"
+)
+
+// contractAbiCacheTTL bounds how long a lookup racing an upload may keep the abi replaced by the upload
+const contractAbiCacheTTL = 5 * time.Minute
+
+func NewAbiDecoder(
+	redisClient *infra.RedisClient,
+	chainRegistry ChainRegistry,
+	functionSignatureRepo repo.FunctionSignatureRepo,
+	contractAbiRepo repo.ContractAbiRepo,
+) AbiDecoder {
+	return &abiDecoder{
+		chainRegistry:         chainRegistry,
+		functionSignatureRepo: functionSignatureRepo,
+		contractAbiRepo:       contractAbiRepo,
+		redisRepo:             redis.NewRedisRepo(redisClient, "abi", time.Hour),
+	}
+}
+
+// AbiDecoder decodes evm call data with the abi uploaded for the contract, or with every signature
+// of the selector in the signature database loaded from conf FUNCTION_SIGNATURE_FILE.
+type AbiDecoder interface {
+	SaveContractAbi(ctx context.Context, item *entity.ContractAbi) error
+	GetContractAbi(ctx context.Context, chain Chain, address string) (*entity.ContractAbi, error)
+	// DecodeInput returns nil for inputs without selector or selectors nobody knows
+	DecodeInput(ctx context.Context, chain Chain, address, input string) (*entity.DecodedInput, error)
+	// DecodeLogs sets Decoded on the logs of known events, other logs are left as is.
+	// When the abis fail to load, the logs are still decoded with the built-in events and the error is returned.
+	DecodeLogs(ctx context.Context, chain Chain, logs []*entity.LogData) error
+}
+
+type abiDecoder struct {
+	chainRegistry         ChainRegistry
+	functionSignatureRepo repo.FunctionSignatureRepo
+	contractAbiRepo       repo.ContractAbiRepo
+	redisRepo             repo.RedisRepo // signatures of selectors and contract abis, uploads invalidate the abi of the contract
+}
+
+func (d *abiDecoder) SaveContractAbi(ctx context.Context, item *entity.ContractAbi) error {
+	if _, ok := d.chainRegistry.Get(Chain(item.Chain)); !ok {
+		return fmt.Errorf("%w: %s", setting.ErrNotSupportedChain, item.Chain)
+	}
+	if err := item.Validate(); err != nil {
+		return fmt.Errorf("%w: %v", setting.ErrInvalidAbi, err)
+	}
+	if _, err := abi.JSON(bytes.NewReader(item.Abi)); err != nil {
+		return fmt.Errorf("%w: %v", setting.ErrInvalidAbi, err)
+	}
+	if err := d.contractAbiRepo.Save(ctx, item); err != nil {
+		return err
+	}
+	cacheKey := contractAbiCacheKey(Chain(item.Chain), item.Address)
+	if err := d.redisRepo.Invalidate(ctx, cacheKey); err != nil {
+		_, logger := u_logger.GetLogger(ctx)
+		logger.Warnf("failed to invalidate cache %s: %v", cacheKey, err)
+	}
+	return nil
+}
+
+func (d *abiDecoder) GetContractAbi(ctx context.Context, chain Chain, address string) (*entity.ContractAbi, error) {
+	res, err := d.contractAbiRepo.GetOne(ctx,
+		d.contractAbiRepo.S().FilterChain(string(chain)),
+		d.contractAbiRepo.S().FilterAddress(strings.ToLower(address)),
+	)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("%w: %s of chain %s", setting.ErrAbiNotFound, address, chain)
+	}
+	return res, err
+}
+
+func (d *abiDecoder) DecodeInput(ctx context.Context, chain Chain, address, input string) (*entity.DecodedInput, error) {
+	data, err := hexutil.Decode(input)
+	if err != nil || len(data) < 4 {
+		return nil, nil
+	}
+	res := &entity.DecodedInput{Selector: hexutil.Encode(data[:4])}
+
+	contractAbi, err := d.getAbi(ctx, chain, address)
+	if err != nil {
+		return nil, err
+	}
+	if contractAbi != nil {
+		if method, err := contractAbi.MethodById(data[:4]); err == nil {
+			fn := entity.DecodedFunction{Name: method.RawName, Signature: method.Sig, Source: entity.AbiSourceContract}
+			if values, err := method.Inputs.UnpackValues(data[4:]); err == nil {
+				fn.Params = newDecodedParams(method.Inputs, values)
+			}
+			res.Functions = append(res.Functions, fn)
+			return res, nil
+		}
+	}
+
+	signatures, err := d.getSignatures(ctx, res.Selector)
+	if err != nil {
+		return nil, err
+	}
+	for _, signature := range signatures {
+		name, args, err := parseSignature(signature)
+		if err != nil {
+			continue
+		}
+		fn := entity.DecodedFunction{Name: name, Signature: signature, Source: entity.AbiSourceSignature}
+		if values, err := args.UnpackValues(data[4:]); err == nil {
+			fn.Params = newDecodedParams(args, values)
+		}
+		res.Functions = append(res.Functions, fn)
+	}
+	if len(res.Functions) == 0 {
+		return nil, nil
+	}
+	return res, nil
+}
+
+func (d *abiDecoder) DecodeLogs(ctx context.Context, chain Chain, logs []*entity.LogData) error {
+	// logs of a block come from few contracts, their abis are read at once
+	addresses := make([]string, 0, len(logs))
+	for _, log := range logs {
+		if len(log.Topics) > 0 {
+			addresses = append(addresses, log.Address)
+		}
+	}
+	contractAbis, err := d.getAbis(ctx, chain, addresses)
+
+	for _, log := range logs {
+		if len(log.Topics) == 0 {
+			// anonymous events have no topic to be matched by
+			continue
+		}
+		log.Decoded = decodeLog(contractAbis[strings.ToLower(log.Address)], log)
+	}
+	return err
+}
+
+// builtinEventAbis are the events decoded without uploaded abi: tokens, weth and the swaps of the common dexes.
+// Erc20 and erc721 share the signatures of Transfer and Approval, they are told apart by the count of indexed params.
+var builtinEventAbis = []string{
+	// erc20
+	`[
+		{"type":"event","name":"Transfer","inputs":[{"name":"from","type":"address","indexed":true},{"name":"to","type":"address","indexed":true},{"name":"value","type":"uint256"}]},
+		{"type":"event","name":"Approval","inputs":[{"name":"owner","type":"address","indexed":true},{"name":"spender","type":"address","indexed":true},{"name":"value","type":"uint256"}]}
+	]`,
+	// erc721
+	`[
+		{"type":"event","name":"Transfer","inputs":[{"name":"from","type":"address","indexed":true},{"name":"to","type":"address","indexed":true},{"name":"tokenId","type":"uint256","indexed":true}]},
+		{"type":"event","name":"Approval","inputs":[{"name":"owner","type":"address","indexed":true},{"name":"approved","type":"address","indexed":true},{"name":"tokenId","type":"uint256","indexed":true}]},
+		{"type":"event","name":"ApprovalForAll","inputs":[{"name":"owner","type":"address","indexed":true},{"name":"operator","type":"address","indexed":true},{"name":"approved","type":"bool"}]}
+	]`,
+	// erc1155
+	`[
+		{"type":"event","name":"TransferSingle","inputs":[{"name":"operator","type":"address","indexed":true},{"name":"from","type":"address","indexed":true},{"name":"to","type":"address","indexed":true},{"name":"id","type":"uint256"},{"name":"value","type":"uint256"}]},
+		{"type":"event","name":"TransferBatch","inputs":[{"name":"operator","type":"address","indexed":true},{"name":"from","type":"address","indexed":true},{"name":"to","type":"address","indexed":true},{"name":"ids","type":"uint256[]"},{"name":"values","type":"uint256[]"}]}
+	]`,
+	// weth
+	`[
+		{"type":"event","name":"Deposit","inputs":[{"name":"dst","type":"address","indexed":true},{"name":"wad","type":"uint256"}]},
+		{"type":"event","name":"Withdrawal","inputs":[{"name":"src","type":"address","indexed":true},{"name":"wad","type":"uint256"}]}
+	]`,
+	// uniswap v2 and its forks
+	`[
+		{"type":"event","name":"Swap","inputs":[{"name":"sender","type":"address","indexed":true},{"name":"amount0In","type":"uint256"},{"name":"amount1In","type":"uint256"},{"name":"amount0Out","type":"uint256"},{"name":"amount1Out","type":"uint256"},{"name":"to","type":"address","indexed":true}]},
+		{"type":"event","name":"Sync","inputs":[{"name":"reserve0","type":"uint112"},{"name":"reserve1","type":"uint112"}]}
+	]`,
+	// uniswap v3 and its forks
+	`[
+		{"type":"event","name":"Swap","inputs":[{"name":"sender","type":"address","indexed":true},{"name":"recipient","type":"address","indexed":true},{"name":"amount0","type":"int256"},{"name":"amount1","type":"int256"},{"name":"sqrtPriceX96","type":"uint160"},{"name":"liquidity","type":"uint128"},{"name":"tick","type":"int24"}]}
+	]`,
+	// curve
+	`[
+		{"type":"event","name":"TokenExchange","inputs":[{"name":"buyer","type":"address","indexed":true},{"name":"sold_id","type":"int128"},{"name":"tokens_sold","type":"uint256"},{"name":"bought_id","type":"int128"},{"name":"tokens_bought","type":"uint256"}]}
+	]`,
+}
+
+// builtinEvents are the built-in events by topic
+var builtinEvents = newBuiltinEvents(builtinEventAbis)
+
+func newBuiltinEvents(abis []string) map[common.Hash][]abi.Event {
+	res := make(map[common.Hash][]abi.Event)
+	for _, item := range abis {
+		parsed, err := abi.JSON(strings.NewReader(item))
+		if err != nil {
+			panic(fmt.Sprintf("invalid built-in event abi: %v", err))
+		}
+		for _, event := range parsed.Events {
+			res[event.ID] = append(res[event.ID], event)
+		}
+	}
+	return res
+}
+
+// decodeLog returns nil for unknown events and logs the event does not unpack
+func decodeLog(contractAbi *abi.ABI, log *entity.LogData) *entity.DecodedLog {
+	topic := common.HexToHash(log.Topics[0])
+	if contractAbi != nil {
+		if event, err := contractAbi.EventByID(topic); err == nil {
+			if params, err := decodeEventParams(event, log); err == nil {
+				return &entity.DecodedLog{Name: event.RawName, Signature: event.Sig, Source: entity.AbiSourceContract, Params: params}
+			}
+		}
+	}
+	for _, event := range builtinEvents[topic] {
+		if params, err := decodeEventParams(&event, log); err == nil {
+			return &entity.DecodedLog{Name: event.RawName, Signature: event.Sig, Source: entity.AbiSourceBuiltin, Params: params}
+		}
+	}
+	return nil
+}
+
+// decodeEventParams fails when the topics do not match the indexed params of the event,
+// indexed params are read from the topics after the event signature and the others from the data.
+func decodeEventParams(event *abi.Event, log *entity.LogData) ([]entity.DecodedParam, error) {
+	var indexed abi.Arguments
+	for _, arg := range event.Inputs {
+		if arg.Indexed {
+			indexed = append(indexed, arg)
+		}
+	}
+	if len(indexed) != len(log.Topics)-1 {
+		return nil, fmt.Errorf("event %s has %d indexed params, log has %d topics", event.Sig, len(indexed), len(log.Topics))
+	}
+	data, err := hexutil.Decode(log.Data)
+	if err != nil {
+		return nil, err
+	}
+	values, err := event.Inputs.NonIndexed().UnpackValues(data)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]entity.DecodedParam, 0, len(event.Inputs))
+	var topicIndex, valueIndex int
+	for _, arg := range event.Inputs {
+		param := entity.DecodedParam{Name: arg.Name, Type: arg.Type.String()}
+		if arg.Indexed {
+			topic := common.HexToHash(log.Topics[topicIndex+1])
+			topicIndex++
+			if arg.Type.T == abi.TupleTy {
+				// the topic is the hash of the tuple, which go-ethereum refuses to parse
+				param.Value = topic.Hex()
+			} else {
+				parsed := make(map[string]interface{}, 1)
+				if err := abi.ParseTopicsIntoMap(parsed, abi.Arguments{arg}, []common.Hash{topic}); err != nil {
+					return nil, err
+				}
+				param.Value = formatAbiValue(reflect.ValueOf(parsed[arg.Name]))
+			}
+		} else {
+			param.Value = formatAbiValue(reflect.ValueOf(values[valueIndex]))
+			valueIndex++
+		}
+		res = append(res, param)
+	}
+	return res, nil
+}
+
+func contractAbiCacheKey(chain Chain, address string) string {
+	return fmt.Sprintf("contract_abi:%s:%s", string(chain), strings.ToLower(address))
+}
+
+// getAbi returns nil when no abi was uploaded for the contract
+func (d *abiDecoder) getAbi(ctx context.Context, chain Chain, address string) (*abi.ABI, error) {
+	res, err := d.getAbis(ctx, chain, []string{address})
+	if err != nil {
+		return nil, err
+	}
+	return res[strings.ToLower(address)], nil
+}
+
+// getAbis returns the abis uploaded for the contracts by lowercase address, contracts without abi are left out.
+// Lookups are cached for contractAbiCacheTTL, contracts without abi as null, and the misses are read with one query.
+// When the query fails the abis found in the cache are returned with the error.
+func (d *abiDecoder) getAbis(ctx context.Context, chain Chain, addresses []string) (map[string]*abi.ABI, error) {
+	var (
+		items  = make(map[string]*entity.ContractAbi, len(addresses))
+		unique = make([]string, 0, len(addresses))
+		keys   = make([]string, 0, len(addresses))
+	)
+	for _, address := range addresses {
+		address = strings.ToLower(address)
+		if _, ok := items[address]; ok || address == "" {
+			continue
+		}
+		items[address] = nil
+		unique = append(unique, address)
+		keys = append(keys, contractAbiCacheKey(chain, address))
+	}
+	if len(unique) == 0 {
+		return nil, nil
+	}
+
+	// get data from cache
+	var missing []string
+	values, err := d.redisRepo.MGet(ctx, keys)
+	for i, address := range unique {
+		var (
+			item *entity.ContractAbi
+			data string
+			ok   bool
+		)
+		if err == nil && i < len(values) {
+			data, ok = values[i].(string)
+		}
+		if !ok || json.Unmarshal([]byte(data), &item) != nil {
+			missing = append(missing, address)
+			continue
+		}
+		items[address] = item
+	}
+
+	// if no hit cache then call db
+	if len(missing) > 0 {
+		rows, err := d.contractAbiRepo.GetList(ctx,
+			d.contractAbiRepo.S().FilterChain(string(chain)),
+			d.contractAbiRepo.S().FilterAddresses(missing),
+		)
+		if err != nil {
+			return d.parseAbis(ctx, chain, items), err
+		}
+		for _, row := range rows {
+			items[row.Address] = row
+		}
+		for _, address := range missing {
+			cacheKey := contractAbiCacheKey(chain, address)
+			_ = d.redisRepo.Set(ctx, cacheKey, items[address])
+			_ = d.redisRepo.Expire(ctx, cacheKey, contractAbiCacheTTL)
+		}
+	}
+	return d.parseAbis(ctx, chain, items), nil
+}
+
+// parseAbis leaves out the contracts without abi
+func (d *abiDecoder) parseAbis(ctx context.Context, chain Chain, items map[string]*entity.ContractAbi) map[string]*abi.ABI {
+	ctx, logger := u_logger.GetLogger(ctx)
+	res := make(map[string]*abi.ABI, len(items))
+	for address, item := range items {
+		if item == nil {
+			continue
+		}
+		parsed, err := abi.JSON(bytes.NewReader(item.Abi))
+		if err != nil {
+			// abis are validated on upload, so this is only logged
+			logger.Warnf("invalid abi of contract %s of chain %s: %v", address, chain, err)
+			continue
+		}
+		res[address] = &parsed
+	}
+	return res
+}
+
+func (d *abiDecoder) getSignatures(ctx context.Context, selector string) ([]string, error) {
+	cacheKey := fmt.Sprintf("selector:%s", selector)
+
+	// get data from cache
+	data, err := d.redisRepo.Get(ctx, cacheKey)
+	if err == nil {
+		var res []string
+		err = json.Unmarshal([]byte(data), &res)
+		return res, err
+	}
+
+	// if no hit cache then call db
+	rows, err := d.functionSignatureRepo.GetList(ctx, d.functionSignatureRepo.S().FilterSelector(selector))
+	if err != nil {
+		return nil, err
+	}
+	res := make([]string, 0, len(rows))
+	for _, row := range rows {
+		res = append(res, row.Signature)
+	}
+	_ = d.redisRepo.Set(ctx, cacheKey, res)
+	return res, nil
+}
+
+// parseSignature turns a text signature such as swap((address,uint256)[],bytes) into the abi arguments,
+// tuple components have no name in text signatures, so they are named by position.
+func parseSignature(signature string) (string, abi.Arguments, error) {
+	open := strings.Index(signature, "(")
+	if open <= 0 || !strings.HasSuffix(signature, ")") {
+		return "", nil, fmt.Errorf("invalid signature %s", signature)
+	}
+	types, err := splitAbiTypes(signature[open+1 : len(signature)-1])
+	if err != nil {
+		return "", nil, err
+	}
+
+	args := make(abi.Arguments, 0, len(types))
+	for i, item := range types {
+		marshaling, err := newArgumentMarshaling(fmt.Sprintf("arg%d", i), item)
+		if err != nil {
+			return "", nil, err
+		}
+		typ, err := abi.NewType(marshaling.Type, "", marshaling.Components)
+		if err != nil {
+			return "", nil, err
+		}
+		args = append(args, abi.Argument{Type: typ})
+	}
+	return signature[:open], args, nil
+}
+
+// splitAbiTypes splits a type list at the commas outside of tuples
+func splitAbiTypes(list string) ([]string, error) {
+	var (
+		res   []string
+		depth int
+		start int
+	)
+	if list == "" {
+		return nil, nil
+	}
+	for i, c := range list {
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				res = append(res, list[start:i])
+				start = i + 1
+			}
+		}
+		if depth < 0 {
+			return nil, fmt.Errorf("invalid type list %s", list)
+		}
+	}
+	if depth != 0 {
+		return nil, fmt.Errorf("invalid type list %s", list)
+	}
+	return append(res, list[start:]), nil
+}
+
+func newArgumentMarshaling(name, typ string) (abi.ArgumentMarshaling, error) {
+	if !strings.HasPrefix(typ, "(") {
+		return abi.ArgumentMarshaling{Name: name, Type: typ}, nil
+	}
+	end := strings.LastIndex(typ, ")")
+	components, err := splitAbiTypes(typ[1:end])
+	if err != nil {
+		return abi.ArgumentMarshaling{}, err
+	}
+	res := abi.ArgumentMarshaling{Name: name, Type: "tuple" + typ[end+1:]}
+	for i, item := range components {
+		component, err := newArgumentMarshaling(fmt.Sprintf("field%d", i), item)
+		if err != nil {
+			return abi.ArgumentMarshaling{}, err
+		}
+		res.Components = append(res.Components, component)
+	}
+	return res, nil
+}
+
+func newDecodedParams(args abi.Arguments, values []interface{}) []entity.DecodedParam {
+	res := make([]entity.DecodedParam, 0, len(values))
+	for i, value := range values {
+		res = append(res, entity.DecodedParam{
+			Name:  args[i].Name,
+			Type:  args[i].Type.String(),
+			Value: formatAbiValue(reflect.ValueOf(value)),
+		})
+	}
+	return res
+}
+
+// formatAbiValue converts the go values of go-ethereum abi into json friendly values
+func formatAbiValue(value reflect.Value) interface{} {
+	if !value.IsValid() {
+		return nil
+	}
+	switch v := value.Interface().(type) {
+	case *big.Int:
+		return v.String()
+	case common.Address:
+		return v.Hex()
+	case common.Hash:
+		return v.Hex()
+	case []byte:
+		return hexutil.Encode(v)
+	}
+
+	switch value.Kind() {
+	case reflect.Array:
+		// fixed bytes, such as bytes32
+		if value.Type().Elem().Kind() == reflect.Uint8 {
+			data := make([]byte, value.Len())
+			reflect.Copy(reflect.ValueOf(data), value)
+			return hexutil.Encode(data)
+		}
+		fallthrough
+	case reflect.Slice:
+		res := make([]interface{}, 0, value.Len())
+		for i := 0; i < value.Len(); i++ {
+			res = append(res, formatAbiValue(value.Index(i)))
+		}
+		return res
+	case reflect.Struct:
+		res := make(map[string]interface{}, value.NumField())
+		for i := 0; i < value.NumField(); i++ {
+			field := value.Type().Field(i)
+			name := field.Tag.Get("json")
+			if name == "" {
+				name = field.Name
+			}
+			res[name] = formatAbiValue(value.Field(i))
+		}
+		return res
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		// integers up to 64 bits are decimal strings as well, so clients parse every integer the same way
+		return fmt.Sprint(value.Interface())
+	}
+	return value.Interface()
+}
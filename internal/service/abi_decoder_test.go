@@ -0,0 +1,361 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/smartystreets/goconvey/convey"
+
+	"nimbus-enhance-api/internal/entity"
+)
+
+const testTokenAddress = "0xdac17f958d2ee523a2206206994597c13d831ec7"
+
+// input of transfer(0x000000000000000000000000000000000000dead, 1000)
+const testTransferInput = "0xa9059cbb000000000000000000000000000000000000000000000000000000000000dead00000000000000000000000000000000000000000000000000000000000003e8"
+
+const testTokenAbi = `[{"type":"function","name":"transfer","inputs":[{"name":"to","type":"address"},{"name":"amount","type":"uint256"}],"outputs":[{"name":"","type":"bool"}]}]`
+
+const (
+	testTransferTopic = "0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef"
+	testFromTopic     = "0x000000000000000000000000000000000000000000000000000000000000beef"
+	testToTopic       = "0x000000000000000000000000000000000000000000000000000000000000dead"
+	testAmountData    = "0x00000000000000000000000000000000000000000000000000000000000003e8"
+)
+
+func newTestAbiDecoder() (*abiDecoder, *fakeContractAbiRepo) {
+	contractAbiRepo := &fakeContractAbiRepo{}
+	return &abiDecoder{
+		chainRegistry:         &chainRegistry{chains: map[Chain]ChainInfo{Ethereum: {}}},
+		functionSignatureRepo: &fakeFunctionSignatureRepo{signatures: []string{"transfer(address,uint256)"}},
+		contractAbiRepo:       contractAbiRepo,
+		redisRepo:             newFakeRedisRepo(),
+	}, contractAbiRepo
+}
+
+func TestAbiDecoder_DecodeInput(t *testing.T) {
+	convey.Convey("TestAbiDecoder_DecodeInput", t, func() {
+		ctx := context.Background()
+		d, contractAbiRepo := newTestAbiDecoder()
+
+		convey.Convey("Contracts without abi are cached and decoded with the signature database", func() {
+			for i := 0; i < 3; i++ {
+				res, err := d.DecodeInput(ctx, Ethereum, testTokenAddress, testTransferInput)
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(res.Functions, convey.ShouldHaveLength, 1)
+				convey.So(res.Functions[0].Source, convey.ShouldEqual, entity.AbiSourceSignature)
+			}
+			convey.So(contractAbiRepo.Queries(), convey.ShouldEqual, 1)
+		})
+
+		convey.Convey("Uploaded abi invalidates the cached lookup", func() {
+			_, err := d.DecodeInput(ctx, Ethereum, testTokenAddress, testTransferInput)
+			convey.So(err, convey.ShouldBeNil)
+
+			err = d.SaveContractAbi(ctx, &entity.ContractAbi{Chain: string(Ethereum), Address: testTokenAddress, Abi: json.RawMessage(testTokenAbi)})
+			convey.So(err, convey.ShouldBeNil)
+			for i := 0; i < 2; i++ {
+				res, err := d.DecodeInput(ctx, Ethereum, testTokenAddress, testTransferInput)
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(res.Functions, convey.ShouldHaveLength, 1)
+				convey.So(res.Functions[0].Source, convey.ShouldEqual, entity.AbiSourceContract)
+				convey.So(res.Functions[0].Params, convey.ShouldResemble, []entity.DecodedParam{
+					{Name: "to", Type: "address", Value: "0x000000000000000000000000000000000000dEaD"},
+					{Name: "amount", Type: "uint256", Value: "1000"},
+				})
+			}
+			convey.So(contractAbiRepo.Queries(), convey.ShouldEqual, 2)
+		})
+	})
+}
+
+func TestAbiDecoder_DecodeLogs(t *testing.T) {
+	convey.Convey("TestAbiDecoder_DecodeLogs", t, func() {
+		ctx := context.Background()
+		d, contractAbiRepo := newTestAbiDecoder()
+		const pingAddress = "0x00000000000000000000000000000000000000aa"
+		contractAbiRepo.abis = []*entity.ContractAbi{{
+			Chain:   string(Ethereum),
+			Address: pingAddress,
+			Abi:     json.RawMessage(`[{"type":"event","name":"Ping","inputs":[{"name":"value","type":"uint256"}]}]`),
+		}}
+		newLogs := func() []*entity.LogData {
+			return []*entity.LogData{
+				{Address: "0x00000000000000000000000000000000000000AA", Topics: []string{crypto.Keccak256Hash([]byte("Ping(uint256)")).Hex()}, Data: testAmountData},
+				{Address: testTokenAddress, Topics: []string{testTransferTopic, testFromTopic, testToTopic}, Data: testAmountData},
+				{Address: "0x00000000000000000000000000000000000000bb", Topics: []string{testTransferTopic, testFromTopic, testToTopic}, Data: testAmountData},
+				{Address: "0x00000000000000000000000000000000000000cc", Data: testAmountData},
+			}
+		}
+
+		convey.Convey("Abis of the emitters are read with one query and cached", func() {
+			for i := 0; i < 2; i++ {
+				logs := newLogs()
+				convey.So(d.DecodeLogs(ctx, Ethereum, logs), convey.ShouldBeNil)
+				convey.So(logs[0].Decoded.Name, convey.ShouldEqual, "Ping")
+				convey.So(logs[0].Decoded.Source, convey.ShouldEqual, entity.AbiSourceContract)
+				convey.So(logs[1].Decoded.Source, convey.ShouldEqual, entity.AbiSourceBuiltin)
+				convey.So(logs[2].Decoded.Source, convey.ShouldEqual, entity.AbiSourceBuiltin)
+				convey.So(logs[3].Decoded, convey.ShouldBeNil)
+			}
+			convey.So(contractAbiRepo.Queries(), convey.ShouldEqual, 1)
+		})
+
+		convey.Convey("Failed lookup still decodes every log with the built-in events", func() {
+			contractAbiRepo.err = errors.New("connection refused")
+			logs := newLogs()
+			convey.So(d.DecodeLogs(ctx, Ethereum, logs), convey.ShouldNotBeNil)
+			convey.So(logs[0].Decoded, convey.ShouldBeNil)
+			convey.So(logs[1].Decoded.Name, convey.ShouldEqual, "Transfer")
+			convey.So(logs[2].Decoded.Name, convey.ShouldEqual, "Transfer")
+		})
+	})
+}
+
+func TestParseSignature(t *testing.T) {
+	convey.Convey("TestParseSignature", t, func() {
+		cases := []struct {
+			signature string
+			name      string
+			types     []string
+			fail      bool
+		}{
+			{signature: "transfer(address,uint256)", name: "transfer", types: []string{"address", "uint256"}},
+			{signature: "pause()", name: "pause", types: []string{}},
+			{signature: "swap((address,uint256)[],bytes)", name: "swap", types: []string{"(address,uint256)[]", "bytes"}},
+			{signature: "execute((uint8,(bool,string)[2]),bytes32[])", name: "execute", types: []string{"(uint8,(bool,string)[2])", "bytes32[]"}},
+			{signature: "transfer", fail: true},
+			{signature: "(address)", fail: true},
+			{signature: "transfer(address", fail: true},
+			{signature: "transfer(address))", fail: true},
+			{signature: "transfer(address,token)", fail: true},
+		}
+		for _, c := range cases {
+			name, args, err := parseSignature(c.signature)
+			if c.fail {
+				convey.So(err, convey.ShouldNotBeNil)
+				continue
+			}
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(name, convey.ShouldEqual, c.name)
+			types := make([]string, 0, len(args))
+			for _, arg := range args {
+				types = append(types, arg.Type.String())
+			}
+			convey.So(types, convey.ShouldResemble, c.types)
+		}
+	})
+}
+
+func TestSplitAbiTypes(t *testing.T) {
+	convey.Convey("TestSplitAbiTypes", t, func() {
+		cases := []struct {
+			list  string
+			types []string
+			fail  bool
+		}{
+			{list: "", types: nil},
+			{list: "address", types: []string{"address"}},
+			{list: "address,uint256", types: []string{"address", "uint256"}},
+			{list: "(address,uint256),bool", types: []string{"(address,uint256)", "bool"}},
+			{list: "((address,uint256)[],bytes)[],uint8", types: []string{"((address,uint256)[],bytes)[]", "uint8"}},
+			{list: "address)", fail: true},
+			{list: "(address", fail: true},
+			{list: "address),(uint256", fail: true},
+		}
+		for _, c := range cases {
+			types, err := splitAbiTypes(c.list)
+			if c.fail {
+				convey.So(err, convey.ShouldNotBeNil)
+				continue
+			}
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(types, convey.ShouldResemble, c.types)
+		}
+	})
+}
+
+func TestNewArgumentMarshaling(t *testing.T) {
+	convey.Convey("TestNewArgumentMarshaling", t, func() {
+		cases := []struct {
+			typ  string
+			want abi.ArgumentMarshaling
+		}{
+			{typ: "uint256", want: abi.ArgumentMarshaling{Name: "arg0", Type: "uint256"}},
+			{typ: "bytes32[]", want: abi.ArgumentMarshaling{Name: "arg0", Type: "bytes32[]"}},
+			{typ: "(address,uint256)", want: abi.ArgumentMarshaling{Name: "arg0", Type: "tuple", Components: []abi.ArgumentMarshaling{
+				{Name: "field0", Type: "address"},
+				{Name: "field1", Type: "uint256"},
+			}}},
+			{typ: "(address,(bool,bytes)[])[3]", want: abi.ArgumentMarshaling{Name: "arg0", Type: "tuple[3]", Components: []abi.ArgumentMarshaling{
+				{Name: "field0", Type: "address"},
+				{Name: "field1", Type: "tuple[]", Components: []abi.ArgumentMarshaling{
+					{Name: "field0", Type: "bool"},
+					{Name: "field1", Type: "bytes"},
+				}},
+			}}},
+		}
+		for _, c := range cases {
+			res, err := newArgumentMarshaling("arg0", c.typ)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(res, convey.ShouldResemble, c.want)
+
+			_, err = abi.NewType(res.Type, "", res.Components)
+			convey.So(err, convey.ShouldBeNil)
+		}
+
+		convey.Convey("Unbalanced tuple fails", func() {
+			_, err := newArgumentMarshaling("arg0", "((address,uint256)")
+			convey.So(err, convey.ShouldNotBeNil)
+		})
+	})
+}
+
+func TestFormatAbiValue(t *testing.T) {
+	convey.Convey("TestFormatAbiValue", t, func() {
+		type tuple struct {
+			Amount    *big.Int `json:"amount"`
+			Recipient common.Address
+		}
+		cases := []struct {
+			name  string
+			value interface{}
+			want  interface{}
+		}{
+			{name: "big integer", value: big.NewInt(-1000), want: "-1000"},
+			{name: "small integers", value: uint8(7), want: "7"},
+			{name: "signed small integers", value: int64(-5), want: "-5"},
+			{name: "address", value: common.HexToAddress("0xdead"), want: "0x000000000000000000000000000000000000dEaD"},
+			{name: "hash", value: common.HexToHash("0x01"), want: "0x0000000000000000000000000000000000000000000000000000000000000001"},
+			{name: "bytes", value: []byte{0xca, 0xfe}, want: "0xcafe"},
+			{name: "fixed bytes", value: [4]byte{0xa9, 0x05, 0x9c, 0xbb}, want: "0xa9059cbb"},
+			{name: "bool", value: true, want: true},
+			{name: "string", value: "nimbus", want: "nimbus"},
+			{name: "array", value: []*big.Int{big.NewInt(1), big.NewInt(2)}, want: []interface{}{"1", "2"}},
+			{name: "fixed array", value: [2]uint16{1, 2}, want: []interface{}{"1", "2"}},
+			{name: "tuple", value: tuple{Amount: big.NewInt(3), Recipient: common.HexToAddress("0xdead")}, want: map[string]interface{}{
+				"amount":    "3",
+				"Recipient": "0x000000000000000000000000000000000000dEaD",
+			}},
+			{name: "tuple array", value: []tuple{{Amount: big.NewInt(4)}}, want: []interface{}{map[string]interface{}{
+				"amount":    "4",
+				"Recipient": "0x0000000000000000000000000000000000000000",
+			}}},
+		}
+		for _, c := range cases {
+			convey.So(formatAbiValue(reflect.ValueOf(c.value)), convey.ShouldResemble, c.want)
+		}
+
+		convey.Convey("Invalid value is nil", func() {
+			convey.So(formatAbiValue(reflect.Value{}), convey.ShouldBeNil)
+		})
+	})
+}
+
+func TestDecodeLog(t *testing.T) {
+	convey.Convey("TestDecodeLog", t, func() {
+		var (
+			approvalTopic = crypto.Keccak256Hash([]byte("Approval(address,address,uint256)")).Hex()
+			tokenIDTopic  = common.BigToHash(big.NewInt(1000)).Hex()
+			from          = common.HexToAddress(testFromTopic).Hex()
+			to            = common.HexToAddress(testToTopic).Hex()
+		)
+		contractAbi, err := abi.JSON(strings.NewReader(`[
+			{"type":"event","name":"Transfer","inputs":[{"name":"src","type":"address"},{"name":"dst","type":"address"},{"name":"wad","type":"uint256"}]},
+			{"type":"event","name":"Order","inputs":[{"name":"order","type":"tuple","indexed":true,"components":[{"name":"maker","type":"address"}]},{"name":"price","type":"uint256"}]}
+		]`))
+		convey.So(err, convey.ShouldBeNil)
+		orderTopic := contractAbi.Events["Order"].ID.Hex()
+		orderHash := crypto.Keccak256Hash([]byte("order")).Hex()
+
+		cases := []struct {
+			name        string
+			contractAbi *abi.ABI
+			log         *entity.LogData
+			want        *entity.DecodedLog
+		}{
+			{
+				name: "erc20 transfer has the value in data",
+				log:  &entity.LogData{Topics: []string{testTransferTopic, testFromTopic, testToTopic}, Data: testAmountData},
+				want: &entity.DecodedLog{Name: "Transfer", Signature: "Transfer(address,address,uint256)", Source: entity.AbiSourceBuiltin, Params: []entity.DecodedParam{
+					{Name: "from", Type: "address", Value: from},
+					{Name: "to", Type: "address", Value: to},
+					{Name: "value", Type: "uint256", Value: "1000"},
+				}},
+			},
+			{
+				name: "erc721 transfer has the token id in a fourth topic",
+				log:  &entity.LogData{Topics: []string{testTransferTopic, testFromTopic, testToTopic, tokenIDTopic}, Data: "0x"},
+				want: &entity.DecodedLog{Name: "Transfer", Signature: "Transfer(address,address,uint256)", Source: entity.AbiSourceBuiltin, Params: []entity.DecodedParam{
+					{Name: "from", Type: "address", Value: from},
+					{Name: "to", Type: "address", Value: to},
+					{Name: "tokenId", Type: "uint256", Value: "1000"},
+				}},
+			},
+			{
+				name: "erc20 approval",
+				log:  &entity.LogData{Topics: []string{approvalTopic, testFromTopic, testToTopic}, Data: testAmountData},
+				want: &entity.DecodedLog{Name: "Approval", Signature: "Approval(address,address,uint256)", Source: entity.AbiSourceBuiltin, Params: []entity.DecodedParam{
+					{Name: "owner", Type: "address", Value: from},
+					{Name: "spender", Type: "address", Value: to},
+					{Name: "value", Type: "uint256", Value: "1000"},
+				}},
+			},
+			{
+				name: "erc721 approval",
+				log:  &entity.LogData{Topics: []string{approvalTopic, testFromTopic, testToTopic, tokenIDTopic}, Data: "0x"},
+				want: &entity.DecodedLog{Name: "Approval", Signature: "Approval(address,address,uint256)", Source: entity.AbiSourceBuiltin, Params: []entity.DecodedParam{
+					{Name: "owner", Type: "address", Value: from},
+					{Name: "approved", Type: "address", Value: to},
+					{Name: "tokenId", Type: "uint256", Value: "1000"},
+				}},
+			},
+			{
+				name: "transfer with topics of neither standard",
+				log:  &entity.LogData{Topics: []string{testTransferTopic, testFromTopic}, Data: testAmountData},
+			},
+			{
+				name: "unknown event",
+				log:  &entity.LogData{Topics: []string{crypto.Keccak256Hash([]byte("Unknown()")).Hex()}, Data: "0x"},
+			},
+			{
+				name:        "contract abi not matching the topics falls back to the built-in events",
+				contractAbi: &contractAbi,
+				log:         &entity.LogData{Topics: []string{testTransferTopic, testFromTopic, testToTopic}, Data: testAmountData},
+				want: &entity.DecodedLog{Name: "Transfer", Signature: "Transfer(address,address,uint256)", Source: entity.AbiSourceBuiltin, Params: []entity.DecodedParam{
+					{Name: "from", Type: "address", Value: from},
+					{Name: "to", Type: "address", Value: to},
+					{Name: "value", Type: "uint256", Value: "1000"},
+				}},
+			},
+			{
+				name:        "indexed tuple of the contract abi is the hash in the topic",
+				contractAbi: &contractAbi,
+				log:         &entity.LogData{Topics: []string{orderTopic, orderHash}, Data: testAmountData},
+				want: &entity.DecodedLog{Name: "Order", Signature: "Order((address),uint256)", Source: entity.AbiSourceContract, Params: []entity.DecodedParam{
+					{Name: "order", Type: "(address)", Value: orderHash},
+					{Name: "price", Type: "uint256", Value: "1000"},
+				}},
+			},
+			{
+				name:        "data too short for the event",
+				contractAbi: &contractAbi,
+				log:         &entity.LogData{Topics: []string{orderTopic, orderHash}, Data: "0x01"},
+			},
+		}
+		for _, c := range cases {
+			c := c
+			convey.Convey(c.name, func() {
+				convey.So(decodeLog(c.contractAbi, c.log), convey.ShouldResemble, c.want)
+			})
+		}
+	})
+}
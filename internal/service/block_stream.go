@@ -0,0 +1,263 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/tikivn/ultrago/u_logger"
+
+	"nimbus-enhance-api/internal/conf"
+	"nimbus-enhance-api/internal/entity"
+	"nimbus-enhance-api/internal/infra"
+	"nimbus-enhance-api/internal/setting"
+)
+
+const (
+	// blockStreamBuffer is the number of blocks a slow subscriber may lag before blocks are dropped for it
+	blockStreamBuffer = 16
+	// blockStreamMaxCatchUp bounds the blocks published by one poll, older ones are skipped
+	blockStreamMaxCatchUp = 16
+	// blockStreamRecheckInterval is how long a feed polls before checking again for a websocket upstream
+	blockStreamRecheckInterval = time.Minute
+)
+
+func NewBlockStream(
+	chainRegistry ChainRegistry,
+	clientPool *infra.RpcClientPool,
+) (BlockStream, func()) {
+	stream := &blockStream{
+		chainRegistry: chainRegistry,
+		clientPool:    clientPool,
+		feeds:         make(map[Chain]*blockFeed),
+	}
+	return stream, stream.Close
+}
+
+// BlockStream pushes new blocks of a chain to subscribers. Subscribers of the same chain share one upstream feed,
+// started by the first subscriber and stopped when the last one leaves.
+// EVM chains with a websocket upstream subscribe to newHeads, other chains poll the latest block.
+type BlockStream interface {
+	// Subscribe returns blocks until unsubscribe is called or the stream is closed, the channel is closed then.
+	Subscribe(chain Chain) (blocks <-chan *entity.Block, unsubscribe func(), err error)
+	Close()
+}
+
+type blockStream struct {
+	sync.Mutex
+	chainRegistry ChainRegistry
+	clientPool    *infra.RpcClientPool
+	feeds         map[Chain]*blockFeed
+	closed        bool
+}
+
+type blockFeed struct {
+	chain       Chain
+	cancel      context.CancelFunc
+	subscribers map[chan *entity.Block]struct{}
+	last        uint64 // latest published block number, only used by the feed goroutine
+}
+
+func (s *blockStream) Subscribe(chain Chain) (<-chan *entity.Block, func(), error) {
+	if _, ok := s.chainRegistry.Get(chain); !ok {
+		return nil, nil, fmt.Errorf("%w: %s", setting.ErrNotSupportedChain, chain)
+	}
+
+	s.Lock()
+	defer s.Unlock()
+	if s.closed {
+		return nil, nil, setting.ErrStreamClosed
+	}
+
+	feed, ok := s.feeds[chain]
+	if !ok {
+		ctx, cancel := context.WithCancel(context.Background())
+		feed = &blockFeed{
+			chain:       chain,
+			cancel:      cancel,
+			subscribers: make(map[chan *entity.Block]struct{}),
+		}
+		s.feeds[chain] = feed
+		go s.run(ctx, feed)
+	}
+
+	ch := make(chan *entity.Block, blockStreamBuffer)
+	feed.subscribers[ch] = struct{}{}
+	unsubscribe := func() {
+		s.Lock()
+		defer s.Unlock()
+		if _, ok := feed.subscribers[ch]; !ok {
+			return
+		}
+		delete(feed.subscribers, ch)
+		close(ch)
+		if len(feed.subscribers) == 0 {
+			feed.cancel()
+			delete(s.feeds, chain)
+		}
+	}
+	return ch, unsubscribe, nil
+}
+
+// Close stops every feed and closes the channels of all subscribers, so streaming responses end before server shutdown.
+func (s *blockStream) Close() {
+	s.Lock()
+	defer s.Unlock()
+	s.closed = true
+	for chain, feed := range s.feeds {
+		feed.cancel()
+		for ch := range feed.subscribers {
+			close(ch)
+		}
+		feed.subscribers = nil
+		delete(s.feeds, chain)
+	}
+}
+
+// publish never blocks the feed, a subscriber whose buffer is full misses the block
+func (s *blockStream) publish(ctx context.Context, feed *blockFeed, block *entity.Block) {
+	ctx, logger := u_logger.GetLogger(ctx)
+	block.Chain = string(feed.chain)
+	feed.last = block.Number
+
+	s.Lock()
+	defer s.Unlock()
+	for ch := range feed.subscribers {
+		select {
+		case ch <- block:
+		default:
+			logger.Warnf("drop block %d of chain %s for slow subscriber", block.Number, feed.chain)
+		}
+	}
+}
+
+func (s *blockStream) run(ctx context.Context, feed *blockFeed) {
+	ctx, logger := u_logger.GetLogger(ctx)
+	for ctx.Err() == nil {
+		chainInfo, ok := s.chainRegistry.Get(feed.chain)
+		if ok && chainInfo.IsEVM {
+			if endpoint := wsEndpoint(chainInfo); endpoint != "" {
+				err := s.subscribeNewHeads(ctx, feed, chainInfo, endpoint)
+				if ctx.Err() != nil {
+					return
+				}
+				logger.Warnf("new heads subscription of chain %s failed, poll instead: %v", feed.chain, err)
+			}
+		}
+		s.pollBlocks(ctx, feed, time.Now().Add(blockStreamRecheckInterval))
+	}
+}
+
+// subscribeNewHeads returns when the subscription fails. The websocket connection is dedicated to the feed
+// instead of pooled, because it stays open as long as the feed has subscribers.
+func (s *blockStream) subscribeNewHeads(ctx context.Context, feed *blockFeed, chainInfo ChainInfo, endpoint string) error {
+	ctx, logger := u_logger.GetLogger(ctx)
+	dialCtx, cancel := context.WithTimeout(ctx, conf.Config.UpstreamProbeTimeout)
+	defer cancel()
+	client, err := rpc.DialContext(dialCtx, endpoint)
+	if err != nil {
+		return redactError(err)
+	}
+	defer client.Close()
+
+	// chains with a custom namespace, such as klay_, subscribe in the namespace of their block methods
+	namespace := "eth"
+	if method := chainInfo.Methods["getBlockByNumber"]; strings.Contains(method, "_") {
+		namespace = strings.SplitN(method, "_", 2)[0]
+	}
+	heads := make(chan json.RawMessage, blockStreamBuffer)
+	sub, err := client.Subscribe(ctx, namespace, heads, "newHeads")
+	if err != nil {
+		return redactError(err)
+	}
+	defer sub.Unsubscribe()
+
+	logger.Infof("subscribed new heads of chain %s", feed.chain)
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-sub.Err():
+			return redactError(err)
+		case data := <-heads:
+			block, err := newEvmBlock(data, false)
+			if err != nil {
+				logger.Errorf("invalid new head of chain %s: %v", feed.chain, err)
+				continue
+			}
+			// heads carry no transactions
+			block.TxCount = nil
+			s.publish(ctx, feed, block)
+		}
+	}
+}
+
+// pollBlocks publishes the blocks after the last published one every conf BLOCK_POLL_INTERVAL until deadline.
+// The first poll publishes the latest block only.
+func (s *blockStream) pollBlocks(ctx context.Context, feed *blockFeed, deadline time.Time) {
+	ctx, logger := u_logger.GetLogger(ctx)
+	ticker := time.NewTicker(conf.Config.BlockPollInterval)
+	defer ticker.Stop()
+
+	for time.Now().Before(deadline) {
+		if err := s.pollBlock(ctx, feed); err != nil {
+			logger.Errorf("failed to poll blocks of chain %s: %v", feed.chain, err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *blockStream) pollBlock(ctx context.Context, feed *blockFeed) error {
+	chainInfo, ok := s.chainRegistry.Get(feed.chain)
+	if !ok {
+		return setting.ErrNotSupportedChain
+	}
+	adapter, err := newChainAdapter(feed.chain, chainInfo, s.clientPool)
+	if err != nil {
+		return err
+	}
+
+	head, err := adapter.GetLatestBlockNumber(ctx)
+	if err != nil || head <= feed.last {
+		return err
+	}
+	from := feed.last + 1
+	if feed.last == 0 || head-feed.last > blockStreamMaxCatchUp {
+		from = head
+	}
+	for number := from; number <= head; number++ {
+		block, err := adapter.GetBlockByNumber(ctx, number)
+		if errors.Is(err, ethereum.NotFound) {
+			continue
+		} else if err != nil {
+			return err
+		}
+		s.publish(ctx, feed, block)
+	}
+	feed.last = head
+	return nil
+}
+
+// wsEndpoint returns the first websocket upstream of the chain
+func wsEndpoint(chainInfo ChainInfo) string {
+	endpoints := []string{chainInfo.Endpoint}
+	for _, upstream := range chainInfo.Upstreams {
+		endpoints = append(endpoints, upstream.Endpoint)
+	}
+	for _, endpoint := range endpoints {
+		if strings.HasPrefix(endpoint, "ws://") || strings.HasPrefix(endpoint, "wss://") {
+			return endpoint
+		}
+	}
+	return ""
+}
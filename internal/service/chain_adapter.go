@@ -0,0 +1,78 @@
+package service
+
+import (
+	"context"
+	"sync"
+
+	"nimbus-enhance-api/internal/entity"
+	"nimbus-enhance-api/internal/infra"
+	"nimbus-enhance-api/internal/setting"
+)
+
+// ChainAdapter hides the rpc dialect of a chain family, so service methods never branch on the chain.
+// Blocks are normalized into entity.Block with the upstream payload as Raw, the chain name is set by the service.
+// Methods return nil result without error when the requested data does not exist,
+// and setting.ErrNotSupportedMethod when the chain family has no such concept.
+type ChainAdapter interface {
+	IsTxHash(hash string) bool
+	GetChainID(ctx context.Context) (uint64, error)
+	GetLatestBlock(ctx context.Context) (*entity.Block, error)
+	GetLatestBlockNumber(ctx context.Context) (uint64, error)
+	GetBlockByNumber(ctx context.Context, number uint64) (*entity.Block, error)
+	// GetBlock returns ethereum.NotFound when the block does not exist, transactions are included on demand
+	GetBlock(ctx context.Context, id BlockID, includeTxs bool) (*entity.Block, error)
+	GetTransactionByHash(ctx context.Context, hash string) (interface{}, error)
+	GetTransactionReceipt(ctx context.Context, hash string) (interface{}, error)
+}
+
+type ChainAdapterFactory func(chainInfo ChainInfo, clientPool *infra.RpcClientPool) ChainAdapter
+
+var (
+	chainAdaptersMu sync.RWMutex
+	chainAdapters   = map[Chain]ChainAdapterFactory{
+		Solana: newSolanaAdapter,
+		Near:   newNearAdapter,
+		Aptos:  newAptosAdapter,
+		Sui:    newSuiAdapter,
+		Tron:   newTronAdapter,
+
+		Bitcoin:  newUtxoAdapter,
+		Litecoin: newUtxoAdapter,
+		Dogecoin: newUtxoAdapter,
+	}
+)
+
+// RegisterChainAdapter binds a chain to its adapter, chains without adapter fall back to EVM when IsEVM is set.
+func RegisterChainAdapter(chain Chain, factory ChainAdapterFactory) {
+	chainAdaptersMu.Lock()
+	defer chainAdaptersMu.Unlock()
+	chainAdapters[chain] = factory
+}
+
+func newChainAdapter(chain Chain, chainInfo ChainInfo, clientPool *infra.RpcClientPool) (ChainAdapter, error) {
+	chainAdaptersMu.RLock()
+	factory, ok := chainAdapters[chain]
+	chainAdaptersMu.RUnlock()
+	if ok {
+		return factory(chainInfo, clientPool), nil
+	}
+	if chainInfo.IsEVM {
+		return newEvmAdapter(chainInfo, clientPool), nil
+	}
+	return nil, setting.ErrNotSupportedChain
+}
+
+// newUpstreamAdapter builds an adapter calling only endpoint, without failover to the other upstreams of the chain.
+func newUpstreamAdapter(chain Chain, chainInfo ChainInfo, endpoint string, clientPool *infra.RpcClientPool) (ChainAdapter, error) {
+	upstreams, err := newUpstreamGroup(chain, []entity.ChainUpstream{{Endpoint: endpoint, Weight: 1}}, NewUpstreamHealth())
+	if err != nil {
+		return nil, err
+	}
+	chainInfo.upstreams = upstreams
+	return newChainAdapter(chain, chainInfo, clientPool)
+}
+
+type TransactionData struct {
+	Receipt interface{} `json:"receipt,omitempty"`
+	Tx      interface{} `json:"tx,omitempty"`
+}
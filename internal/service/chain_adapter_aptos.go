@@ -0,0 +1,186 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/rpc"
+
+	"nimbus-enhance-api/internal/entity"
+	"nimbus-enhance-api/internal/infra"
+	"nimbus-enhance-api/internal/setting"
+	"nimbus-enhance-api/pkg/encoder"
+)
+
+// newAptosAdapter calls the rest api of aptos fullnode, Methods maps each call to its path.
+func newAptosAdapter(chainInfo ChainInfo, clientPool *infra.RpcClientPool) ChainAdapter {
+	return &aptosAdapter{
+		chainInfo:  chainInfo,
+		clientPool: clientPool,
+	}
+}
+
+type aptosAdapter struct {
+	chainInfo  ChainInfo
+	clientPool *infra.RpcClientPool
+}
+
+type aptosLedgerInfo struct {
+	ChainID           uint64 `json:"chain_id"`
+	BlockHeight       string `json:"block_height"`
+	OldestBlockHeight string `json:"oldest_block_height"`
+}
+
+// aptos encodes u64 as json string
+type aptosBlock struct {
+	BlockHeight    uint64 `json:"block_height,string"`
+	BlockHash      string `json:"block_hash"`
+	BlockTimestamp int64  `json:"block_timestamp,string"` // microseconds
+	FirstVersion   uint64 `json:"first_version,string"`
+	LastVersion    uint64 `json:"last_version,string"`
+}
+
+// IsTxHash checks the 0x prefixed sha3-256 hash, the same format as EVM tx hash
+func (a *aptosAdapter) IsTxHash(hash string) bool {
+	return strings.HasPrefix(hash, "0x") && len(hash) == 66 && encoder.IsHex(hash[2:])
+}
+
+func (a *aptosAdapter) GetChainID(ctx context.Context) (uint64, error) {
+	res, err := a.getLedgerInfo(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return res.ChainID, nil
+}
+
+func (a *aptosAdapter) GetLatestBlock(ctx context.Context) (*entity.Block, error) {
+	number, err := a.GetLatestBlockNumber(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return a.GetBlockByNumber(ctx, number)
+}
+
+func (a *aptosAdapter) GetLatestBlockNumber(ctx context.Context) (uint64, error) {
+	res, err := a.getLedgerInfo(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(res.BlockHeight, 10, 64)
+}
+
+func (a *aptosAdapter) GetBlockByNumber(ctx context.Context, number uint64) (*entity.Block, error) {
+	return a.getBlock(ctx, number, false)
+}
+
+// GetBlock addresses blocks by height, the rest api has no lookup by block hash.
+// Blocks are final once committed, so latest, safe and finalized are the same block.
+func (a *aptosAdapter) GetBlock(ctx context.Context, id BlockID, includeTxs bool) (*entity.Block, error) {
+	if id.Hash != "" {
+		return nil, setting.ErrNotSupportedMethod
+	}
+	if id.Number != nil {
+		return a.getBlock(ctx, *id.Number, includeTxs)
+	}
+
+	ledgerInfo, err := a.getLedgerInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	height := ledgerInfo.BlockHeight
+	if id.Tag == BlockTagEarliest {
+		height = ledgerInfo.OldestBlockHeight
+	}
+	number, err := strconv.ParseUint(height, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	return a.getBlock(ctx, number, includeTxs)
+}
+
+// GetTransactionByHash returns committed and pending transactions, the vm status of the transaction is its receipt.
+func (a *aptosAdapter) GetTransactionByHash(ctx context.Context, hash string) (interface{}, error) {
+	var res map[string]interface{}
+	err := a.call(ctx, &res, "getTransactionByHash", hash)
+	if isNotFoundError(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// GetTransactionReceipt is not supported because execution result is already returned with the transaction
+func (a *aptosAdapter) GetTransactionReceipt(ctx context.Context, hash string) (interface{}, error) {
+	return nil, setting.ErrNotSupportedMethod
+}
+
+// getBlock counts transactions from the versions of the block, aptos blocks have no parent hash nor proposer field.
+func (a *aptosAdapter) getBlock(ctx context.Context, number uint64, includeTxs bool) (*entity.Block, error) {
+	path, ok := a.chainInfo.Methods["getBlockByNumber"]
+	if !ok {
+		return nil, setting.ErrNotSupportedMethod
+	}
+
+	var data json.RawMessage
+	err := a.chainInfo.upstreams.Do(ctx, func(endpoint string) error {
+		url := fmt.Sprintf("%s?with_transactions=%t", joinUrl(endpoint, path, strconv.FormatUint(number, 10)), includeTxs)
+		return callRest(ctx, a.clientPool.GetHttpClient(), http.MethodGet, url, nil, &data)
+	})
+	if isNotFoundError(err) {
+		return nil, ethereum.NotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	var block aptosBlock
+	raw, err := decodeBlock(data, &block)
+	if err != nil {
+		return nil, err
+	}
+	if raw == nil {
+		return nil, ethereum.NotFound
+	}
+	res := &entity.Block{
+		Number:    block.BlockHeight,
+		Hash:      block.BlockHash,
+		Timestamp: block.BlockTimestamp / int64(time.Second/time.Microsecond),
+		Raw:       raw,
+	}
+	if includeTxs {
+		res.Transactions = raw["transactions"]
+	}
+	return res.WithTxCount(block.LastVersion - block.FirstVersion + 1), nil
+}
+
+func (a *aptosAdapter) getLedgerInfo(ctx context.Context) (*aptosLedgerInfo, error) {
+	var res aptosLedgerInfo
+	if err := a.call(ctx, &res, "getLedgerInfo"); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+func (a *aptosAdapter) call(ctx context.Context, result interface{}, method string, args ...string) error {
+	path, ok := a.chainInfo.Methods[method]
+	if !ok {
+		return setting.ErrNotSupportedMethod
+	}
+
+	return a.chainInfo.upstreams.Do(ctx, func(endpoint string) error {
+		return callRest(ctx, a.clientPool.GetHttpClient(), http.MethodGet, joinUrl(endpoint, append([]string{path}, args...)...), nil, result)
+	})
+}
+
+// isNotFoundError reports rest api answering 404 for missing block or transaction
+func isNotFoundError(err error) bool {
+	var httpErr rpc.HTTPError
+	return errors.As(err, &httpErr) && httpErr.StatusCode == http.StatusNotFound
+}
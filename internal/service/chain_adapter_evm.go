@@ -0,0 +1,515 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+
+	"nimbus-enhance-api/internal/entity"
+	"nimbus-enhance-api/internal/infra"
+	"nimbus-enhance-api/internal/setting"
+)
+
+func newEvmAdapter(chainInfo ChainInfo, clientPool *infra.RpcClientPool) ChainAdapter {
+	return &evmAdapter{
+		chainInfo:  chainInfo,
+		clientPool: clientPool,
+	}
+}
+
+type evmAdapter struct {
+	chainInfo  ChainInfo
+	clientPool *infra.RpcClientPool
+}
+
+type evmBlock struct {
+	Number       hexutil.Uint64    `json:"number"`
+	Hash         string            `json:"hash"`
+	ParentHash   string            `json:"parentHash"`
+	Timestamp    hexutil.Uint64    `json:"timestamp"`
+	Miner        string            `json:"miner"`
+	Reward       string            `json:"reward"` // klaytn names the block proposer reward
+	GasUsed      *hexutil.Uint64   `json:"gasUsed"`
+	GasLimit     *hexutil.Uint64   `json:"gasLimit"` // klaytn blocks have no gas limit
+	Transactions []json.RawMessage `json:"transactions"`
+}
+
+// IsTxHash checks the fixed-length 66 tx hash of every chain deploying EVM
+// https://stackoverflow.com/questions/72772567/how-long-ethereum-hash-length-block-transaction-address
+func (a *evmAdapter) IsTxHash(hash string) bool {
+	return strings.HasPrefix(hash, "0x") && len(hash) == 66
+}
+
+func (a *evmAdapter) GetChainID(ctx context.Context) (uint64, error) {
+	var res hexutil.Uint64
+	if err := a.call(ctx, &res, "chainId"); err != nil {
+		return 0, err
+	}
+	return uint64(res), nil
+}
+
+func (a *evmAdapter) GetLatestBlock(ctx context.Context) (*entity.Block, error) {
+	return a.getBlock(ctx, "getBlockByNumber", BlockTagLatest, false)
+}
+
+func (a *evmAdapter) GetLatestBlockNumber(ctx context.Context) (uint64, error) {
+	res, err := a.GetLatestBlock(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return res.Number, nil
+}
+
+// Commitments follow the block tags of the merge, nodes before it answer latest only
+func (a *evmAdapter) Commitments() ([]string, string) {
+	return []string{CommitmentLatest, CommitmentSafe, CommitmentFinalized}, CommitmentLatest
+}
+
+func (a *evmAdapter) GetHeadBlock(ctx context.Context, commitment string) (*entity.Block, error) {
+	return a.getBlock(ctx, "getBlockByNumber", commitment, false)
+}
+
+func (a *evmAdapter) GetHeadNumber(ctx context.Context, commitment string) (uint64, error) {
+	res, err := a.GetHeadBlock(ctx, commitment)
+	if err != nil {
+		return 0, err
+	}
+	return res.Number, nil
+}
+
+func (a *evmAdapter) GetBlockByNumber(ctx context.Context, number uint64) (*entity.Block, error) {
+	return a.getBlock(ctx, "getBlockByNumber", hexutil.EncodeUint64(number), false)
+}
+
+// GetBlock keeps the raw block, so fields of chains extending the EVM block are not lost.
+// Tags are passed through, nodes which do not know safe or finalized answer an error.
+func (a *evmAdapter) GetBlock(ctx context.Context, id BlockID, includeTxs bool) (*entity.Block, error) {
+	switch {
+	case id.Hash != "":
+		return a.getBlock(ctx, "getBlockByHash", id.Hash, includeTxs)
+	case id.Number != nil:
+		return a.getBlock(ctx, "getBlockByNumber", hexutil.EncodeUint64(*id.Number), includeTxs)
+	default:
+		return a.getBlock(ctx, "getBlockByNumber", id.Tag, includeTxs)
+	}
+}
+
+func (a *evmAdapter) GetTransactionByHash(ctx context.Context, hash string) (interface{}, error) {
+	var res map[string]interface{}
+	if err := a.call(ctx, &res, "getTransactionByHash", hash); err != nil {
+		return nil, err
+	}
+	if res == nil {
+		return nil, nil
+	}
+	return res, nil
+}
+
+func (a *evmAdapter) GetTransactionReceipt(ctx context.Context, hash string) (interface{}, error) {
+	var res map[string]interface{}
+	if err := a.call(ctx, &res, "getTransactionReceipt", hash); err != nil {
+		return nil, err
+	}
+	if res == nil {
+		return nil, nil
+	}
+	return res, nil
+}
+
+// NormalizeTransaction takes the block and status from the receipt, a transaction without receipt is pending.
+// The fee is the gas used at the effective gas price, plus the l1 fee op stack chains add to the receipt.
+func (a *evmAdapter) NormalizeTransaction(ctx context.Context, hash string, data *TransactionData) (*entity.Transaction, error) {
+	tx, _ := data.Tx.(map[string]interface{})
+	receipt, _ := data.Receipt.(map[string]interface{})
+	if tx == nil && receipt == nil {
+		return nil, fmt.Errorf("missing transaction %s", hash)
+	}
+	// receipts of klaytn carry the transaction fields
+	if tx == nil {
+		tx = receipt
+	}
+
+	res := &entity.Transaction{
+		Hash:   hash,
+		From:   mapString(tx, "from"),
+		To:     mapString(tx, "to"),
+		Status: entity.TxStatusPending,
+	}
+	if value := mapHexBig(tx, "value"); value != nil {
+		res.Value = value.String()
+	}
+	res.Input = mapString(tx, "input")
+	switch input := strings.TrimPrefix(res.Input, "0x"); {
+	case res.To == "":
+		res.Method = "create"
+	case input == "":
+		res.Method = "transfer"
+	case len(input) >= 8:
+		res.Method = "0x" + input[:8]
+	}
+	if receipt == nil {
+		return res, nil
+	}
+
+	res.BlockHash = mapString(receipt, "blockHash")
+	if number := mapHexBig(receipt, "blockNumber"); number != nil && number.IsUint64() {
+		blockNumber := number.Uint64()
+		res.BlockNumber = &blockNumber
+		res.Timestamp = blockTimestamp(ctx, a, BlockID{Number: &blockNumber})
+	}
+	// receipts before byzantium have a state root instead of status
+	res.Status = entity.TxStatusSuccess
+	if status := mapHexBig(receipt, "status"); status != nil && status.Sign() == 0 {
+		res.Status = entity.TxStatusFailed
+	}
+	if res.To == "" {
+		res.To = mapString(receipt, "contractAddress")
+	}
+	if logs, ok := receipt["logs"]; ok {
+		// receipt logs have the json fields of LogData
+		if data, err := json.Marshal(logs); err == nil {
+			_ = json.Unmarshal(data, &res.Logs)
+		}
+	}
+
+	gasPrice := mapHexBig(receipt, "effectiveGasPrice")
+	if gasPrice == nil {
+		gasPrice = mapHexBig(tx, "gasPrice")
+	}
+	if gasUsed := mapHexBig(receipt, "gasUsed"); gasUsed != nil && gasPrice != nil {
+		fee := new(big.Int).Mul(gasUsed, gasPrice)
+		if l1Fee := mapHexBig(receipt, "l1Fee"); l1Fee != nil {
+			fee.Add(fee, l1Fee)
+		}
+		res.Fee = fee.String()
+	}
+	return res, nil
+}
+
+func (a *evmAdapter) getBlock(ctx context.Context, method string, id string, includeTxs bool) (*entity.Block, error) {
+	var data json.RawMessage
+	if err := a.call(ctx, &data, method, id, includeTxs); err != nil {
+		return nil, err
+	}
+	return newEvmBlock(data, includeTxs)
+}
+
+// GetBlocksByNumber fetches the blocks in one json-rpc batch, blocks beyond the head are nil.
+func (a *evmAdapter) GetBlocksByNumber(ctx context.Context, numbers []uint64) ([]*entity.Block, error) {
+	methodName, ok := a.chainInfo.Methods["getBlockByNumber"]
+	if !ok {
+		return nil, setting.ErrNotSupportedMethod
+	}
+
+	var batch []rpc.BatchElem
+	err := a.chainInfo.upstreams.Do(ctx, func(endpoint string) error {
+		client, release, err := a.clientPool.GetRpcClient(ctx, endpoint)
+		if err != nil {
+			return setting.ErrClientConnectionFailure
+		}
+		defer release()
+
+		batch = make([]rpc.BatchElem, 0, len(numbers))
+		for _, number := range numbers {
+			batch = append(batch, rpc.BatchElem{
+				Method: methodName,
+				Args:   []interface{}{hexutil.EncodeUint64(number), false},
+				Result: &json.RawMessage{},
+			})
+		}
+		return client.BatchCallContext(ctx, batch)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]*entity.Block, len(batch))
+	for i, elem := range batch {
+		if elem.Error != nil {
+			return nil, fmt.Errorf("failed to get block %d: %w", numbers[i], elem.Error)
+		}
+		block, err := newEvmBlock(*elem.Result.(*json.RawMessage), false)
+		if errors.Is(err, ethereum.NotFound) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		res[i] = block
+	}
+	return res, nil
+}
+
+// call resolves method name through ChainInfo.Methods, so chains with a custom namespace can reuse this adapter.
+func (a *evmAdapter) call(ctx context.Context, result interface{}, method string, args ...interface{}) error {
+	methodName, ok := a.chainInfo.Methods[method]
+	if !ok {
+		return setting.ErrNotSupportedMethod
+	}
+
+	return a.chainInfo.upstreams.Do(ctx, func(endpoint string) error {
+		client, release, err := a.clientPool.GetRpcClient(ctx, endpoint)
+		if err != nil {
+			return setting.ErrClientConnectionFailure
+		}
+		defer release()
+
+		return client.CallContext(ctx, result, methodName, args...)
+	})
+}
+
+// newEvmBlock returns ethereum.NotFound for the null block answered by nodes when the block does not exist
+func newEvmBlock(data json.RawMessage, includeTxs bool) (*entity.Block, error) {
+	var block evmBlock
+	raw, err := decodeBlock(data, &block)
+	if err != nil {
+		return nil, err
+	}
+	if raw == nil {
+		return nil, ethereum.NotFound
+	}
+
+	res := &entity.Block{
+		Number:     uint64(block.Number),
+		Hash:       block.Hash,
+		ParentHash: block.ParentHash,
+		Timestamp:  int64(block.Timestamp),
+		Producer:   block.Miner,
+		GasUsed:    (*uint64)(block.GasUsed),
+		GasLimit:   (*uint64)(block.GasLimit),
+		Raw:        raw,
+	}
+	if res.Producer == "" {
+		res.Producer = block.Reward
+	}
+	if includeTxs {
+		res.Transactions = raw["transactions"]
+	}
+	return res.WithTxCount(uint64(len(block.Transactions))), nil
+}
+
+type evmFeeHistory struct {
+	OldestBlock   hexutil.Uint64   `json:"oldestBlock"`
+	BaseFeePerGas []*hexutil.Big   `json:"baseFeePerGas"`
+	GasUsedRatio  []float64        `json:"gasUsedRatio"`
+	Reward        [][]*hexutil.Big `json:"reward"`
+}
+
+// GetGasEstimate takes priority fees from the reward percentiles of eth_feeHistory, the median of each percentile
+// over the recent non-empty blocks, and the base fee of the next block. Chains without EIP-1559 fail fee history
+// or answer it without base fee, they get the gas price of the node for every level.
+func (a *evmAdapter) GetGasEstimate(ctx context.Context) (*entity.GasEstimate, error) {
+	var history evmFeeHistory
+	err := a.call(ctx, &history, "feeHistory", hexutil.EncodeUint64(gasFeeHistoryBlocks), BlockTagLatest, gasPercentiles)
+	if err == nil && len(history.BaseFeePerGas) > 0 && len(history.Reward) > 0 {
+		if baseFee := bigToUint64(history.BaseFeePerGas[len(history.BaseFeePerGas)-1].ToInt()); baseFee > 0 {
+			return newEvmGasEstimate(history, baseFee), nil
+		}
+	}
+
+	var gasPrice hexutil.Big
+	if err := a.call(ctx, &gasPrice, "gasPrice"); err != nil {
+		return nil, err
+	}
+	price := bigToUint64(gasPrice.ToInt())
+	fee := entity.GasFee{GasPrice: &price}
+	return &entity.GasEstimate{Unit: "wei", Slow: fee, Standard: fee, Fast: fee}, nil
+}
+
+func newEvmGasEstimate(history evmFeeHistory, baseFee uint64) *entity.GasEstimate {
+	fees := make([]entity.GasFee, len(gasPercentiles))
+	for i := range gasPercentiles {
+		rewards := make([]uint64, 0, len(history.Reward))
+		for j, reward := range history.Reward {
+			// empty blocks report zero rewards
+			if i >= len(reward) || (j < len(history.GasUsedRatio) && history.GasUsedRatio[j] == 0) {
+				continue
+			}
+			rewards = append(rewards, bigToUint64(reward[i].ToInt()))
+		}
+		var (
+			base        = baseFee
+			priorityFee = percentile(rewards, 50)
+			// the max fee stays above the base fee for 6 full blocks in a row, each raising it by 12.5%
+			maxFee = 2*baseFee + priorityFee
+		)
+		fees[i] = entity.GasFee{BaseFee: &base, PriorityFee: &priorityFee, MaxFee: &maxFee}
+	}
+	return &entity.GasEstimate{
+		BlockNumber: uint64(history.OldestBlock) + uint64(len(history.Reward)) - 1,
+		Unit:        "wei",
+		Slow:        fees[0],
+		Standard:    fees[1],
+		Fast:        fees[2],
+	}
+}
+
+// evmMaxReceiptsPerBatch bounds the eth_getTransactionReceipt calls of one json-rpc batch
+const evmMaxReceiptsPerBatch = 100
+
+// GetBlockResult decodes the block with its transactions into go-ethereum types and attaches the receipts.
+// Blocks whose header or transactions go-ethereum can not decode, such as deposit transactions of op stack chains,
+// return setting.ErrNotSupportedMethod rather than a partial block.
+func (a *evmAdapter) GetBlockResult(ctx context.Context, number uint64) (*entity.BlockResult, error) {
+	var data json.RawMessage
+	if err := a.call(ctx, &data, "getBlockByNumber", hexutil.EncodeUint64(number), true); err != nil {
+		return nil, err
+	}
+	if len(data) == 0 || string(data) == "null" {
+		return nil, ethereum.NotFound
+	}
+
+	var (
+		header types.Header
+		body   struct {
+			Hash         string            `json:"hash"`
+			Transactions []json.RawMessage `json:"transactions"`
+		}
+	)
+	if err := json.Unmarshal(data, &header); err != nil {
+		return nil, fmt.Errorf("%w: block %d: %v", setting.ErrNotSupportedMethod, number, err)
+	}
+	if err := json.Unmarshal(data, &body); err != nil {
+		return nil, err
+	}
+
+	chainID := a.chainInfo.ChainID
+	if chainID == 0 {
+		var err error
+		if chainID, err = a.GetChainID(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	var (
+		txs      = make([]*types.Transaction, 0, len(body.Transactions))
+		messages = make([]types.Message, 0, len(body.Transactions))
+	)
+	for i, raw := range body.Transactions {
+		var (
+			tx     types.Transaction
+			sender struct {
+				From common.Address `json:"from"`
+			}
+		)
+		if err := tx.UnmarshalJSON(raw); err != nil {
+			return nil, fmt.Errorf("%w: transaction %d of block %d: %v", setting.ErrNotSupportedMethod, i, number, err)
+		}
+		if err := json.Unmarshal(raw, &sender); err != nil {
+			return nil, err
+		}
+		txs = append(txs, &tx)
+		messages = append(messages, newEvmMessage(&tx, sender.From, header.BaseFee))
+	}
+
+	receipts, err := a.getBlockReceipts(ctx, number, txs)
+	if err != nil {
+		return nil, err
+	}
+
+	transactionMaps := make(map[common.Hash]*entity.TransactionResult, len(txs))
+	for i, tx := range txs {
+		transactionMaps[tx.Hash()] = &entity.TransactionResult{
+			Index:       i,
+			ChainId:     strconv.FormatUint(chainID, 10),
+			Transaction: tx,
+			Message:     &messages[i],
+			Receipt:     receipts[tx.Hash()],
+		}
+	}
+	block := types.NewBlockWithHeader(&header).WithBody(txs, nil)
+	return (&entity.BlockResult{Block: block, Hash: body.Hash}).WithTransactions(transactionMaps), nil
+}
+
+// getBlockReceipts asks eth_getBlockReceipts first, nodes without it answer a json-rpc error
+// and the receipts are fetched one per transaction in batches.
+func (a *evmAdapter) getBlockReceipts(ctx context.Context, number uint64, txs []*types.Transaction) (map[common.Hash]*types.Receipt, error) {
+	var (
+		receipts []*types.Receipt
+		rpcErr   rpc.Error
+	)
+	err := a.call(ctx, &receipts, "getBlockReceipts", hexutil.EncodeUint64(number))
+	if err != nil && !errors.Is(err, setting.ErrNotSupportedMethod) && !errors.As(err, &rpcErr) {
+		return nil, err
+	}
+	if err != nil || len(receipts) != len(txs) {
+		if receipts, err = a.getTransactionReceipts(ctx, txs); err != nil {
+			return nil, err
+		}
+	}
+
+	res := make(map[common.Hash]*types.Receipt, len(receipts))
+	for _, receipt := range receipts {
+		if receipt != nil {
+			res[receipt.TxHash] = receipt
+		}
+	}
+	return res, nil
+}
+
+func (a *evmAdapter) getTransactionReceipts(ctx context.Context, txs []*types.Transaction) ([]*types.Receipt, error) {
+	methodName, ok := a.chainInfo.Methods["getTransactionReceipt"]
+	if !ok {
+		return nil, setting.ErrNotSupportedMethod
+	}
+
+	res := make([]*types.Receipt, 0, len(txs))
+	for start := 0; start < len(txs); start += evmMaxReceiptsPerBatch {
+		end := start + evmMaxReceiptsPerBatch
+		if end > len(txs) {
+			end = len(txs)
+		}
+
+		var batch []rpc.BatchElem
+		err := a.chainInfo.upstreams.Do(ctx, func(endpoint string) error {
+			client, release, err := a.clientPool.GetRpcClient(ctx, endpoint)
+			if err != nil {
+				return setting.ErrClientConnectionFailure
+			}
+			defer release()
+
+			batch = make([]rpc.BatchElem, 0, end-start)
+			for _, tx := range txs[start:end] {
+				batch = append(batch, rpc.BatchElem{
+					Method: methodName,
+					Args:   []interface{}{tx.Hash().Hex()},
+					Result: new(types.Receipt),
+				})
+			}
+			return client.BatchCallContext(ctx, batch)
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for i, elem := range batch {
+			if elem.Error != nil {
+				return nil, fmt.Errorf("failed to get receipt of transaction %s: %w", txs[start+i].Hash().Hex(), elem.Error)
+			}
+			res = append(res, elem.Result.(*types.Receipt))
+		}
+	}
+	return res, nil
+}
+
+// newEvmMessage takes the sender answered by the node instead of recovering it from the signature,
+// the gas price of dynamic fee transactions is the effective one paid in the block.
+func newEvmMessage(tx *types.Transaction, from common.Address, baseFee *big.Int) types.Message {
+	gasPrice := tx.GasPrice()
+	if baseFee != nil && tx.Type() == types.DynamicFeeTxType {
+		gasPrice = new(big.Int).Add(tx.GasTipCap(), baseFee)
+		if gasPrice.Cmp(tx.GasFeeCap()) > 0 {
+			gasPrice = tx.GasFeeCap()
+		}
+	}
+	return types.NewMessage(from, tx.To(), tx.Nonce(), tx.Value(), tx.Gas(), gasPrice, tx.GasFeeCap(), tx.GasTipCap(), tx.Data(), tx.AccessList(), false)
+}
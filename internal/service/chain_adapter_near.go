@@ -0,0 +1,305 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/eteu-technologies/near-api-go/pkg/client/block"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/tikivn/ultrago/u_logger"
+
+	"nimbus-enhance-api/internal/entity"
+	"nimbus-enhance-api/internal/infra"
+	"nimbus-enhance-api/internal/setting"
+	"nimbus-enhance-api/pkg/encoder"
+)
+
+func newNearAdapter(chainInfo ChainInfo, clientPool *infra.RpcClientPool) ChainAdapter {
+	return &nearAdapter{
+		chainInfo:  chainInfo,
+		clientPool: clientPool,
+	}
+}
+
+type nearAdapter struct {
+	chainInfo  ChainInfo
+	clientPool *infra.RpcClientPool
+}
+
+// IsTxHash checks the base58 encoded 32 bytes hash
+func (a *nearAdapter) IsTxHash(hash string) bool {
+	return len(hash) >= 42 && len(hash) <= 44 && encoder.IsBase58(hash)
+}
+
+// GetChainID is not supported because near networks are identified by name
+func (a *nearAdapter) GetChainID(ctx context.Context) (uint64, error) {
+	return 0, setting.ErrNotSupportedMethod
+}
+
+func (a *nearAdapter) GetLatestBlock(ctx context.Context) (*entity.Block, error) {
+	return a.getBlock(ctx, block.FinalityFinal(), false)
+}
+
+func (a *nearAdapter) GetLatestBlockNumber(ctx context.Context) (uint64, error) {
+	res, err := a.GetLatestBlock(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return res.Number, nil
+}
+
+// Commitments are the finality of near blocks, the latest block is the final one
+func (a *nearAdapter) Commitments() ([]string, string) {
+	return []string{CommitmentOptimistic, CommitmentFinal}, CommitmentFinal
+}
+
+func (a *nearAdapter) GetHeadBlock(ctx context.Context, commitment string) (*entity.Block, error) {
+	if commitment == CommitmentOptimistic {
+		return a.getBlock(ctx, block.FinalityOptimistic(), false)
+	}
+	return a.getBlock(ctx, block.FinalityFinal(), false)
+}
+
+func (a *nearAdapter) GetHeadNumber(ctx context.Context, commitment string) (uint64, error) {
+	res, err := a.GetHeadBlock(ctx, commitment)
+	if err != nil {
+		return 0, err
+	}
+	return res.Number, nil
+}
+
+func (a *nearAdapter) GetBlockByNumber(ctx context.Context, number uint64) (*entity.Block, error) {
+	return a.getBlock(ctx, block.BlockID(uint(number)), false)
+}
+
+// GetBlock resolves every tag except earliest to the final block, near blocks are final after two blocks.
+func (a *nearAdapter) GetBlock(ctx context.Context, id BlockID, includeTxs bool) (*entity.Block, error) {
+	switch {
+	case id.Hash != "":
+		return a.getBlock(ctx, block.BlockHashRaw(id.Hash), includeTxs)
+	case id.Number != nil:
+		return a.getBlock(ctx, block.BlockID(uint(*id.Number)), includeTxs)
+	case id.Tag == BlockTagEarliest:
+		return nil, setting.ErrNotSupportedMethod
+	default:
+		return a.getBlock(ctx, block.FinalityFinal(), includeTxs)
+	}
+}
+
+// GetTransactionByHash is not supported because near requires the sender account besides tx hash
+func (a *nearAdapter) GetTransactionByHash(ctx context.Context, hash string) (interface{}, error) {
+	return nil, setting.ErrNotSupportedMethod
+}
+
+func (a *nearAdapter) GetTransactionReceipt(ctx context.Context, hash string) (res interface{}, err error) {
+	err = a.chainInfo.upstreams.Do(ctx, func(endpoint string) error {
+		client, release, err := a.clientPool.GetRpcClient(ctx, endpoint)
+		if err != nil {
+			return setting.ErrClientConnectionFailure
+		}
+		defer release()
+
+		// TODO: think about make same interface for this type
+		// near node returns the receipt inside error data
+		var (
+			receipt interface{}
+			dataErr rpc.DataError
+		)
+		err = client.CallContext(ctx, &receipt, a.chainInfo.Methods["getTransactionReceipt"], hash)
+		if err != nil && errors.As(err, &dataErr) && dataErr.ErrorData() != nil {
+			switch dataErr.ErrorData().(type) {
+			case string:
+				return err
+			default:
+				res = dataErr.ErrorData()
+				return nil
+			}
+		} else if err != nil {
+			return err
+		}
+		if receipt != nil {
+			res = receipt
+		}
+		return nil
+	})
+	return
+}
+
+// NormalizeTransaction reads the status view of tx and EXPERIMENTAL_tx_status. The receipt view of EXPERIMENTAL_receipt
+// has no outcome, its status view is asked with the signer of the receipt, and the receipt alone answers
+// without status, fee and block when the node does not know the hash as a transaction.
+func (a *nearAdapter) NormalizeTransaction(ctx context.Context, hash string, data *TransactionData) (*entity.Transaction, error) {
+	ctx, logger := u_logger.GetLogger(ctx)
+	view, _ := data.Receipt.(map[string]interface{})
+	if view == nil {
+		return nil, fmt.Errorf("unexpected receipt %T of %s", data.Receipt, hash)
+	}
+	if _, ok := view["transaction"].(map[string]interface{}); ok {
+		return a.normalizeTxStatus(ctx, hash, view), nil
+	}
+
+	res := &entity.Transaction{Hash: hash}
+	res.To = mapString(view, "receiver_id")
+	res.From = mapString(view, "predecessor_id")
+	receipt, _ := view["receipt"].(map[string]interface{})
+	if action, ok := receipt["Action"].(map[string]interface{}); ok {
+		if signer := mapString(action, "signer_id"); signer != "" {
+			res.From = signer
+		}
+		res.Method, res.Value = nearActions(action["actions"])
+	}
+	if res.From == "" {
+		return res, nil
+	}
+	status, err := a.getTxStatus(ctx, hash, res.From)
+	if err != nil {
+		logger.Warnf("failed to get status of near transaction %s: %v", hash, err)
+		return res, nil
+	}
+	return a.normalizeTxStatus(ctx, hash, status), nil
+}
+
+// getTxStatus asks the status view of the transaction hash sent by signer
+func (a *nearAdapter) getTxStatus(ctx context.Context, hash string, signer string) (res map[string]interface{}, err error) {
+	err = a.chainInfo.upstreams.Do(ctx, func(endpoint string) error {
+		client, release, err := a.clientPool.GetRpcClient(ctx, endpoint)
+		if err != nil {
+			return setting.ErrClientConnectionFailure
+		}
+		defer release()
+		return client.CallContext(ctx, &res, "EXPERIMENTAL_tx_status", hash, signer)
+	})
+	if err == nil && res == nil {
+		err = fmt.Errorf("missing transaction %s", hash)
+	}
+	return
+}
+
+// normalizeTxStatus sums the tokens burnt by the transaction and every receipt as fee,
+// the block is the one including the transaction.
+func (a *nearAdapter) normalizeTxStatus(ctx context.Context, hash string, view map[string]interface{}) *entity.Transaction {
+	res := &entity.Transaction{Hash: hash}
+	tx, _ := view["transaction"].(map[string]interface{})
+	res.From = mapString(tx, "signer_id")
+	res.To = mapString(tx, "receiver_id")
+	res.Method, res.Value = nearActions(tx["actions"])
+
+	res.Status = entity.TxStatusSuccess
+	if status, ok := view["status"].(map[string]interface{}); ok && status["Failure"] != nil {
+		res.Status = entity.TxStatusFailed
+	}
+	outcomes, _ := view["receipts_outcome"].([]interface{})
+	outcomes = append([]interface{}{view["transaction_outcome"]}, outcomes...)
+	burnt := make([]string, 0, len(outcomes))
+	for _, item := range outcomes {
+		outcome, _ := item.(map[string]interface{})
+		if detail, ok := outcome["outcome"].(map[string]interface{}); ok {
+			burnt = append(burnt, mapString(detail, "tokens_burnt"))
+		}
+	}
+	res.Fee = sumDecimals(burnt...).String()
+	if outcome, ok := view["transaction_outcome"].(map[string]interface{}); ok {
+		if res.BlockHash = mapString(outcome, "block_hash"); res.BlockHash != "" {
+			if block, err := a.GetBlock(ctx, BlockID{Hash: res.BlockHash}, false); err == nil {
+				res.BlockNumber = &block.Number
+				res.Timestamp = block.Timestamp
+			}
+		}
+	}
+	return res
+}
+
+// nearActions names the actions, function calls by their method, and sums the deposits attached to them
+func nearActions(data interface{}) (string, string) {
+	items, _ := data.([]interface{})
+	var (
+		names    = make([]string, 0, len(items))
+		deposits = make([]string, 0, len(items))
+	)
+	for _, item := range items {
+		switch action := item.(type) {
+		case string:
+			// actions without arguments, such as CreateAccount
+			names = append(names, action)
+		case map[string]interface{}:
+			for name, value := range action {
+				args, _ := value.(map[string]interface{})
+				if method := mapString(args, "method_name"); method != "" {
+					name += ":" + method
+				}
+				names = append(names, name)
+				deposits = append(deposits, mapString(args, "deposit"))
+			}
+		}
+	}
+	return strings.Join(names, ","), sumDecimals(deposits...).String()
+}
+
+// getBlock sums gas of the chunks of the block. Transactions live in chunks, so they are only counted
+// when collected from the chunks with includeTxs.
+func (a *nearAdapter) getBlock(ctx context.Context, characteristic block.BlockCharacteristic, includeTxs bool) (res *entity.Block, err error) {
+	err = a.chainInfo.upstreams.Do(ctx, func(endpoint string) error {
+		client, err := a.clientPool.GetNearClient(endpoint)
+		if err != nil {
+			return setting.ErrClientConnectionFailure
+		}
+		blockView, err := client.BlockDetails(ctx, characteristic)
+		if isNearUnknownBlockError(err) {
+			return ethereum.NotFound
+		} else if err != nil {
+			return err
+		}
+
+		var gasUsed, gasLimit uint64
+		for _, chunk := range blockView.Chunks {
+			gasUsed += uint64(chunk.GasUsed)
+			gasLimit += uint64(chunk.GasLimit)
+		}
+		res = &entity.Block{
+			Number:     uint64(blockView.Header.Height),
+			Hash:       blockView.Header.Hash.String(),
+			ParentHash: blockView.Header.PrevHash.String(),
+			Timestamp:  int64(blockView.Header.Timestamp / uint64(time.Second)), // nanoseconds despite the field comment of near-api-go
+			Producer:   string(blockView.Author),
+			GasUsed:    &gasUsed,
+			GasLimit:   &gasLimit,
+			Raw:        blockView,
+		}
+		if !includeTxs {
+			return nil
+		}
+
+		transactions := make([]json.RawMessage, 0)
+		for _, chunk := range blockView.Chunks {
+			resp, err := client.ChunkDetails(ctx, chunk.ChunkHash)
+			if err != nil {
+				return err
+			}
+			var chunkView struct {
+				Transactions []json.RawMessage `json:"transactions"`
+			}
+			if err := json.Unmarshal(resp.Result, &chunkView); err != nil {
+				return err
+			}
+			transactions = append(transactions, chunkView.Transactions...)
+		}
+		res.Transactions = transactions
+		res.WithTxCount(uint64(len(transactions)))
+		return nil
+	})
+	return
+}
+
+// isNearUnknownBlockError reports near node answering error for missing or garbage collected block
+func isNearUnknownBlockError(err error) bool {
+	if err == nil {
+		return false
+	}
+	message := err.Error()
+	return strings.Contains(message, "UNKNOWN_BLOCK") || strings.Contains(message, "DB Not Found")
+}
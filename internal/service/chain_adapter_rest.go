@@ -0,0 +1,65 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// callRest sends a json request to the rest api of a node. Non 2xx answers are returned as rpc.HTTPError,
+// so failover treats them the same way as json-rpc nodes answering 5xx.
+func callRest(ctx context.Context, client *http.Client, method string, url string, payload interface{}, result interface{}) error {
+	var body io.Reader
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return rpc.HTTPError{
+			StatusCode: resp.StatusCode,
+			Status:     resp.Status,
+			Body:       data,
+		}
+	}
+	if err := json.Unmarshal(data, result); err != nil {
+		return fmt.Errorf("invalid response of %s: %w", url, err)
+	}
+	return nil
+}
+
+func joinUrl(endpoint string, paths ...string) string {
+	res := strings.TrimSuffix(endpoint, "/")
+	for _, path := range paths {
+		res = fmt.Sprintf("%s/%s", res, strings.Trim(path, "/"))
+	}
+	return res
+}
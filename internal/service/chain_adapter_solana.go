@@ -0,0 +1,350 @@
+package service
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum"
+
+	"github.com/portto/solana-go-sdk/client"
+	"github.com/portto/solana-go-sdk/common"
+	solanarpc "github.com/portto/solana-go-sdk/rpc"
+
+	"nimbus-enhance-api/internal/entity"
+	"nimbus-enhance-api/internal/infra"
+	"nimbus-enhance-api/internal/setting"
+	"nimbus-enhance-api/pkg/encoder"
+)
+
+// solana json-rpc error codes of slots without block
+const (
+	solanaErrBlockNotAvailable       = -32004
+	solanaErrSlotSkipped             = -32007
+	solanaErrLongTermStorageSlotSkip = -32009
+)
+
+func newSolanaAdapter(chainInfo ChainInfo, clientPool *infra.RpcClientPool) ChainAdapter {
+	return &solanaAdapter{
+		chainInfo:  chainInfo,
+		clientPool: clientPool,
+	}
+}
+
+type solanaAdapter struct {
+	chainInfo  ChainInfo
+	clientPool *infra.RpcClientPool
+}
+
+// IsTxHash checks the base58 encoded 64 bytes signature
+func (a *solanaAdapter) IsTxHash(hash string) bool {
+	return (len(hash) >= 86 && len(hash) <= 88) && encoder.IsBase58(hash)
+}
+
+// GetChainID is not supported because solana clusters have no chain id
+func (a *solanaAdapter) GetChainID(ctx context.Context) (uint64, error) {
+	return 0, setting.ErrNotSupportedMethod
+}
+
+func (a *solanaAdapter) GetLatestBlock(ctx context.Context) (res *entity.Block, err error) {
+	err = a.chainInfo.upstreams.Do(ctx, func(endpoint string) error {
+		client := a.clientPool.GetSolanaClient(endpoint)
+		latestBlockNumber, err := client.GetSlot(ctx)
+		if err != nil {
+			return err
+		}
+		res, err = a.getBlock(ctx, client, latestBlockNumber, false, "")
+		return err
+	})
+	return
+}
+
+func (a *solanaAdapter) GetLatestBlockNumber(ctx context.Context) (res uint64, err error) {
+	err = a.chainInfo.upstreams.Do(ctx, func(endpoint string) error {
+		res, err = a.clientPool.GetSolanaClient(endpoint).GetSlot(ctx)
+		return err
+	})
+	return
+}
+
+// Commitments are the commitment levels of solana slots, the latest block is the finalized one
+func (a *solanaAdapter) Commitments() ([]string, string) {
+	return []string{CommitmentProcessed, CommitmentConfirmed, CommitmentFinalized}, CommitmentFinalized
+}
+
+// GetHeadBlock does not support processed, getBlock serves confirmed and finalized blocks only.
+// The processed slot is still answered by GetHeadNumber.
+func (a *solanaAdapter) GetHeadBlock(ctx context.Context, commitment string) (res *entity.Block, err error) {
+	if commitment == CommitmentProcessed {
+		return nil, fmt.Errorf("%w: solana serves no block at commitment %s, only its slot height", setting.ErrNotSupportedMethod, commitment)
+	}
+	err = a.chainInfo.upstreams.Do(ctx, func(endpoint string) error {
+		client := a.clientPool.GetSolanaClient(endpoint)
+		slot, err := client.GetSlotWithConfig(ctx, solanarpc.GetSlotConfig{Commitment: solanarpc.Commitment(commitment)})
+		if err != nil {
+			return err
+		}
+		res, err = a.getBlock(ctx, client, slot, false, solanarpc.Commitment(commitment))
+		return err
+	})
+	return
+}
+
+func (a *solanaAdapter) GetHeadNumber(ctx context.Context, commitment string) (res uint64, err error) {
+	err = a.chainInfo.upstreams.Do(ctx, func(endpoint string) error {
+		res, err = a.clientPool.GetSolanaClient(endpoint).GetSlotWithConfig(ctx, solanarpc.GetSlotConfig{Commitment: solanarpc.Commitment(commitment)})
+		return err
+	})
+	return
+}
+
+func (a *solanaAdapter) GetBlockByNumber(ctx context.Context, number uint64) (res *entity.Block, err error) {
+	err = a.chainInfo.upstreams.Do(ctx, func(endpoint string) error {
+		res, err = a.getBlock(ctx, a.clientPool.GetSolanaClient(endpoint), number, false, "")
+		return err
+	})
+	return
+}
+
+// GetBlock addresses blocks by slot, solana rpc has no lookup by blockhash.
+// latest and safe resolve to the confirmed slot, finalized to the finalized slot.
+func (a *solanaAdapter) GetBlock(ctx context.Context, id BlockID, includeTxs bool) (res *entity.Block, err error) {
+	if id.Hash != "" {
+		return nil, setting.ErrNotSupportedMethod
+	}
+
+	err = a.chainInfo.upstreams.Do(ctx, func(endpoint string) error {
+		var (
+			client = a.clientPool.GetSolanaClient(endpoint)
+			slot   uint64
+			err    error
+		)
+		switch {
+		case id.Number != nil:
+			slot = *id.Number
+		case id.Tag == BlockTagEarliest:
+			slot, err = client.GetFirstAvailableBlock(ctx)
+		case id.Tag == BlockTagFinalized:
+			slot, err = client.GetSlotWithConfig(ctx, solanarpc.GetSlotConfig{Commitment: solanarpc.CommitmentFinalized})
+		default:
+			slot, err = client.GetSlotWithConfig(ctx, solanarpc.GetSlotConfig{Commitment: solanarpc.CommitmentConfirmed})
+		}
+		if err != nil {
+			return err
+		}
+
+		res, err = a.getBlock(ctx, client, slot, includeTxs, solanarpc.CommitmentConfirmed)
+		return err
+	})
+	return
+}
+
+func (a *solanaAdapter) GetTransactionByHash(ctx context.Context, hash string) (res interface{}, err error) {
+	err = a.chainInfo.upstreams.Do(ctx, func(endpoint string) error {
+		client := a.clientPool.GetSolanaClient(endpoint)
+		tx, err := client.GetTransaction(ctx, hash)
+		if err == nil && tx != nil {
+			res = tx
+		}
+		return err
+	})
+	return
+}
+
+// GetTransactionReceipt is not supported because transaction meta is already returned with the transaction
+func (a *solanaAdapter) GetTransactionReceipt(ctx context.Context, hash string) (interface{}, error) {
+	return nil, setting.ErrNotSupportedMethod
+}
+
+// solanaProgramNames labels the instructions of well-known programs in the method of normalized transactions
+var solanaProgramNames = map[common.PublicKey]string{
+	common.SystemProgramID:                    "system",
+	common.StakeProgramID:                     "stake",
+	common.VoteProgramID:                      "vote",
+	common.TokenProgramID:                     "token",
+	common.MemoProgramID:                      "memo",
+	common.SPLAssociatedTokenAccountProgramID: "associated_token",
+	common.ComputeBudgetProgramID:             "compute_budget",
+	common.AddressLookupTableProgramID:        "address_lookup_table",
+}
+
+// solanaSystemTransfer is the index of the transfer instruction of the system program
+const solanaSystemTransfer = 2
+
+// NormalizeTransaction takes the fee payer as sender. Value and recipient are read from the system transfers,
+// the method lists the programs called by the top-level instructions.
+func (a *solanaAdapter) NormalizeTransaction(ctx context.Context, hash string, data *TransactionData) (*entity.Transaction, error) {
+	tx, ok := data.Tx.(*client.GetTransactionResponse)
+	if !ok || tx == nil {
+		return nil, fmt.Errorf("unexpected transaction %T of %s", data.Tx, hash)
+	}
+
+	var (
+		message = tx.Transaction.Message
+		slot    = tx.Slot
+		value   uint64
+		methods = make([]string, 0, len(message.Instructions))
+		res     = &entity.Transaction{
+			Hash:        hash,
+			BlockNumber: &slot,
+			Status:      entity.TxStatusSuccess,
+		}
+	)
+	if tx.BlockTime != nil {
+		res.Timestamp = *tx.BlockTime
+	}
+	if len(message.Accounts) > 0 {
+		res.From = message.Accounts[0].ToBase58()
+	}
+	if tx.Meta != nil {
+		res.Fee = strconv.FormatUint(tx.Meta.Fee, 10)
+		if tx.Meta.Err != nil {
+			res.Status = entity.TxStatusFailed
+		}
+	}
+
+	for _, instruction := range message.Instructions {
+		// accounts of address lookup tables are not listed in the message
+		if instruction.ProgramIDIndex >= len(message.Accounts) {
+			methods = append(methods, "unknown")
+			continue
+		}
+		program := message.Accounts[instruction.ProgramIDIndex]
+		name, ok := solanaProgramNames[program]
+		if !ok {
+			methods = append(methods, program.ToBase58())
+			continue
+		}
+		if program == common.SystemProgramID && len(instruction.Data) == 12 &&
+			binary.LittleEndian.Uint32(instruction.Data) == solanaSystemTransfer {
+			value += binary.LittleEndian.Uint64(instruction.Data[4:])
+			if len(instruction.Accounts) > 1 && instruction.Accounts[1] < len(message.Accounts) && res.To == "" {
+				res.To = message.Accounts[instruction.Accounts[1]].ToBase58()
+			}
+			name += ":transfer"
+		}
+		methods = append(methods, name)
+	}
+	if res.To != "" {
+		res.Value = strconv.FormatUint(value, 10)
+	}
+	res.Method = strings.Join(methods, ",")
+	return res, nil
+}
+
+// getBlock asks signatures only unless includeTxs, so the tx count is known without decoding transactions.
+// Slots without block, skipped or pruned, return ethereum.NotFound.
+func (a *solanaAdapter) getBlock(
+	ctx context.Context,
+	client *client.Client,
+	slot uint64,
+	includeTxs bool,
+	commitment solanarpc.Commitment,
+) (*entity.Block, error) {
+	var (
+		maxSupportedTransactionVersion uint8 = 0
+		rewards                              = false
+		transactionDetails                   = solanarpc.GetBlockConfigTransactionDetailsSignatures
+	)
+	if includeTxs {
+		transactionDetails = solanarpc.GetBlockConfigTransactionDetailsFull
+	}
+	resp, err := client.RpcClient.GetBlockWithConfig(
+		ctx,
+		slot,
+		solanarpc.GetBlockConfig{
+			Encoding:                       solanarpc.GetBlockConfigEncodingJsonParsed,
+			TransactionDetails:             transactionDetails,
+			Commitment:                     commitment,
+			MaxSupportedTransactionVersion: &maxSupportedTransactionVersion,
+			Rewards:                        &rewards,
+		},
+	)
+	if err == nil && resp.Error != nil {
+		err = resp.Error
+	}
+	if isSolanaErrorCode(err, solanaErrBlockNotAvailable, solanaErrSlotSkipped, solanaErrLongTermStorageSlotSkip) {
+		return nil, ethereum.NotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	block := resp.Result
+	res := &entity.Block{
+		Number:     slot,
+		Hash:       block.Blockhash,
+		ParentHash: block.PreviousBlockhash,
+		Raw:        block,
+	}
+	if block.BlockTime != nil {
+		res.Timestamp = *block.BlockTime
+	}
+	if includeTxs {
+		res.Transactions = block.Transactions
+		return res.WithTxCount(uint64(len(block.Transactions))), nil
+	}
+	return res.WithTxCount(uint64(len(block.Signatures))), nil
+}
+
+func isSolanaErrorCode(err error, codes ...int) bool {
+	var rpcErr *solanarpc.JsonRpcError
+	if !errors.As(err, &rpcErr) {
+		return false
+	}
+	for _, code := range codes {
+		if rpcErr.Code == code {
+			return true
+		}
+	}
+	return false
+}
+
+type solanaPrioritizationFee struct {
+	Slot              uint64 `json:"slot"`
+	PrioritizationFee uint64 `json:"prioritizationFee"`
+}
+
+// GetGasEstimate takes percentiles of the priority fees per compute unit paid in the recent slots
+// the node keeps, about the last 150. The solana sdk has no getRecentPrioritizationFees, so it is called raw.
+func (a *solanaAdapter) GetGasEstimate(ctx context.Context) (res *entity.GasEstimate, err error) {
+	err = a.chainInfo.upstreams.Do(ctx, func(endpoint string) error {
+		body, err := a.clientPool.GetSolanaClient(endpoint).RpcClient.Call(ctx, "getRecentPrioritizationFees")
+		if err != nil {
+			return err
+		}
+		var resp solanarpc.JsonRpcResponse[[]solanaPrioritizationFee]
+		if err := json.Unmarshal(body, &resp); err != nil {
+			return err
+		}
+		if resp.Error != nil {
+			return resp.Error
+		}
+		res = newSolanaGasEstimate(resp.Result)
+		return nil
+	})
+	return
+}
+
+func newSolanaGasEstimate(items []solanaPrioritizationFee) *entity.GasEstimate {
+	var (
+		res  = &entity.GasEstimate{Unit: "micro_lamports"}
+		fees = make([]uint64, 0, len(items))
+	)
+	for _, item := range items {
+		fees = append(fees, item.PrioritizationFee)
+		if item.Slot > res.BlockNumber {
+			res.BlockNumber = item.Slot
+		}
+	}
+	levels := make([]entity.GasFee, len(gasPercentiles))
+	for i, p := range gasPercentiles {
+		fee := percentile(fees, p)
+		levels[i] = entity.GasFee{PriorityFee: &fee}
+	}
+	res.Slow, res.Standard, res.Fast = levels[0], levels[1], levels[2]
+	return res
+}
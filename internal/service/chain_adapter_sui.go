@@ -0,0 +1,246 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/rpc"
+
+	"nimbus-enhance-api/internal/entity"
+	"nimbus-enhance-api/internal/infra"
+	"nimbus-enhance-api/internal/setting"
+	"nimbus-enhance-api/pkg/encoder"
+)
+
+// sui nodes limit the digests of sui_multiGetTransactionBlocks
+const suiMaxTransactionsPerCall = 50
+
+// sui encodes u64 as json string
+type suiCheckpoint struct {
+	SequenceNumber uint64   `json:"sequenceNumber,string"`
+	Digest         string   `json:"digest"`
+	PreviousDigest string   `json:"previousDigest"`
+	TimestampMs    int64    `json:"timestampMs,string"`
+	Transactions   []string `json:"transactions"`
+}
+
+// newSuiAdapter serves checkpoints as blocks, because sui has no block concept.
+func newSuiAdapter(chainInfo ChainInfo, clientPool *infra.RpcClientPool) ChainAdapter {
+	return &suiAdapter{
+		chainInfo:  chainInfo,
+		clientPool: clientPool,
+	}
+}
+
+type suiAdapter struct {
+	chainInfo  ChainInfo
+	clientPool *infra.RpcClientPool
+}
+
+// IsTxHash checks the base58 encoded 32 bytes transaction digest
+func (a *suiAdapter) IsTxHash(hash string) bool {
+	return (len(hash) == 43 || len(hash) == 44) && encoder.IsBase58(hash)
+}
+
+// GetChainID is not supported because sui identifies networks by the digest of the genesis checkpoint
+func (a *suiAdapter) GetChainID(ctx context.Context) (uint64, error) {
+	return 0, setting.ErrNotSupportedMethod
+}
+
+func (a *suiAdapter) GetLatestBlock(ctx context.Context) (*entity.Block, error) {
+	number, err := a.GetLatestBlockNumber(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return a.GetBlockByNumber(ctx, number)
+}
+
+func (a *suiAdapter) GetLatestBlockNumber(ctx context.Context) (uint64, error) {
+	var res string
+	if err := a.call(ctx, &res, "getLatestBlockNumber"); err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(res, 10, 64)
+}
+
+func (a *suiAdapter) GetBlockByNumber(ctx context.Context, number uint64) (*entity.Block, error) {
+	return a.getCheckpoint(ctx, strconv.FormatUint(number, 10))
+}
+
+// GetBlock looks up checkpoints by sequence number or digest, checkpoints are final so every tag
+// except earliest is the latest checkpoint. Transactions are fetched by the digests of the checkpoint when included.
+func (a *suiAdapter) GetBlock(ctx context.Context, id BlockID, includeTxs bool) (*entity.Block, error) {
+	var checkpointID string
+	switch {
+	case id.Hash != "":
+		checkpointID = id.Hash
+	case id.Number != nil:
+		checkpointID = strconv.FormatUint(*id.Number, 10)
+	case id.Tag == BlockTagEarliest:
+		checkpointID = "0"
+	default:
+		number, err := a.GetLatestBlockNumber(ctx)
+		if err != nil {
+			return nil, err
+		}
+		checkpointID = strconv.FormatUint(number, 10)
+	}
+
+	res, err := a.getCheckpoint(ctx, checkpointID)
+	if err != nil || !includeTxs {
+		return res, err
+	}
+
+	digests, _ := res.Raw.(map[string]interface{})["transactions"].([]interface{})
+	transactions := make([]interface{}, 0, len(digests))
+	options := map[string]bool{
+		"showInput":   true,
+		"showEffects": true,
+		"showEvents":  true,
+	}
+	for start := 0; start < len(digests); start += suiMaxTransactionsPerCall {
+		end := start + suiMaxTransactionsPerCall
+		if end > len(digests) {
+			end = len(digests)
+		}
+		var items []interface{}
+		if err := a.call(ctx, &items, "getTransactionsByHash", digests[start:end], options); err != nil {
+			return nil, err
+		}
+		transactions = append(transactions, items...)
+	}
+	res.Transactions = transactions
+	return res, nil
+}
+
+// GetTransactionByHash returns the transaction with its effects and events, so there is no separated receipt.
+func (a *suiAdapter) GetTransactionByHash(ctx context.Context, hash string) (interface{}, error) {
+	var res map[string]interface{}
+	options := map[string]bool{
+		"showInput":          true,
+		"showEffects":        true,
+		"showEvents":         true,
+		"showBalanceChanges": true,
+	}
+	err := a.call(ctx, &res, "getTransactionByHash", hash, options)
+	if isSuiNotFoundError(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// GetTransactionReceipt is not supported because effects are already returned with the transaction
+func (a *suiAdapter) GetTransactionReceipt(ctx context.Context, hash string) (interface{}, error) {
+	return nil, setting.ErrNotSupportedMethod
+}
+
+// getCheckpoint has no producer because checkpoints are certified by the validator committee
+func (a *suiAdapter) getCheckpoint(ctx context.Context, checkpointID string) (*entity.Block, error) {
+	var data json.RawMessage
+	err := a.call(ctx, &data, "getBlockByNumber", checkpointID)
+	if isSuiNotFoundError(err) {
+		return nil, ethereum.NotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return newSuiBlock(data)
+}
+
+// GetBlocksByNumber fetches the checkpoints in one json-rpc batch, checkpoints not created yet are nil.
+func (a *suiAdapter) GetBlocksByNumber(ctx context.Context, numbers []uint64) ([]*entity.Block, error) {
+	methodName, ok := a.chainInfo.Methods["getBlockByNumber"]
+	if !ok {
+		return nil, setting.ErrNotSupportedMethod
+	}
+
+	var batch []rpc.BatchElem
+	err := a.chainInfo.upstreams.Do(ctx, func(endpoint string) error {
+		client, release, err := a.clientPool.GetRpcClient(ctx, endpoint)
+		if err != nil {
+			return setting.ErrClientConnectionFailure
+		}
+		defer release()
+
+		batch = make([]rpc.BatchElem, 0, len(numbers))
+		for _, number := range numbers {
+			batch = append(batch, rpc.BatchElem{
+				Method: methodName,
+				Args:   []interface{}{strconv.FormatUint(number, 10)},
+				Result: &json.RawMessage{},
+			})
+		}
+		return client.BatchCallContext(ctx, batch)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]*entity.Block, len(batch))
+	for i, elem := range batch {
+		if isSuiNotFoundError(elem.Error) {
+			continue
+		} else if elem.Error != nil {
+			return nil, fmt.Errorf("failed to get checkpoint %d: %w", numbers[i], elem.Error)
+		}
+		block, err := newSuiBlock(*elem.Result.(*json.RawMessage))
+		if err != nil && !errors.Is(err, ethereum.NotFound) {
+			return nil, err
+		}
+		res[i] = block
+	}
+	return res, nil
+}
+
+func newSuiBlock(data json.RawMessage) (*entity.Block, error) {
+	var checkpoint suiCheckpoint
+	raw, err := decodeBlock(data, &checkpoint)
+	if err != nil {
+		return nil, err
+	}
+	if raw == nil {
+		return nil, ethereum.NotFound
+	}
+	res := &entity.Block{
+		Number:     checkpoint.SequenceNumber,
+		Hash:       checkpoint.Digest,
+		ParentHash: checkpoint.PreviousDigest,
+		Timestamp:  checkpoint.TimestampMs / int64(time.Second/time.Millisecond),
+		Raw:        raw,
+	}
+	return res.WithTxCount(uint64(len(checkpoint.Transactions))), nil
+}
+
+func (a *suiAdapter) call(ctx context.Context, result interface{}, method string, args ...interface{}) error {
+	methodName, ok := a.chainInfo.Methods[method]
+	if !ok {
+		return setting.ErrNotSupportedMethod
+	}
+
+	return a.chainInfo.upstreams.Do(ctx, func(endpoint string) error {
+		client, release, err := a.clientPool.GetRpcClient(ctx, endpoint)
+		if err != nil {
+			return setting.ErrClientConnectionFailure
+		}
+		defer release()
+
+		return client.CallContext(ctx, result, methodName, args...)
+	})
+}
+
+// isSuiNotFoundError reports sui node answering json-rpc error for missing checkpoint or transaction
+func isSuiNotFoundError(err error) bool {
+	var rpcErr rpc.Error
+	if !errors.As(err, &rpcErr) {
+		return false
+	}
+	message := strings.ToLower(rpcErr.Error())
+	return strings.Contains(message, "could not find") || strings.Contains(message, "not found")
+}
@@ -0,0 +1,159 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+
+	"nimbus-enhance-api/internal/entity"
+	"nimbus-enhance-api/internal/infra"
+	"nimbus-enhance-api/internal/setting"
+	"nimbus-enhance-api/pkg/encoder"
+)
+
+// newTronAdapter calls the http api of tron full node, Methods maps each call to its path.
+// Tron answers an empty object instead of an error when the block or transaction does not exist.
+func newTronAdapter(chainInfo ChainInfo, clientPool *infra.RpcClientPool) ChainAdapter {
+	return &tronAdapter{
+		chainInfo:  chainInfo,
+		clientPool: clientPool,
+	}
+}
+
+type tronAdapter struct {
+	chainInfo  ChainInfo
+	clientPool *infra.RpcClientPool
+}
+
+type tronBlockHeader struct {
+	BlockHeader struct {
+		RawData struct {
+			Number uint64 `json:"number"`
+		} `json:"raw_data"`
+	} `json:"block_header"`
+}
+
+type tronBlock struct {
+	BlockID     string `json:"blockID"`
+	BlockHeader struct {
+		RawData struct {
+			Number         uint64 `json:"number"`
+			ParentHash     string `json:"parentHash"`
+			Timestamp      int64  `json:"timestamp"` // milliseconds
+			WitnessAddress string `json:"witness_address"`
+		} `json:"raw_data"`
+	} `json:"block_header"`
+	Transactions []json.RawMessage `json:"transactions"`
+}
+
+// IsTxHash checks the 64 hex tx id without 0x prefix
+func (a *tronAdapter) IsTxHash(hash string) bool {
+	return len(hash) == 64 && encoder.IsHex(hash)
+}
+
+// GetChainID is not supported by the http api of tron
+func (a *tronAdapter) GetChainID(ctx context.Context) (uint64, error) {
+	return 0, setting.ErrNotSupportedMethod
+}
+
+func (a *tronAdapter) GetLatestBlock(ctx context.Context) (*entity.Block, error) {
+	return a.getBlock(ctx, "getLatestBlock", nil, false)
+}
+
+func (a *tronAdapter) GetLatestBlockNumber(ctx context.Context) (uint64, error) {
+	var res tronBlockHeader
+	if err := a.call(ctx, &res, "getLatestBlock", nil); err != nil {
+		return 0, err
+	}
+	return res.BlockHeader.RawData.Number, nil
+}
+
+func (a *tronAdapter) GetBlockByNumber(ctx context.Context, number uint64) (*entity.Block, error) {
+	return a.getBlock(ctx, "getBlockByNumber", map[string]interface{}{"num": number}, false)
+}
+
+// GetBlock resolves every tag except earliest to the latest block
+func (a *tronAdapter) GetBlock(ctx context.Context, id BlockID, includeTxs bool) (*entity.Block, error) {
+	switch {
+	case id.Hash != "":
+		return a.getBlock(ctx, "getBlockByHash", map[string]interface{}{"value": id.Hash}, includeTxs)
+	case id.Number != nil:
+		return a.getBlock(ctx, "getBlockByNumber", map[string]interface{}{"num": *id.Number}, includeTxs)
+	case id.Tag == BlockTagEarliest:
+		return a.getBlock(ctx, "getBlockByNumber", map[string]interface{}{"num": 0}, includeTxs)
+	default:
+		return a.getBlock(ctx, "getLatestBlock", nil, includeTxs)
+	}
+}
+
+func (a *tronAdapter) GetTransactionByHash(ctx context.Context, hash string) (interface{}, error) {
+	var res map[string]interface{}
+	if err := a.call(ctx, &res, "getTransactionByHash", map[string]interface{}{"value": hash}); err != nil {
+		return nil, err
+	}
+	if len(res) == 0 {
+		return nil, nil
+	}
+	return res, nil
+}
+
+// GetTransactionReceipt returns the transaction info holding fee, energy usage, result and logs
+func (a *tronAdapter) GetTransactionReceipt(ctx context.Context, hash string) (interface{}, error) {
+	var res map[string]interface{}
+	if err := a.call(ctx, &res, "getTransactionReceipt", map[string]interface{}{"value": hash}); err != nil {
+		return nil, err
+	}
+	if len(res) == 0 {
+		return nil, nil
+	}
+	return res, nil
+}
+
+// getBlock drops the transactions of the raw block unless includeTxs, the same as blocks of other chains.
+// The producer is the hex address of the super representative signing the block.
+func (a *tronAdapter) getBlock(ctx context.Context, method string, payload interface{}, includeTxs bool) (*entity.Block, error) {
+	var data json.RawMessage
+	if err := a.call(ctx, &data, method, payload); err != nil {
+		return nil, err
+	}
+
+	var block tronBlock
+	raw, err := decodeBlock(data, &block)
+	if err != nil {
+		return nil, err
+	}
+	if len(raw) == 0 {
+		return nil, ethereum.NotFound
+	}
+	res := &entity.Block{
+		Number:     block.BlockHeader.RawData.Number,
+		Hash:       block.BlockID,
+		ParentHash: block.BlockHeader.RawData.ParentHash,
+		Timestamp:  block.BlockHeader.RawData.Timestamp / int64(time.Second/time.Millisecond),
+		Producer:   block.BlockHeader.RawData.WitnessAddress,
+		Raw:        raw,
+	}
+	if includeTxs {
+		res.Transactions = raw["transactions"]
+	} else {
+		delete(raw, "transactions")
+	}
+	return res.WithTxCount(uint64(len(block.Transactions))), nil
+}
+
+func (a *tronAdapter) call(ctx context.Context, result interface{}, method string, payload interface{}) error {
+	path, ok := a.chainInfo.Methods[method]
+	if !ok {
+		return setting.ErrNotSupportedMethod
+	}
+	if payload == nil {
+		payload = map[string]interface{}{}
+	}
+
+	return a.chainInfo.upstreams.Do(ctx, func(endpoint string) error {
+		return callRest(ctx, a.clientPool.GetHttpClient(), http.MethodPost, joinUrl(endpoint, path), payload, result)
+	})
+}
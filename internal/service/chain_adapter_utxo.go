@@ -0,0 +1,476 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/rpc"
+
+	"nimbus-enhance-api/internal/entity"
+	"nimbus-enhance-api/internal/infra"
+	"nimbus-enhance-api/internal/setting"
+	"nimbus-enhance-api/pkg/encoder"
+)
+
+const (
+	// bitcoind error codes of missing transaction and block height out of range
+	utxoErrInvalidAddressOrKey = -5
+	utxoErrInvalidParameter    = -8
+
+	satoshiPerCoin = 100_000_000
+)
+
+// newUtxoAdapter serves bitcoin and its forks (litecoin, dogecoin) through bitcoind json-rpc.
+// Transactions are returned with inputs resolved from their previous outputs, so addresses and values are known.
+func newUtxoAdapter(chainInfo ChainInfo, clientPool *infra.RpcClientPool) ChainAdapter {
+	return &utxoAdapter{
+		chainInfo:  chainInfo,
+		clientPool: clientPool,
+	}
+}
+
+type utxoAdapter struct {
+	chainInfo  ChainInfo
+	clientPool *infra.RpcClientPool
+}
+
+type utxoBlockchainInfo struct {
+	Blocks        uint64 `json:"blocks"`
+	BestBlockHash string `json:"bestblockhash"`
+}
+
+type utxoBlock struct {
+	Hash              string `json:"hash"`
+	Height            uint64 `json:"height"`
+	PreviousBlockHash string `json:"previousblockhash"`
+	Time              int64  `json:"time"`
+	NTx               uint64 `json:"nTx"`
+
+	Tx []json.RawMessage `json:"tx"` // dogecoin nodes do not answer nTx
+}
+
+type utxoTransaction struct {
+	Txid string     `json:"txid"`
+	Vin  []utxoVin  `json:"vin"`
+	Vout []utxoVout `json:"vout"`
+}
+
+type utxoVin struct {
+	Txid     string `json:"txid"`
+	Vout     uint32 `json:"vout"`
+	Coinbase string `json:"coinbase"`
+}
+
+type utxoVout struct {
+	Value        json.Number `json:"value"`
+	N            uint32      `json:"n"`
+	ScriptPubKey struct {
+		Type      string   `json:"type"`
+		Address   string   `json:"address"`
+		Addresses []string `json:"addresses"` // nodes before bitcoin core v22
+	} `json:"scriptPubKey"`
+}
+
+// UtxoInput is an input with the address and value of the output it spends, coinbase inputs have neither.
+type UtxoInput struct {
+	Txid     string `json:"txid,omitempty"`
+	Vout     uint32 `json:"vout"`
+	Coinbase bool   `json:"coinbase"`
+	Address  string `json:"address,omitempty"`
+	Value    string `json:"value,omitempty"`
+	ValueSat int64  `json:"value_sat"`
+	// Unresolved inputs spend an output the node did not answer, they have no address nor value
+	Unresolved bool `json:"unresolved,omitempty"`
+}
+
+type UtxoOutput struct {
+	N        uint32 `json:"n"`
+	Type     string `json:"type"`
+	Address  string `json:"address,omitempty"`
+	Value    string `json:"value"`
+	ValueSat int64  `json:"value_sat"`
+}
+
+// IsTxHash checks the 64 hex tx id without 0x prefix
+func (a *utxoAdapter) IsTxHash(hash string) bool {
+	return len(hash) == 64 && encoder.IsHex(hash)
+}
+
+// GetChainID is not supported because utxo chains have no chain id
+func (a *utxoAdapter) GetChainID(ctx context.Context) (uint64, error) {
+	return 0, setting.ErrNotSupportedMethod
+}
+
+func (a *utxoAdapter) GetLatestBlock(ctx context.Context) (*entity.Block, error) {
+	info, err := a.getBlockchainInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return a.getBlock(ctx, info.BestBlockHash, false)
+}
+
+func (a *utxoAdapter) GetLatestBlockNumber(ctx context.Context) (uint64, error) {
+	info, err := a.getBlockchainInfo(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return info.Blocks, nil
+}
+
+func (a *utxoAdapter) GetBlockByNumber(ctx context.Context, number uint64) (*entity.Block, error) {
+	var hash string
+	err := a.call(ctx, &hash, "getBlockHash", number)
+	if isUtxoErrorCode(err, utxoErrInvalidParameter) {
+		return nil, ethereum.NotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return a.getBlock(ctx, hash, false)
+}
+
+// GetBlock resolves every tag except earliest to the best block, transactions are included decoded.
+func (a *utxoAdapter) GetBlock(ctx context.Context, id BlockID, includeTxs bool) (*entity.Block, error) {
+	hash := id.Hash
+	switch {
+	case hash != "":
+	case id.Number != nil, id.Tag == BlockTagEarliest:
+		var number uint64
+		if id.Number != nil {
+			number = *id.Number
+		}
+		err := a.call(ctx, &hash, "getBlockHash", number)
+		if isUtxoErrorCode(err, utxoErrInvalidParameter) {
+			return nil, ethereum.NotFound
+		} else if err != nil {
+			return nil, err
+		}
+	default:
+		info, err := a.getBlockchainInfo(ctx)
+		if err != nil {
+			return nil, err
+		}
+		hash = info.BestBlockHash
+	}
+	return a.getBlock(ctx, hash, includeTxs)
+}
+
+func (a *utxoAdapter) GetTransactionByHash(ctx context.Context, hash string) (res interface{}, err error) {
+	methodName, ok := a.chainInfo.Methods["getTransactionByHash"]
+	if !ok {
+		return nil, setting.ErrNotSupportedMethod
+	}
+
+	err = a.chainInfo.upstreams.Do(ctx, func(endpoint string) error {
+		client, release, err := a.clientPool.GetRpcClient(ctx, endpoint)
+		if err != nil {
+			return setting.ErrClientConnectionFailure
+		}
+		defer release()
+
+		var raw json.RawMessage
+		err = client.CallContext(ctx, &raw, methodName, hash, true)
+		if isUtxoErrorCode(err, utxoErrInvalidAddressOrKey) {
+			res = nil
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		res, err = a.resolveTransaction(ctx, client, methodName, raw)
+		return err
+	})
+	return
+}
+
+// GetTransactionReceipt is not supported because utxo transactions have no receipt
+func (a *utxoAdapter) GetTransactionReceipt(ctx context.Context, hash string) (interface{}, error) {
+	return nil, setting.ErrNotSupportedMethod
+}
+
+// utxoMaxPrevTxsPerBatch bounds the previous transactions fetched in one json-rpc batch,
+// consolidations spend thousands of outputs and nodes refuse batches that large.
+const utxoMaxPrevTxsPerBatch = 100
+
+// resolveTransaction adds inputs with the outputs they spend, outputs and fee to the verbose transaction.
+// Previous transactions are fetched in batches on the same upstream, inputs of a failing batch are unresolved
+// and the transaction has no fee then.
+func (a *utxoAdapter) resolveTransaction(ctx context.Context, client *rpc.Client, methodName string, raw json.RawMessage) (map[string]interface{}, error) {
+	var (
+		res map[string]interface{}
+		tx  utxoTransaction
+	)
+	if err := json.Unmarshal(raw, &res); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(raw, &tx); err != nil {
+		return nil, err
+	}
+
+	var (
+		batch   = make([]rpc.BatchElem, 0, len(tx.Vin))
+		prevIdx = make(map[string]int, len(tx.Vin)) // index of previous transaction in batch
+	)
+	for _, vin := range tx.Vin {
+		if _, ok := prevIdx[vin.Txid]; ok || vin.Coinbase != "" {
+			continue
+		}
+		prevIdx[vin.Txid] = len(batch)
+		batch = append(batch, rpc.BatchElem{
+			Method: methodName,
+			Args:   []interface{}{vin.Txid, true},
+			Result: &utxoTransaction{},
+		})
+	}
+	for start := 0; start < len(batch); start += utxoMaxPrevTxsPerBatch {
+		end := start + utxoMaxPrevTxsPerBatch
+		if end > len(batch) {
+			end = len(batch)
+		}
+		if err := client.BatchCallContext(ctx, batch[start:end]); err != nil {
+			if ctx.Err() != nil {
+				return nil, err
+			}
+			for i := start; i < end; i++ {
+				batch[i].Error = err
+			}
+		}
+	}
+
+	var (
+		inputs            = make([]*UtxoInput, 0, len(tx.Vin))
+		outputs           = make([]*UtxoOutput, 0, len(tx.Vout))
+		totalIn, totalOut int64
+		resolved          = true
+	)
+	for i, vin := range tx.Vin {
+		input := &UtxoInput{
+			Txid:     vin.Txid,
+			Vout:     vin.Vout,
+			Coinbase: vin.Coinbase != "",
+		}
+		if input.Coinbase {
+			inputs = append(inputs, input)
+			continue
+		}
+
+		prev := batch[prevIdx[vin.Txid]]
+		prevTx := prev.Result.(*utxoTransaction)
+		if prev.Error != nil || int(vin.Vout) >= len(prevTx.Vout) {
+			// node without txindex can not find spent transactions
+			resolved = false
+			input.Unresolved = true
+			inputs = append(inputs, input)
+			continue
+		}
+		prevOut := prevTx.Vout[vin.Vout]
+		valueSat, err := toSatoshi(prevOut.Value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value of input %d: %w", i, err)
+		}
+		input.Address = prevOut.address()
+		input.Value = prevOut.Value.String()
+		input.ValueSat = valueSat
+		totalIn += valueSat
+		inputs = append(inputs, input)
+	}
+	for _, vout := range tx.Vout {
+		valueSat, err := toSatoshi(vout.Value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value of output %d: %w", vout.N, err)
+		}
+		outputs = append(outputs, &UtxoOutput{
+			N:        vout.N,
+			Type:     vout.ScriptPubKey.Type,
+			Address:  vout.address(),
+			Value:    vout.Value.String(),
+			ValueSat: valueSat,
+		})
+		totalOut += valueSat
+	}
+
+	res["inputs"] = inputs
+	res["outputs"] = outputs
+	// coinbase transactions pay no fee
+	if resolved && len(tx.Vin) > 0 && !inputs[0].Coinbase {
+		res["fee_sat"] = totalIn - totalOut
+	}
+	return res, nil
+}
+
+func (a *utxoAdapter) getBlockchainInfo(ctx context.Context) (*utxoBlockchainInfo, error) {
+	var res utxoBlockchainInfo
+	if err := a.call(ctx, &res, "getBlockchainInfo"); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// getBlock drops the tx ids of the block, the same as blocks of other chains are returned without transactions.
+// With includeTxs the block is read at verbosity 2, which returns decoded transactions instead of ids,
+// otherwise verbose is passed as bool because dogecoin nodes do not accept a verbosity level.
+func (a *utxoAdapter) getBlock(ctx context.Context, hash string, includeTxs bool) (*entity.Block, error) {
+	var (
+		data      json.RawMessage
+		verbosity interface{} = true
+	)
+	if includeTxs {
+		verbosity = 2
+	}
+	err := a.call(ctx, &data, "getBlockByNumber", hash, verbosity)
+	if isUtxoErrorCode(err, utxoErrInvalidAddressOrKey) {
+		return nil, ethereum.NotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return newUtxoBlock(data, includeTxs)
+}
+
+// GetBlocksByNumber resolves the hashes in one json-rpc batch then fetches the blocks in a second batch
+// on the same upstream, heights beyond the best block are nil.
+func (a *utxoAdapter) GetBlocksByNumber(ctx context.Context, numbers []uint64) ([]*entity.Block, error) {
+	hashMethod, ok := a.chainInfo.Methods["getBlockHash"]
+	if !ok {
+		return nil, setting.ErrNotSupportedMethod
+	}
+	blockMethod, ok := a.chainInfo.Methods["getBlockByNumber"]
+	if !ok {
+		return nil, setting.ErrNotSupportedMethod
+	}
+
+	res := make([]*entity.Block, len(numbers))
+	err := a.chainInfo.upstreams.Do(ctx, func(endpoint string) error {
+		client, release, err := a.clientPool.GetRpcClient(ctx, endpoint)
+		if err != nil {
+			return setting.ErrClientConnectionFailure
+		}
+		defer release()
+
+		hashBatch := make([]rpc.BatchElem, 0, len(numbers))
+		for _, number := range numbers {
+			hashBatch = append(hashBatch, rpc.BatchElem{
+				Method: hashMethod,
+				Args:   []interface{}{number},
+				Result: new(string),
+			})
+		}
+		if err := client.BatchCallContext(ctx, hashBatch); err != nil {
+			return err
+		}
+
+		var (
+			blockBatch = make([]rpc.BatchElem, 0, len(numbers))
+			blockIdx   = make([]int, 0, len(numbers)) // index of numbers of each block in batch
+		)
+		for i, elem := range hashBatch {
+			if isUtxoErrorCode(elem.Error, utxoErrInvalidParameter) {
+				continue
+			} else if elem.Error != nil {
+				return fmt.Errorf("failed to get hash of block %d: %w", numbers[i], elem.Error)
+			}
+			blockIdx = append(blockIdx, i)
+			blockBatch = append(blockBatch, rpc.BatchElem{
+				Method: blockMethod,
+				Args:   []interface{}{*elem.Result.(*string), true},
+				Result: &json.RawMessage{},
+			})
+		}
+		if len(blockBatch) == 0 {
+			return nil
+		}
+		if err := client.BatchCallContext(ctx, blockBatch); err != nil {
+			return err
+		}
+
+		for i, elem := range blockBatch {
+			if elem.Error != nil {
+				return fmt.Errorf("failed to get block %d: %w", numbers[blockIdx[i]], elem.Error)
+			}
+			block, err := newUtxoBlock(*elem.Result.(*json.RawMessage), false)
+			if err != nil && !errors.Is(err, ethereum.NotFound) {
+				return err
+			}
+			res[blockIdx[i]] = block
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func newUtxoBlock(data json.RawMessage, includeTxs bool) (*entity.Block, error) {
+	var block utxoBlock
+	raw, err := decodeBlock(data, &block)
+	if err != nil {
+		return nil, err
+	}
+	if raw == nil {
+		return nil, ethereum.NotFound
+	}
+	res := &entity.Block{
+		Number:     block.Height,
+		Hash:       block.Hash,
+		ParentHash: block.PreviousBlockHash,
+		Timestamp:  block.Time,
+		Raw:        raw,
+	}
+	if includeTxs {
+		res.Transactions = raw["tx"]
+	} else {
+		delete(raw, "tx")
+	}
+	if block.NTx == 0 {
+		block.NTx = uint64(len(block.Tx))
+	}
+	return res.WithTxCount(block.NTx), nil
+}
+
+func (a *utxoAdapter) call(ctx context.Context, result interface{}, method string, args ...interface{}) error {
+	methodName, ok := a.chainInfo.Methods[method]
+	if !ok {
+		return setting.ErrNotSupportedMethod
+	}
+
+	return a.chainInfo.upstreams.Do(ctx, func(endpoint string) error {
+		client, release, err := a.clientPool.GetRpcClient(ctx, endpoint)
+		if err != nil {
+			return setting.ErrClientConnectionFailure
+		}
+		defer release()
+
+		return client.CallContext(ctx, result, methodName, args...)
+	})
+}
+
+func (v utxoVout) address() string {
+	if v.ScriptPubKey.Address != "" {
+		return v.ScriptPubKey.Address
+	}
+	if len(v.ScriptPubKey.Addresses) > 0 {
+		return v.ScriptPubKey.Addresses[0]
+	}
+	return ""
+}
+
+// toSatoshi converts a coin amount with 8 decimals into its smallest unit without float rounding
+func toSatoshi(value json.Number) (int64, error) {
+	amount, ok := new(big.Rat).SetString(value.String())
+	if !ok {
+		return 0, fmt.Errorf("invalid amount %s", value)
+	}
+	amount.Mul(amount, new(big.Rat).SetInt64(satoshiPerCoin))
+	if !amount.IsInt() {
+		return 0, fmt.Errorf("amount %s has more than 8 decimals", value)
+	}
+	return amount.Num().Int64(), nil
+}
+
+func isUtxoErrorCode(err error, code int) bool {
+	var rpcErr rpc.Error
+	return errors.As(err, &rpcErr) && rpcErr.ErrorCode() == code
+}
@@ -0,0 +1,71 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestUtxoAdapter_GetTransactionByHash(t *testing.T) {
+	convey.Convey("TestUtxoAdapter_GetTransactionByHash", t, func() {
+		ctx := context.Background()
+		upstream := newFakeUpstream(t)
+		upstream.SetMaxBatch(utxoMaxPrevTxsPerBatch)
+
+		// a consolidation spending output 0 of 250 transactions worth 0.1 coin each
+		consolidation := map[string]interface{}{"txid": "consolidation", "vout": []interface{}{
+			map[string]interface{}{"value": json.Number("24.9"), "n": 0, "scriptPubKey": map[string]interface{}{"type": "witness_v0_keyhash", "address": "bc1qto"}},
+		}}
+		var vin []interface{}
+		for i := 0; i < 250; i++ {
+			vin = append(vin, map[string]interface{}{"txid": fmt.Sprintf("prev%d", i), "vout": 0})
+		}
+		consolidation["vin"] = vin
+		upstream.Handle("getrawtransaction", func(params []json.RawMessage) (interface{}, error) {
+			var txid string
+			_ = json.Unmarshal(params[0], &txid)
+			switch txid {
+			case "consolidation":
+				return consolidation, nil
+			case "prev7":
+				return nil, fmt.Errorf("No such mempool or blockchain transaction")
+			}
+			return map[string]interface{}{"txid": txid, "vin": []interface{}{}, "vout": []interface{}{
+				map[string]interface{}{"value": json.Number("0.1"), "n": 0, "scriptPubKey": map[string]interface{}{"type": "witness_v0_keyhash", "address": "bc1q" + txid}},
+			}}, nil
+		})
+
+		adapter := newUtxoAdapter(newTestChainInfo(t, Bitcoin, upstream.URL), newTestClientPool(t))
+		res, err := adapter.GetTransactionByHash(ctx, "consolidation")
+		convey.So(err, convey.ShouldBeNil)
+
+		tx := res.(map[string]interface{})
+		inputs := tx["inputs"].([]*UtxoInput)
+		convey.So(upstream.Batches(), convey.ShouldResemble, []int{100, 100, 50})
+		convey.So(inputs, convey.ShouldHaveLength, 250)
+		convey.So(inputs[0].Address, convey.ShouldEqual, "bc1qprev0")
+		convey.So(inputs[0].ValueSat, convey.ShouldEqual, 10_000_000)
+		convey.So(inputs[249].Address, convey.ShouldEqual, "bc1qprev249")
+
+		convey.Convey("Inputs the node does not answer are unresolved", func() {
+			convey.So(inputs[7].Unresolved, convey.ShouldBeTrue)
+			convey.So(inputs[7].Address, convey.ShouldBeEmpty)
+			convey.So(inputs[8].Unresolved, convey.ShouldBeFalse)
+			convey.So(tx, convey.ShouldNotContainKey, "fee_sat")
+		})
+
+		convey.Convey("Batches refused by the node leave their inputs unresolved", func() {
+			upstream.SetMaxBatch(50)
+			res, err := adapter.GetTransactionByHash(ctx, "consolidation")
+			convey.So(err, convey.ShouldBeNil)
+
+			inputs := res.(map[string]interface{})["inputs"].([]*UtxoInput)
+			convey.So(inputs, convey.ShouldHaveLength, 250)
+			convey.So(inputs[0].Unresolved, convey.ShouldBeTrue)
+			convey.So(inputs[249].Unresolved, convey.ShouldBeFalse)
+		})
+	})
+}
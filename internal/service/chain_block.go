@@ -0,0 +1,289 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/tikivn/ultrago/u_logger"
+	"golang.org/x/sync/errgroup"
+
+	"nimbus-enhance-api/internal/conf"
+	"nimbus-enhance-api/internal/entity"
+	"nimbus-enhance-api/internal/setting"
+)
+
+// block tags, resolved by each adapter to the head of the matching finality
+const (
+	BlockTagLatest    = "latest"
+	BlockTagSafe      = "safe"
+	BlockTagFinalized = "finalized"
+	BlockTagEarliest  = "earliest"
+)
+
+// limits of blocks returned by one page of a block range
+const (
+	defaultBlockRangeLimit = 20
+	maxBlockRangeLimit     = 100
+)
+
+// BlockID addresses a block by exactly one of number, hash or tag
+type BlockID struct {
+	Number *uint64
+	Hash   string
+	Tag    string
+}
+
+// ParseBlockID accepts a block tag, a decimal or 0x prefixed hex number, anything else is taken as block hash
+func ParseBlockID(id string) (BlockID, error) {
+	id = strings.TrimSpace(id)
+	switch {
+	case id == "":
+		return BlockID{}, fmt.Errorf("missing block id")
+	case id == BlockTagLatest, id == BlockTagSafe, id == BlockTagFinalized, id == BlockTagEarliest:
+		return BlockID{Tag: id}, nil
+	case strings.HasPrefix(id, "0x") && len(id) <= 18:
+		number, err := hexutil.DecodeUint64(id)
+		if err != nil {
+			return BlockID{}, fmt.Errorf("invalid block number %s: %w", id, err)
+		}
+		return BlockID{Number: &number}, nil
+	}
+	// 64 hex hashes of tron and utxo chains may contain only digits, block numbers never have 20 digits
+	if len(id) < 20 {
+		if number, err := strconv.ParseUint(id, 10, 64); err == nil {
+			return BlockID{Number: &number}, nil
+		}
+	}
+	return BlockID{Hash: id}, nil
+}
+
+func (id BlockID) String() string {
+	switch {
+	case id.Number != nil:
+		return strconv.FormatUint(*id.Number, 10)
+	case id.Hash != "":
+		return id.Hash
+	default:
+		return id.Tag
+	}
+}
+
+// GetBlock caches blocks addressed by hash, which never change, and blocks addressed by number once they are
+// deeper than conf BLOCK_IMMUTABLE_DEPTH. Blocks addressed by tag, and recent blocks which may still be reorganized,
+// are always fetched.
+func (svc *chainService) GetBlock(ctx context.Context, chain Chain, id BlockID, includeTxs bool) (*entity.Block, error) {
+	ctx, logger := u_logger.GetLogger(ctx)
+	adapter, err := svc.getAdapter(chain)
+	if err != nil {
+		return nil, err
+	}
+	cacheKey := fmt.Sprintf("block:%s:%s:%t", string(chain), id, includeTxs)
+
+	// get data from cache
+	if id.Number != nil || id.Hash != "" {
+		data, err := svc.blockRepo.Get(ctx, cacheKey)
+		if err == nil {
+			var res entity.Block
+			err = json.Unmarshal([]byte(data), &res)
+			return &res, err
+		}
+	}
+
+	// if no hit cache then call api
+	res, err := adapter.GetBlock(ctx, id, includeTxs)
+	if err != nil {
+		return nil, err
+	}
+	res.Chain = string(chain)
+	switch {
+	case id.Hash != "":
+		_ = svc.blockRepo.Set(ctx, cacheKey, res)
+		return res, nil
+	case id.Number == nil:
+		return res, nil
+	}
+
+	latestBlockNumber, err := adapter.GetLatestBlockNumber(ctx)
+	if err != nil {
+		logger.Warnf("failed to get latest block number of chain %s: %v", chain, err)
+		return res, nil
+	}
+	if *id.Number+conf.Config.BlockImmutableDepth <= latestBlockNumber {
+		_ = svc.blockRepo.Set(ctx, cacheKey, res)
+	}
+	return res, nil
+}
+
+// blockResultFetcher is implemented by adapters decoding blocks into go-ethereum types
+type blockResultFetcher interface {
+	GetBlockResult(ctx context.Context, number uint64) (*entity.BlockResult, error)
+}
+
+// GetBlockRawLogs returns the block with every transaction and receipt flattened for archiving, it is not cached
+// because dumps of full blocks are large and read once. Hashes are the ones answered by the node, chains extending
+// the header would not hash to them again. Receipt logs of known events are decoded, failing to decode is only logged.
+func (svc *chainService) GetBlockRawLogs(ctx context.Context, chain Chain, number uint64) (*entity.BlockRawLogs, error) {
+	ctx, logger := u_logger.GetLogger(ctx)
+	adapter, err := svc.getAdapter(chain)
+	if err != nil {
+		return nil, err
+	}
+	fetcher, ok := adapter.(blockResultFetcher)
+	if !ok {
+		return nil, setting.ErrNotSupportedMethod
+	}
+
+	block, err := fetcher.GetBlockResult(ctx, number)
+	if err != nil {
+		return nil, err
+	}
+	res := new(entity.BlockRawLogs).FromEntity(block.Block).WithTransactions(block.Transactions)
+	if block.Hash != "" {
+		res.Hash = block.Hash
+	}
+
+	var logs []*entity.LogData
+	for i := range res.Transactions {
+		receipt := &res.Transactions[i].Receipt
+		for j := range receipt.Logs {
+			logs = append(logs, &receipt.Logs[j])
+		}
+	}
+	if err := svc.abiDecoder.DecodeLogs(ctx, chain, logs); err != nil {
+		logger.Warnf("failed to decode logs of block %d of chain %s: %v", number, chain, err)
+	}
+	return res, nil
+}
+
+// blockBatcher is implemented by adapters fetching several blocks in one json-rpc batch,
+// the result has the order of numbers with nil for missing blocks.
+type blockBatcher interface {
+	GetBlocksByNumber(ctx context.Context, numbers []uint64) ([]*entity.Block, error)
+}
+
+// BlockPage is one page of a block range, NextCursor is empty on the last page
+type BlockPage struct {
+	Blocks     []*entity.Block `json:"blocks"`
+	NextCursor string          `json:"next_cursor,omitempty"`
+}
+
+// GetBlockRange returns up to limit blocks from from to to (the latest block when 0) in ascending order.
+// The cursor of the previous page takes over from. Blocks are fetched in batches of conf NUM_BATCH blocks,
+// at most conf NUM_WORKER batches at once. Missing blocks, such as skipped solana slots, are left out.
+func (svc *chainService) GetBlockRange(ctx context.Context, chain Chain, from, to uint64, limit int, cursor string) (*BlockPage, error) {
+	adapter, err := svc.getAdapter(chain)
+	if err != nil {
+		return nil, err
+	}
+	if cursor != "" {
+		if from, err = strconv.ParseUint(cursor, 10, 64); err != nil {
+			return nil, fmt.Errorf("%w: invalid cursor %s", setting.ErrInvalidBlockRange, cursor)
+		}
+	}
+	if limit <= 0 {
+		limit = defaultBlockRangeLimit
+	} else if limit > maxBlockRangeLimit {
+		limit = maxBlockRangeLimit
+	}
+	if to == 0 {
+		if to, err = adapter.GetLatestBlockNumber(ctx); err != nil {
+			return nil, err
+		}
+	}
+	if from > to {
+		return nil, fmt.Errorf("%w: from %d is after to %d", setting.ErrInvalidBlockRange, from, to)
+	}
+
+	last := to
+	if to-from >= uint64(limit) {
+		last = from + uint64(limit) - 1
+	}
+	numbers := make([]uint64, 0, last-from+1)
+	for number := from; number <= last; number++ {
+		numbers = append(numbers, number)
+	}
+
+	var (
+		eg, childCtx = errgroup.WithContext(ctx)
+		blocks       = make([]*entity.Block, len(numbers))
+		batchSize    = conf.Config.NumBatch
+		numWorker    = conf.Config.NumWorker
+	)
+	if batchSize <= 0 {
+		batchSize = 1
+	}
+	if numWorker <= 0 {
+		numWorker = 1
+	}
+	eg.SetLimit(numWorker)
+	for start := 0; start < len(numbers); start += batchSize {
+		start, end := start, start+batchSize
+		if end > len(numbers) {
+			end = len(numbers)
+		}
+		eg.Go(func() error {
+			res, err := fetchBlocksByNumber(childCtx, adapter, numbers[start:end])
+			if err != nil {
+				return err
+			}
+			copy(blocks[start:end], res)
+			return nil
+		})
+	}
+	if err := eg.Wait(); err != nil {
+		return nil, err
+	}
+
+	res := &BlockPage{Blocks: make([]*entity.Block, 0, len(blocks))}
+	for _, block := range blocks {
+		if block != nil {
+			block.Chain = string(chain)
+			res.Blocks = append(res.Blocks, block)
+		}
+	}
+	if last < to {
+		res.NextCursor = strconv.FormatUint(last+1, 10)
+	}
+	return res, nil
+}
+
+// fetchBlocksByNumber falls back to one call per block for adapters without json-rpc batch
+func fetchBlocksByNumber(ctx context.Context, adapter ChainAdapter, numbers []uint64) ([]*entity.Block, error) {
+	if batcher, ok := adapter.(blockBatcher); ok {
+		return batcher.GetBlocksByNumber(ctx, numbers)
+	}
+
+	res := make([]*entity.Block, len(numbers))
+	for i, number := range numbers {
+		block, err := adapter.GetBlockByNumber(ctx, number)
+		if errors.Is(err, ethereum.NotFound) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		res[i] = block
+	}
+	return res, nil
+}
+
+// decodeBlock unmarshals the upstream block into the raw map kept as entity.Block Raw and into the typed view of the adapter,
+// a null block returns nil map. go-ethereum rpc client leaves the result empty when the node answers null.
+func decodeBlock(data json.RawMessage, block interface{}) (map[string]interface{}, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+	var raw map[string]interface{}
+	if err := json.Unmarshal(data, &raw); err != nil || raw == nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, block); err != nil {
+		return nil, err
+	}
+	return raw, nil
+}
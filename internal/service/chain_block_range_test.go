@@ -0,0 +1,88 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+
+	"nimbus-enhance-api/internal/entity"
+	"nimbus-enhance-api/internal/setting"
+)
+
+func blockNumbers(blocks []*entity.Block) []uint64 {
+	res := make([]uint64, 0, len(blocks))
+	for _, block := range blocks {
+		res = append(res, block.Number)
+	}
+	return res
+}
+
+func numberRange(from, to uint64) []uint64 {
+	res := make([]uint64, 0, to-from+1)
+	for number := from; number <= to; number++ {
+		res = append(res, number)
+	}
+	return res
+}
+
+func TestChainService_GetBlockRange(t *testing.T) {
+	convey.Convey("TestChainService_GetBlockRange", t, func() {
+		ctx := context.Background()
+		chain := Chain("fake-block-range")
+		upstream := newFakeUpstream(t)
+		upstream.HandleEvmBlocks(1000)
+		svc := registerFakeChain(t, chain, newEvmAdapter(newTestChainInfo(t, Ethereum, upstream.URL), newTestClientPool(t)))
+
+		convey.Convey("Default limit pages from the given block in batches of NUM_BATCH", func() {
+			res, err := svc.GetBlockRange(ctx, chain, 0, 0, 0, "")
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(blockNumbers(res.Blocks), convey.ShouldResemble, numberRange(0, 19))
+			convey.So(res.Blocks[0].Chain, convey.ShouldEqual, string(chain))
+			convey.So(res.NextCursor, convey.ShouldEqual, "20")
+			convey.So(upstream.Batches(), convey.ShouldResemble, []int{5, 5, 5, 5})
+		})
+
+		convey.Convey("Limit is capped and the cursor takes over from", func() {
+			res, err := svc.GetBlockRange(ctx, chain, 0, 500, 1000, "")
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(blockNumbers(res.Blocks), convey.ShouldResemble, numberRange(0, 99))
+			convey.So(res.NextCursor, convey.ShouldEqual, "100")
+			convey.So(upstream.Batches(), convey.ShouldHaveLength, 20)
+
+			res, err = svc.GetBlockRange(ctx, chain, 0, 106, 20, res.NextCursor)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(blockNumbers(res.Blocks), convey.ShouldResemble, numberRange(100, 106))
+			convey.So(res.NextCursor, convey.ShouldBeEmpty)
+			// batches run in parallel, in any order
+			batches := upstream.Batches()[20:]
+			sort.Ints(batches)
+			convey.So(batches, convey.ShouldResemble, []int{2, 5})
+		})
+
+		convey.Convey("Blocks after the head are left out", func() {
+			res, err := svc.GetBlockRange(ctx, chain, 995, 1005, 0, "")
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(blockNumbers(res.Blocks), convey.ShouldResemble, numberRange(995, 1000))
+		})
+
+		convey.Convey("Invalid ranges are refused", func() {
+			_, err := svc.GetBlockRange(ctx, chain, 10, 5, 0, "")
+			convey.So(errors.Is(err, setting.ErrInvalidBlockRange), convey.ShouldBeTrue)
+			_, err = svc.GetBlockRange(ctx, chain, 0, 5, 0, "next")
+			convey.So(errors.Is(err, setting.ErrInvalidBlockRange), convey.ShouldBeTrue)
+			convey.So(upstream.Batches(), convey.ShouldBeEmpty)
+		})
+
+		convey.Convey("Adapters without batch fetch one block per call", func() {
+			adapter := newFakeAdapter(1000)
+			svc := registerFakeChain(t, Chain("fake-block-range-single"), adapter)
+			res, err := svc.GetBlockRange(ctx, Chain("fake-block-range-single"), 10, 0, 7, "")
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(blockNumbers(res.Blocks), convey.ShouldResemble, numberRange(10, 16))
+			convey.So(adapter.BlockCalls(), convey.ShouldEqual, 7)
+		})
+	})
+}
@@ -0,0 +1,175 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"strconv"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/smartystreets/goconvey/convey"
+
+	"nimbus-enhance-api/internal/entity"
+	"nimbus-enhance-api/internal/setting"
+)
+
+func TestChainService_GetBlock(t *testing.T) {
+	convey.Convey("TestChainService_GetBlock", t, func() {
+		ctx := context.Background()
+		chain := Chain("fake-get-block")
+		adapter := newFakeAdapter(1000)
+		svc := registerFakeChain(t, chain, adapter)
+
+		convey.Convey("Blocks addressed by hash are cached at any depth", func() {
+			for i := 0; i < 2; i++ {
+				res, err := svc.GetBlock(ctx, chain, BlockID{Hash: "0x3e8"}, false)
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(res.Number, convey.ShouldEqual, 1000)
+				convey.So(res.Chain, convey.ShouldEqual, string(chain))
+			}
+			convey.So(adapter.BlockCalls(), convey.ShouldEqual, 1)
+		})
+
+		convey.Convey("Blocks addressed by number are cached below the immutable depth only", func() {
+			deep, recent := uint64(10), uint64(990)
+			for i := 0; i < 2; i++ {
+				_, err := svc.GetBlock(ctx, chain, BlockID{Number: &deep}, false)
+				convey.So(err, convey.ShouldBeNil)
+				_, err = svc.GetBlock(ctx, chain, BlockID{Number: &recent}, false)
+				convey.So(err, convey.ShouldBeNil)
+			}
+			convey.So(adapter.BlockCalls(), convey.ShouldEqual, 3)
+		})
+	})
+}
+
+// fakeEvmFullBlock is block number with count signed transfers, the receipt of transaction i has transaction index i
+// and cumulative gas used 21000 * (i + 1). Raw blocks and receipts are answered as nodes do.
+func fakeEvmFullBlock(t *testing.T, number uint64, count int) (block map[string]interface{}, receipts map[string]json.RawMessage, hashes []string) {
+	key, err := crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		from   = crypto.PubkeyToAddress(key.PublicKey)
+		to     = common.HexToAddress("0x000000000000000000000000000000000000dead")
+		signer = types.NewEIP155Signer(big.NewInt(1))
+		txs    = make([]interface{}, 0, count)
+	)
+	receipts = make(map[string]json.RawMessage, count)
+	for i := 0; i < count; i++ {
+		tx := types.MustSignNewTx(key, signer, &types.LegacyTx{
+			Nonce:    uint64(i),
+			GasPrice: big.NewInt(1_000_000_000),
+			Gas:      21000,
+			To:       &to,
+			Value:    big.NewInt(int64(i)),
+		})
+		var raw map[string]interface{}
+		data, _ := tx.MarshalJSON()
+		_ = json.Unmarshal(data, &raw)
+		raw["from"] = from.Hex()
+		txs = append(txs, raw)
+		hashes = append(hashes, tx.Hash().Hex())
+
+		receipt, _ := json.Marshal(&types.Receipt{
+			Status:            types.ReceiptStatusSuccessful,
+			CumulativeGasUsed: uint64(21000 * (i + 1)),
+			Logs:              []*types.Log{},
+			TxHash:            tx.Hash(),
+			GasUsed:           21000,
+			BlockNumber:       new(big.Int).SetUint64(number),
+			TransactionIndex:  uint(i),
+		})
+		receipts[tx.Hash().Hex()] = receipt
+	}
+
+	header, _ := json.Marshal(&types.Header{
+		UncleHash:  types.EmptyUncleHash,
+		Difficulty: big.NewInt(0),
+		Number:     new(big.Int).SetUint64(number),
+		GasLimit:   30_000_000,
+		GasUsed:    uint64(21000 * count),
+		Time:       1_600_000_000 + 12*number,
+		Extra:      []byte{},
+		BaseFee:    big.NewInt(1_000_000_000),
+	})
+	_ = json.Unmarshal(header, &block)
+	block["transactions"] = txs
+	return block, receipts, hashes
+}
+
+func TestChainService_GetBlockRawLogs(t *testing.T) {
+	convey.Convey("TestChainService_GetBlockRawLogs", t, func() {
+		ctx := context.Background()
+		chain := Chain("fake-block-raw")
+		block, receipts, hashes := fakeEvmFullBlock(t, 1000, 205)
+		upstream := newFakeUpstream(t)
+		upstream.Result("eth_getBlockByNumber", block)
+		upstream.Handle("eth_getTransactionReceipt", func(params []json.RawMessage) (interface{}, error) {
+			var hash string
+			_ = json.Unmarshal(params[0], &hash)
+			return receipts[hash], nil
+		})
+		svc := registerFakeChain(t, chain, newEvmAdapter(newTestChainInfo(t, Ethereum, upstream.URL), newTestClientPool(t)))
+
+		assertReceipts := func(res *entity.BlockRawLogs) {
+			convey.So(res.BlockNumber, convey.ShouldEqual, "1000")
+			convey.So(res.Transactions, convey.ShouldHaveLength, len(hashes))
+			for i, tx := range res.Transactions {
+				convey.So(tx.Index, convey.ShouldEqual, i)
+				convey.So(tx.Hash, convey.ShouldEqual, hashes[i])
+				convey.So(tx.Receipt.TxHash, convey.ShouldEqual, hashes[i])
+				convey.So(tx.Receipt.TransactionIndex, convey.ShouldEqual, i)
+				convey.So(tx.Receipt.CumulativeGasUsed, convey.ShouldEqual, strconv.Itoa(21000*(i+1)))
+			}
+		}
+
+		convey.Convey("Block receipts are attached by transaction hash", func() {
+			// the node answers the receipts in reverse order
+			res := make([]json.RawMessage, 0, len(hashes))
+			for i := len(hashes) - 1; i >= 0; i-- {
+				res = append(res, receipts[hashes[i]])
+			}
+			upstream.Result("eth_getBlockReceipts", res)
+
+			block, err := svc.GetBlockRawLogs(ctx, chain, 1000)
+			convey.So(err, convey.ShouldBeNil)
+			assertReceipts(block)
+			convey.So(upstream.Calls("eth_getTransactionReceipt"), convey.ShouldEqual, 0)
+		})
+
+		convey.Convey("Nodes without eth_getBlockReceipts are asked receipts in batches of 100", func() {
+			res, err := svc.GetBlockRawLogs(ctx, chain, 1000)
+			convey.So(err, convey.ShouldBeNil)
+			assertReceipts(res)
+			convey.So(upstream.Calls("eth_getBlockReceipts"), convey.ShouldEqual, 1)
+			convey.So(upstream.Batches(), convey.ShouldResemble, []int{100, 100, 5})
+		})
+
+		convey.Convey("Header go-ethereum can not decode is not supported", func() {
+			// klaytn headers have no uncles, miner nor difficulty
+			delete(block, "sha3Uncles")
+			delete(block, "miner")
+			delete(block, "difficulty")
+			upstream.Result("eth_getBlockByNumber", block)
+
+			_, err := svc.GetBlockRawLogs(ctx, chain, 1000)
+			convey.So(errors.Is(err, setting.ErrNotSupportedMethod), convey.ShouldBeTrue)
+		})
+
+		convey.Convey("Transaction type go-ethereum can not decode is not supported", func() {
+			// deposit transactions of op stack chains
+			block["transactions"].([]interface{})[0].(map[string]interface{})["type"] = "0x7e"
+			upstream.Result("eth_getBlockByNumber", block)
+
+			_, err := svc.GetBlockRawLogs(ctx, chain, 1000)
+			convey.So(errors.Is(err, setting.ErrNotSupportedMethod), convey.ShouldBeTrue)
+			convey.So(upstream.Calls("eth_getTransactionReceipt"), convey.ShouldEqual, 0)
+		})
+	})
+}
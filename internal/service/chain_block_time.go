@@ -0,0 +1,149 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/bits"
+	"strconv"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/tikivn/ultrago/u_logger"
+
+	"nimbus-enhance-api/internal/conf"
+	"nimbus-enhance-api/internal/entity"
+)
+
+// maxBlockTimeProbeGap bounds the blocks scanned forward from a probed number without block,
+// a longer gap is taken as the pruned history of the node.
+const maxBlockTimeProbeGap = 64
+
+// blockTimeProbe is the first existing block at or after a probed number
+type blockTimeProbe struct {
+	Number    uint64 `json:"number"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+// GetBlockAtTimestamp returns the first block at or after timestamp (unix seconds), ethereum.NotFound when the latest block is older.
+// The lower bound is found by doubling the distance from the head, so recent times take few probes
+// and chains without genesis at number 0 or with pruned history need no earliest block.
+// Probes are cached in Redis once deeper than conf BLOCK_IMMUTABLE_DEPTH. Probed numbers are multiples of powers of two,
+// not offsets from the moving head, so later lookups probe the same numbers and are answered from the cache.
+func (svc *chainService) GetBlockAtTimestamp(ctx context.Context, chain Chain, timestamp int64) (*entity.Block, error) {
+	adapter, err := svc.getAdapter(chain)
+	if err != nil {
+		return nil, err
+	}
+
+	latest, err := adapter.GetLatestBlock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if latest.Timestamp < timestamp {
+		return nil, fmt.Errorf("%w: latest block %d of chain %s is before %d", ethereum.NotFound, latest.Number, chain, timestamp)
+	}
+
+	var (
+		head      = latest.Number
+		candidate = &blockTimeProbe{Number: latest.Number, Timestamp: latest.Timestamp}
+		lo, hi    = uint64(0), latest.Number // the answer is candidate or a block in (lo, hi)
+		hasLo     bool
+	)
+	for step := uint64(1); hi > 0; step *= 2 {
+		// head rounded down to a multiple of step, one step back
+		number := uint64(0)
+		if aligned := head - head%step; aligned > step {
+			number = aligned - step
+		}
+		if number >= hi {
+			continue
+		}
+		probe, err := svc.probeBlockTime(ctx, chain, adapter, number, head)
+		if err != nil {
+			return nil, err
+		}
+		if probe == nil {
+			lo, hasLo = number, true
+			break
+		}
+		if probe.Timestamp < timestamp {
+			lo, hasLo = probe.Number, true
+			break
+		}
+		candidate, hi = probe, number
+	}
+
+	for hasLo && lo+1 < hi {
+		mid := alignedBetween(lo, hi)
+		probe, err := svc.probeBlockTime(ctx, chain, adapter, mid, head)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case probe == nil:
+			lo = mid
+		case probe.Timestamp >= timestamp:
+			candidate, hi = probe, mid
+		default:
+			lo = probe.Number
+		}
+	}
+
+	return svc.GetBlock(ctx, chain, BlockID{Number: &candidate.Number}, false)
+}
+
+// alignedBetween returns the number in (lo, hi) with the most trailing zero bits, it splits the range close to
+// the middle while the same numbers come back for ranges around them. lo+1 < hi is required.
+func alignedBetween(lo, hi uint64) uint64 {
+	first, last := lo+1, hi-1
+	if first == last {
+		return first
+	}
+	// first and last share the bits above the highest differing bit, which is 0 in first and 1 in last
+	bit := uint64(1) << (bits.Len64(first^last) - 1)
+	return last &^ (bit - 1)
+}
+
+// probeBlockTime scans forward from number for the first existing block up to the head,
+// nil when there is none within maxBlockTimeProbeGap. The result depends on number only, so it is cached by number.
+func (svc *chainService) probeBlockTime(ctx context.Context, chain Chain, adapter ChainAdapter, number, head uint64) (*blockTimeProbe, error) {
+	ctx, logger := u_logger.GetLogger(ctx)
+	var (
+		cacheKey = fmt.Sprintf("block_time:%s", string(chain))
+		field    = strconv.FormatUint(number, 10)
+	)
+
+	// get data from cache
+	data, err := svc.blockRepo.HGet(ctx, cacheKey, field)
+	if err == nil {
+		var res *blockTimeProbe
+		err = json.Unmarshal([]byte(data), &res)
+		return res, err
+	}
+
+	// if no hit cache then call api
+	var (
+		res     *blockTimeProbe
+		current = number
+	)
+	for ; current <= head && current < number+maxBlockTimeProbeGap; current++ {
+		block, err := adapter.GetBlockByNumber(ctx, current)
+		if errors.Is(err, ethereum.NotFound) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		res = &blockTimeProbe{Number: block.Number, Timestamp: block.Timestamp}
+		break
+	}
+
+	// a missing block is cached as null, the same as a found one once the scanned blocks can not change
+	if current+conf.Config.BlockImmutableDepth <= head {
+		value, _ := json.Marshal(res)
+		if err := svc.blockRepo.HSet(ctx, cacheKey, map[string]interface{}{field: string(value)}); err != nil {
+			logger.Warnf("failed to cache block time probe %d of chain %s: %v", number, chain, err)
+		}
+	}
+	return res, nil
+}
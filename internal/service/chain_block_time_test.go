@@ -0,0 +1,47 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestChainService_GetBlockAtTimestamp(t *testing.T) {
+	convey.Convey("TestChainService_GetBlockAtTimestamp", t, func() {
+		ctx := context.Background()
+		chain := Chain("fake-block-time")
+		adapter := newFakeAdapter(100_000)
+		svc := registerFakeChain(t, chain, adapter)
+
+		lookup := func(number uint64, offset int64) {
+			res, err := svc.GetBlockAtTimestamp(ctx, chain, adapter.block(number).Timestamp+offset)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(res.Number, convey.ShouldEqual, number)
+		}
+
+		convey.Convey("First block at or after the timestamp is found", func() {
+			lookup(0, -100)
+			lookup(54_321, 0)
+			lookup(54_322, -5)
+			lookup(99_999, 0)
+			lookup(100_000, 0)
+		})
+
+		convey.Convey("Repeated lookups are answered from cached probes while the head moves", func() {
+			lookup(54_321, 0)
+			first := adapter.BlockCalls()
+
+			adapter.SetHead(100_050)
+			calls := adapter.BlockCalls()
+			lookup(54_321, 0)
+			// the latest block and the probes within the immutable depth of the head only
+			convey.So(adapter.BlockCalls()-calls, convey.ShouldBeLessThan, first/2)
+
+			adapter.SetHead(100_300)
+			calls = adapter.BlockCalls()
+			lookup(54_321, 0)
+			convey.So(adapter.BlockCalls()-calls, convey.ShouldBeLessThan, first/2)
+		})
+	})
+}
@@ -0,0 +1,120 @@
+package service
+
+import (
+	"fmt"
+	"sort"
+
+	"nimbus-enhance-api/internal/entity"
+	"nimbus-enhance-api/internal/setting"
+)
+
+// chain family, adapters of a family share the rpc dialect
+const (
+	FamilyEVM    = "evm"
+	FamilySolana = "solana"
+	FamilyNear   = "near"
+	FamilyAptos  = "aptos"
+	FamilySui    = "sui"
+	FamilyTron   = "tron"
+	FamilyUTXO   = "utxo"
+)
+
+// operations of the api a chain may support
+const (
+	OperationLatestBlock = "latest_block"
+	OperationBlock       = "block"
+	OperationTxSearch    = "tx_search"
+	OperationTxCount     = "tx_count"
+	OperationGas         = "gas"
+	OperationBlockRaw    = "block_raw"
+)
+
+// familyOperations are served by every adapter of a family, extra operations are listed per chain.
+var familyOperations = map[string][]string{
+	FamilyEVM:    {OperationLatestBlock, OperationBlock, OperationTxSearch, OperationGas, OperationBlockRaw},
+	FamilySolana: {OperationLatestBlock, OperationBlock, OperationTxSearch, OperationGas},
+	FamilyNear:   {OperationLatestBlock, OperationBlock, OperationTxSearch},
+	FamilyAptos:  {OperationLatestBlock, OperationBlock, OperationTxSearch},
+	FamilySui:    {OperationLatestBlock, OperationBlock, OperationTxSearch},
+	FamilyTron:   {OperationLatestBlock, OperationBlock, OperationTxSearch},
+	FamilyUTXO:   {OperationLatestBlock, OperationBlock, OperationTxSearch},
+}
+
+type ChainCapability struct {
+	Name           Chain                  `json:"name"`
+	DisplayName    string                 `json:"display_name"`
+	Family         string                 `json:"family"`
+	ChainID        uint64                 `json:"chain_id,omitempty"`
+	NativeCurrency *entity.NativeCurrency `json:"native_currency,omitempty"`
+	Operations     []string               `json:"operations"`
+}
+
+func (c *ChainCapability) Supports(operation string) bool {
+	for _, item := range c.Operations {
+		if item == operation {
+			return true
+		}
+	}
+	return false
+}
+
+// newChainCapability fills what a stored chain leaves empty from the built-in chain of the same name,
+// operations default to the ones of the chain family.
+func newChainCapability(chain Chain, chainInfo ChainInfo) *ChainCapability {
+	defaultInfo := defaultChainInfos[chain]
+	res := &ChainCapability{
+		Name:           chain,
+		DisplayName:    chainInfo.DisplayName,
+		Family:         chainInfo.Family,
+		ChainID:        chainInfo.ChainID,
+		NativeCurrency: chainInfo.NativeCurrency,
+		Operations:     chainInfo.Operations,
+	}
+	if res.DisplayName == "" {
+		res.DisplayName = defaultInfo.DisplayName
+	}
+	if res.DisplayName == "" {
+		res.DisplayName = string(chain)
+	}
+	if res.Family == "" {
+		res.Family = defaultInfo.Family
+	}
+	if res.Family == "" && chainInfo.IsEVM {
+		res.Family = FamilyEVM
+	}
+	if res.NativeCurrency == nil {
+		res.NativeCurrency = defaultInfo.NativeCurrency
+	}
+	if len(res.Operations) == 0 {
+		res.Operations = defaultInfo.Operations
+	}
+	if len(res.Operations) == 0 {
+		res.Operations = familyOperations[res.Family]
+	}
+	return res
+}
+
+func (svc *chainService) GetChains() []*ChainCapability {
+	chains := svc.chainRegistry.List()
+	res := make([]*ChainCapability, 0, len(chains))
+	for chain, chainInfo := range chains {
+		res = append(res, newChainCapability(chain, chainInfo))
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Name < res[j].Name
+	})
+	return res
+}
+
+// CheckOperation returns setting.ErrNotSupportedChain or setting.ErrNotSupportedOperation
+// when the chain can not serve the operation.
+func (svc *chainService) CheckOperation(chain Chain, operation string) error {
+	chainInfo, ok := svc.getChain(chain)
+	if !ok {
+		return setting.ErrNotSupportedChain
+	}
+	if !newChainCapability(chain, chainInfo).Supports(operation) {
+		return fmt.Errorf("%w %s of chain %s", setting.ErrNotSupportedOperation, operation, chain)
+	}
+	return nil
+}
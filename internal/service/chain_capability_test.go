@@ -0,0 +1,80 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+
+	"nimbus-enhance-api/internal/setting"
+)
+
+func TestNewChainCapability(t *testing.T) {
+	convey.Convey("TestNewChainCapability", t, func() {
+		testCases := []struct {
+			name       string
+			chain      Chain
+			chainInfo  ChainInfo
+			family     string
+			operations []string
+		}{
+			{
+				name:       "Stored evm chain defaults to the evm operations",
+				chain:      Chain("custom-evm"),
+				chainInfo:  ChainInfo{IsEVM: true},
+				family:     FamilyEVM,
+				operations: []string{OperationLatestBlock, OperationBlock, OperationTxSearch, OperationGas, OperationBlockRaw},
+			},
+			{
+				name:       "Klaytn serves no raw dump",
+				chain:      Klaytn,
+				chainInfo:  defaultChainInfos[Klaytn],
+				family:     FamilyEVM,
+				operations: []string{OperationLatestBlock, OperationBlock, OperationTxSearch, OperationGas},
+			},
+			{
+				name:       "Near",
+				chain:      Near,
+				chainInfo:  defaultChainInfos[Near],
+				family:     FamilyNear,
+				operations: []string{OperationLatestBlock, OperationBlock, OperationTxSearch},
+			},
+			{
+				name:       "UTXO",
+				chain:      Bitcoin,
+				chainInfo:  defaultChainInfos[Bitcoin],
+				family:     FamilyUTXO,
+				operations: []string{OperationLatestBlock, OperationBlock, OperationTxSearch},
+			},
+			{
+				name:       "Stored row of a built-in chain keeps its operations",
+				chain:      Klaytn,
+				chainInfo:  ChainInfo{IsEVM: true},
+				family:     FamilyEVM,
+				operations: []string{OperationLatestBlock, OperationBlock, OperationTxSearch, OperationGas},
+			},
+		}
+
+		for _, tc := range testCases {
+			convey.Convey(tc.name, func() {
+				res := newChainCapability(tc.chain, tc.chainInfo)
+				convey.So(res.Family, convey.ShouldEqual, tc.family)
+				convey.So(res.Operations, convey.ShouldResemble, tc.operations)
+			})
+		}
+	})
+}
+
+func TestChainService_CheckOperation(t *testing.T) {
+	convey.Convey("TestChainService_CheckOperation", t, func() {
+		svc := &chainService{chainRegistry: &chainRegistry{chains: map[Chain]ChainInfo{
+			Klaytn: defaultChainInfos[Klaytn],
+			Near:   defaultChainInfos[Near],
+		}}}
+
+		convey.So(svc.CheckOperation(Klaytn, OperationGas), convey.ShouldBeNil)
+		convey.So(errors.Is(svc.CheckOperation(Klaytn, OperationBlockRaw), setting.ErrNotSupportedOperation), convey.ShouldBeTrue)
+		convey.So(errors.Is(svc.CheckOperation(Near, OperationGas), setting.ErrNotSupportedOperation), convey.ShouldBeTrue)
+		convey.So(errors.Is(svc.CheckOperation(Bitcoin, OperationBlock), setting.ErrNotSupportedChain), convey.ShouldBeTrue)
+	})
+}
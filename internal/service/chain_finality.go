@@ -0,0 +1,130 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/tikivn/ultrago/u_logger"
+	"golang.org/x/sync/errgroup"
+
+	"nimbus-enhance-api/internal/entity"
+	"nimbus-enhance-api/internal/setting"
+)
+
+// commitment levels of the latest block, each family names its own
+const (
+	CommitmentLatest     = "latest"     // evm and chains with a single level
+	CommitmentSafe       = "safe"       // evm
+	CommitmentFinalized  = "finalized"  // evm, solana
+	CommitmentOptimistic = "optimistic" // near
+	CommitmentFinal      = "final"      // near
+	CommitmentProcessed  = "processed"  // solana
+	CommitmentConfirmed  = "confirmed"  // solana
+)
+
+// finalityReader is implemented by adapters of chains whose head differs by commitment level
+type finalityReader interface {
+	// Commitments lists the levels from the most recent to the most final and the level of GetLatestBlock
+	Commitments() (levels []string, defaultLevel string)
+	GetHeadNumber(ctx context.Context, commitment string) (uint64, error)
+	GetHeadBlock(ctx context.Context, commitment string) (*entity.Block, error)
+}
+
+// chainCommitments returns the commitment levels of the adapter, chains without finality have latest only
+func chainCommitments(adapter ChainAdapter) ([]string, string) {
+	if reader, ok := adapter.(finalityReader); ok {
+		return reader.Commitments()
+	}
+	return []string{CommitmentLatest}, CommitmentLatest
+}
+
+// GetLatestBlock returns the head block of commitment, the default level of the chain when empty,
+// with the head number of every level and the confirmations of the block.
+func (svc *chainService) GetLatestBlock(ctx context.Context, chain Chain, commitment string) (*entity.Block, error) {
+	if chain == "" {
+		return nil, fmt.Errorf("missing chain")
+	}
+	adapter, err := svc.getAdapter(chain)
+	if err != nil {
+		return nil, err
+	}
+	levels, defaultLevel := chainCommitments(adapter)
+	if commitment == "" {
+		commitment = defaultLevel
+	}
+	if !containsString(levels, commitment) {
+		return nil, fmt.Errorf("%w: %s, chain %s has %v", setting.ErrInvalidCommitment, commitment, chain, levels)
+	}
+	cacheKey := fmt.Sprintf("latest_block:%s:%s", string(chain), commitment)
+
+	// get data from cache
+	data, err := svc.redisRepo.Get(ctx, cacheKey)
+	if err == nil {
+		var res entity.Block
+		err = json.Unmarshal([]byte(data), &res)
+		return &res, err
+	}
+
+	// if no hit cache then call api
+	var res *entity.Block
+	if reader, ok := adapter.(finalityReader); ok {
+		res, err = reader.GetHeadBlock(ctx, commitment)
+	} else {
+		res, err = adapter.GetLatestBlock(ctx)
+	}
+	if err != nil {
+		return nil, err
+	}
+	res.Chain = string(chain)
+	res.Finality = svc.getFinality(ctx, chain, adapter, levels, commitment, res.Number)
+	_ = svc.redisRepo.Set(ctx, cacheKey, res)
+	return res, nil
+}
+
+// getFinality leaves out levels the upstream does not answer, such as safe on nodes without proof of stake
+func (svc *chainService) getFinality(ctx context.Context, chain Chain, adapter ChainAdapter, levels []string, commitment string, number uint64) *entity.BlockFinality {
+	ctx, logger := u_logger.GetLogger(ctx)
+	var (
+		mu  sync.Mutex
+		eg  errgroup.Group
+		res = &entity.BlockFinality{
+			Commitment: commitment,
+			Heights:    map[string]uint64{commitment: number},
+		}
+	)
+	reader, ok := adapter.(finalityReader)
+	for _, item := range levels {
+		level := item
+		if level == commitment || !ok {
+			continue
+		}
+		eg.Go(func() error {
+			height, err := reader.GetHeadNumber(ctx, level)
+			if err != nil {
+				logger.Warnf("failed to get %s head of chain %s: %v", level, chain, err)
+				return nil
+			}
+			mu.Lock()
+			res.Heights[level] = height
+			mu.Unlock()
+			return nil
+		})
+	}
+	_ = eg.Wait()
+
+	if head, ok := res.Heights[levels[0]]; ok && head > number {
+		res.Confirmations = head - number
+	}
+	return res
+}
+
+func containsString(items []string, item string) bool {
+	for _, v := range items {
+		if v == item {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,63 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+
+	"nimbus-enhance-api/internal/setting"
+)
+
+func TestChainService_GetLatestBlock(t *testing.T) {
+	convey.Convey("TestChainService_GetLatestBlock", t, func() {
+		ctx := context.Background()
+		chain := Chain("fake-finality")
+		upstream := newFakeUpstream(t)
+		slots := map[string]uint64{CommitmentProcessed: 250_000_010, CommitmentConfirmed: 250_000_008, CommitmentFinalized: 250_000_000}
+		upstream.Handle("getSlot", func(params []json.RawMessage) (interface{}, error) {
+			var config struct {
+				Commitment string `json:"commitment"`
+			}
+			if len(params) > 0 {
+				_ = json.Unmarshal(params[0], &config)
+			}
+			return slots[config.Commitment], nil
+		})
+		upstream.Result("getBlock", map[string]interface{}{
+			"blockhash":         "5wbD6GsVBReHetMUw17QcNne8BjB1xSKRoU4JYpafEx5",
+			"previousBlockhash": "9hSR6S7WPtxmTojgo6GG3k4yDPecgJY292j7xrsUGWBu",
+			"parentSlot":        249_999_999,
+			"blockTime":         1_707_000_000,
+			"blockHeight":       230_000_000,
+			"signatures":        []string{},
+		})
+		svc := registerFakeChain(t, chain, newSolanaAdapter(newTestChainInfo(t, Solana, upstream.URL), newTestClientPool(t)))
+
+		convey.Convey("Heights of solana include the processed slot", func() {
+			res, err := svc.GetLatestBlock(ctx, chain, "")
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(res.Number, convey.ShouldEqual, 250_000_000)
+			convey.So(res.Finality.Commitment, convey.ShouldEqual, CommitmentFinalized)
+			convey.So(res.Finality.Heights, convey.ShouldResemble, map[string]uint64{
+				CommitmentProcessed: 250_000_010,
+				CommitmentConfirmed: 250_000_008,
+				CommitmentFinalized: 250_000_000,
+			})
+			convey.So(res.Finality.Confirmations, convey.ShouldEqual, 10)
+		})
+
+		convey.Convey("Processed block is refused as not supported", func() {
+			_, err := svc.GetLatestBlock(ctx, chain, CommitmentProcessed)
+			convey.So(errors.Is(err, setting.ErrNotSupportedMethod), convey.ShouldBeTrue)
+			convey.So(upstream.Calls("getBlock"), convey.ShouldEqual, 0)
+		})
+
+		convey.Convey("Unknown commitment is refused", func() {
+			_, err := svc.GetLatestBlock(ctx, chain, CommitmentSafe)
+			convey.So(errors.Is(err, setting.ErrInvalidCommitment), convey.ShouldBeTrue)
+		})
+	})
+}
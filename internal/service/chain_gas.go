@@ -0,0 +1,87 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"sort"
+	"time"
+
+	"nimbus-enhance-api/internal/entity"
+	"nimbus-enhance-api/internal/setting"
+)
+
+const (
+	// gasEstimateTTL keeps estimates for about one block, fees change with every block
+	gasEstimateTTL = 10 * time.Second
+	// gasFeeHistoryBlocks is the number of recent blocks whose priority fees are sampled
+	gasFeeHistoryBlocks = 20
+)
+
+// gasPercentiles of the priority fees paid in recent blocks, for slow, standard and fast
+var gasPercentiles = []float64{25, 50, 75}
+
+// gasOracle is implemented by adapters of chains with a fee market
+type gasOracle interface {
+	GetGasEstimate(ctx context.Context) (*entity.GasEstimate, error)
+}
+
+func (svc *chainService) GetGasEstimate(ctx context.Context, chain Chain) (*entity.GasEstimate, error) {
+	adapter, err := svc.getAdapter(chain)
+	if err != nil {
+		return nil, err
+	}
+	oracle, ok := adapter.(gasOracle)
+	if !ok {
+		return nil, setting.ErrNotSupportedMethod
+	}
+	cacheKey := fmt.Sprintf("gas:%s", string(chain))
+
+	// get data from cache
+	data, err := svc.redisRepo.Get(ctx, cacheKey)
+	if err == nil {
+		var res entity.GasEstimate
+		err = json.Unmarshal([]byte(data), &res)
+		return &res, err
+	}
+
+	// if no hit cache then call api
+	res, err := oracle.GetGasEstimate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	res.Chain = string(chain)
+
+	// cache result into redis
+	_ = svc.redisRepo.Set(ctx, cacheKey, res)
+	_ = svc.redisRepo.Expire(ctx, cacheKey, gasEstimateTTL)
+	return res, nil
+}
+
+// percentile returns the nearest-rank percentile of values, 0 when there is none
+func percentile(values []uint64, p float64) uint64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := make([]uint64, len(values))
+	copy(sorted, values)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	rank := int(p / 100 * float64(len(sorted)))
+	if rank >= len(sorted) {
+		rank = len(sorted) - 1
+	}
+	return sorted[rank]
+}
+
+// bigToUint64 caps fees too large for uint64, nodes answer such values only for broken blocks
+func bigToUint64(value *big.Int) uint64 {
+	if value == nil || value.Sign() < 0 {
+		return 0
+	}
+	if !value.IsUint64() {
+		return ^uint64(0)
+	}
+	return value.Uint64()
+}
@@ -0,0 +1,136 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/smartystreets/goconvey/convey"
+
+	"nimbus-enhance-api/internal/entity"
+)
+
+func TestPercentile(t *testing.T) {
+	convey.Convey("TestPercentile", t, func() {
+		cases := []struct {
+			values []uint64
+			p      float64
+			want   uint64
+		}{
+			{values: nil, p: 50, want: 0},
+			{values: []uint64{7}, p: 50, want: 7},
+			{values: []uint64{30, 10, 20}, p: 50, want: 20},
+			{values: []uint64{40, 10, 30, 20}, p: 50, want: 30},
+			{values: []uint64{40, 10, 30, 20}, p: 25, want: 20},
+			{values: []uint64{40, 10, 30, 20}, p: 0, want: 10},
+			{values: []uint64{40, 10, 30, 20}, p: 100, want: 40},
+		}
+		for _, c := range cases {
+			convey.So(percentile(c.values, c.p), convey.ShouldEqual, c.want)
+		}
+
+		convey.Convey("Values are not sorted in place", func() {
+			values := []uint64{3, 1, 2}
+			percentile(values, 50)
+			convey.So(values, convey.ShouldResemble, []uint64{3, 1, 2})
+		})
+	})
+}
+
+func newTestFeeHistory(oldest uint64, baseFees []int64, gasUsedRatios []float64, rewards [][]int64) evmFeeHistory {
+	history := evmFeeHistory{OldestBlock: hexutil.Uint64(oldest), GasUsedRatio: gasUsedRatios}
+	for _, fee := range baseFees {
+		history.BaseFeePerGas = append(history.BaseFeePerGas, (*hexutil.Big)(big.NewInt(fee)))
+	}
+	for _, reward := range rewards {
+		items := make([]*hexutil.Big, 0, len(reward))
+		for _, fee := range reward {
+			items = append(items, (*hexutil.Big)(big.NewInt(fee)))
+		}
+		history.Reward = append(history.Reward, items)
+	}
+	return history
+}
+
+func TestNewEvmGasEstimate(t *testing.T) {
+	convey.Convey("TestNewEvmGasEstimate", t, func() {
+		history := newTestFeeHistory(100,
+			[]int64{90, 95, 100, 105, 110},
+			[]float64{0.5, 0, 0.9, 0.3},
+			[][]int64{{1, 5, 9}, {0, 0, 0}, {3, 6, 12}, {2, 4, 10}},
+		)
+		res := newEvmGasEstimate(history, 110)
+
+		convey.So(res.BlockNumber, convey.ShouldEqual, 103)
+		convey.So(res.Unit, convey.ShouldEqual, "wei")
+		// the empty block 101 is left out, each level is the median of its percentile over the other blocks
+		for _, c := range []struct {
+			fee         entity.GasFee
+			priorityFee uint64
+		}{
+			{fee: res.Slow, priorityFee: 2},
+			{fee: res.Standard, priorityFee: 5},
+			{fee: res.Fast, priorityFee: 10},
+		} {
+			convey.So(*c.fee.BaseFee, convey.ShouldEqual, 110)
+			convey.So(*c.fee.PriorityFee, convey.ShouldEqual, c.priorityFee)
+			convey.So(*c.fee.MaxFee, convey.ShouldEqual, 2*110+c.priorityFee)
+			convey.So(c.fee.GasPrice, convey.ShouldBeNil)
+		}
+	})
+}
+
+func TestChainService_GetGasEstimate(t *testing.T) {
+	convey.Convey("TestChainService_GetGasEstimate", t, func() {
+		ctx := context.Background()
+		chain := Chain("fake-gas")
+		upstream := newFakeUpstream(t)
+		upstream.Result("eth_gasPrice", "0x3b9aca00")
+		svc := registerFakeChain(t, chain, newEvmAdapter(newTestChainInfo(t, Ethereum, upstream.URL), newTestClientPool(t)))
+
+		convey.Convey("Fee history is sampled and cached", func() {
+			upstream.Result("eth_feeHistory", map[string]interface{}{
+				"oldestBlock":   "0x64",
+				"baseFeePerGas": []string{"0x64", "0x6e"},
+				"gasUsedRatio":  []float64{0.5},
+				"reward":        [][]string{{"0x1", "0x2", "0x3"}},
+			})
+			for i := 0; i < 2; i++ {
+				res, err := svc.GetGasEstimate(ctx, chain)
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(res.Chain, convey.ShouldEqual, string(chain))
+				convey.So(*res.Standard.BaseFee, convey.ShouldEqual, 110)
+				convey.So(*res.Standard.PriorityFee, convey.ShouldEqual, 2)
+			}
+			convey.So(upstream.Calls("eth_feeHistory"), convey.ShouldEqual, 1)
+			convey.So(upstream.Calls("eth_gasPrice"), convey.ShouldEqual, 0)
+		})
+
+		convey.Convey("Chains without base fee get the gas price for every level", func() {
+			upstream.Result("eth_feeHistory", map[string]interface{}{
+				"oldestBlock":   "0x64",
+				"baseFeePerGas": []string{"0x0", "0x0"},
+				"gasUsedRatio":  []float64{0.5},
+				"reward":        [][]string{{"0x1", "0x2", "0x3"}},
+			})
+			res, err := svc.GetGasEstimate(ctx, chain)
+			convey.So(err, convey.ShouldBeNil)
+			for _, fee := range []entity.GasFee{res.Slow, res.Standard, res.Fast} {
+				convey.So(*fee.GasPrice, convey.ShouldEqual, 1_000_000_000)
+				convey.So(fee.BaseFee, convey.ShouldBeNil)
+			}
+		})
+
+		convey.Convey("Chains without fee history get the gas price for every level", func() {
+			upstream.Handle("eth_feeHistory", func([]json.RawMessage) (interface{}, error) {
+				return nil, errors.New("the method eth_feeHistory does not exist")
+			})
+			res, err := svc.GetGasEstimate(ctx, chain)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(*res.Fast.GasPrice, convey.ShouldEqual, 1_000_000_000)
+		})
+	})
+}
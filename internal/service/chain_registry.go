@@ -0,0 +1,332 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/tikivn/ultrago/u_logger"
+	"golang.org/x/sync/errgroup"
+	"gorm.io/gorm"
+
+	"nimbus-enhance-api/internal/conf"
+	"nimbus-enhance-api/internal/entity"
+	"nimbus-enhance-api/internal/infra"
+	"nimbus-enhance-api/internal/repo"
+	"nimbus-enhance-api/internal/setting"
+)
+
+// NewChainRegistry loads the chain snapshot once at startup so requests never wait on postgres.
+func NewChainRegistry(
+	ctx context.Context,
+	chainRepo repo.ChainRepo,
+	health *UpstreamHealth,
+	clientPool *infra.RpcClientPool,
+) (ChainRegistry, error) {
+	r := &chainRegistry{
+		chainRepo:  chainRepo,
+		health:     health,
+		clientPool: clientPool,
+		chains:     make(map[Chain]ChainInfo, len(defaultChainInfos)),
+		chainIDs:   make(map[string]verifiedChainID),
+	}
+	if err := r.Reload(ctx); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+type ChainRegistry interface {
+	Get(chain Chain) (ChainInfo, bool)
+	List() map[Chain]ChainInfo
+	Reload(ctx context.Context) error
+
+	GetChains(ctx context.Context) ([]*entity.Chain, error)
+	GetChain(ctx context.Context, chain Chain) (*entity.Chain, error)
+	CreateChain(ctx context.Context, item *entity.Chain) error
+	UpdateChain(ctx context.Context, item *entity.Chain) error
+	DisableChain(ctx context.Context, chain Chain) error
+}
+
+type chainRegistry struct {
+	sync.RWMutex
+	chainRepo  repo.ChainRepo
+	health     *UpstreamHealth
+	clientPool *infra.RpcClientPool
+	chains     map[Chain]ChainInfo
+	chainIDs   map[string]verifiedChainID // chain id reported by each endpoint
+}
+
+type verifiedChainID struct {
+	chainID    uint64
+	verifiedAt time.Time
+}
+
+func (r *chainRegistry) Get(chain Chain) (ChainInfo, bool) {
+	r.RLock()
+	defer r.RUnlock()
+	val, ok := r.chains[chain]
+	return val, ok
+}
+
+// List returns a copy of the current snapshot, so callers can range over it without holding the lock.
+func (r *chainRegistry) List() map[Chain]ChainInfo {
+	r.RLock()
+	defer r.RUnlock()
+	res := make(map[Chain]ChainInfo, len(r.chains))
+	for k, v := range r.chains {
+		res[k] = v
+	}
+	return res
+}
+
+// Reload rebuilds the snapshot from the built-in chains overridden by rows of table chains, api keys are filled
+// into the endpoints here only, so rotated keys apply at the next start to stored chains as well.
+// A disabled row removes the chain from the snapshot, so does a chain whose upstreams all fail chain id verification.
+func (r *chainRegistry) Reload(ctx context.Context) error {
+	ctx, logger := u_logger.GetLogger(ctx)
+	items, err := r.GetChains(ctx)
+	if err != nil {
+		logger.Errorf("failed to reload chains: %v", err)
+		return err
+	}
+	for i, item := range items {
+		items[i] = renderChain(item)
+	}
+
+	verifyErrs := r.verifyChainIDs(ctx, items)
+	chains := make(map[Chain]ChainInfo, len(items))
+	for _, item := range items {
+		if !item.Enabled {
+			continue
+		}
+		chain := Chain(item.Name)
+
+		verified := make([]entity.ChainUpstream, 0, len(item.GetUpstreams()))
+		for _, upstream := range item.GetUpstreams() {
+			if err := verifyErrs[fmt.Sprintf("%s:%s", chain, upstream.Endpoint)]; err != nil {
+				logger.Errorf("refuse upstream %s of chain %v: %v", upstream.Endpoint, chain, err)
+				continue
+			}
+			verified = append(verified, upstream)
+		}
+		if len(verified) == 0 {
+			logger.Errorf("refuse to serve chain %v: no upstream reports chain id %d", chain, item.ChainID)
+			continue
+		}
+
+		upstreams, err := newUpstreamGroup(chain, verified, r.health)
+		if err != nil {
+			logger.Errorf("failed to build upstreams of chain %v: %v", chain, err)
+			continue
+		}
+		chainInfo := newChainInfo(item)
+		chainInfo.upstreams = upstreams
+		chains[chain] = chainInfo
+	}
+
+	r.Lock()
+	r.chains = chains
+	r.Unlock()
+	return nil
+}
+
+// verifyChainIDs checks the chain id of every upstream of chains having one, so a misconfigured endpoint
+// can not serve data of another chain. Chain ids are asked again after conf CHAIN_ID_VERIFY_TTL, so an endpoint
+// repointed to another network is refused. Upstreams failing to answer keep the chain id they reported before,
+// those never verified are verified again at the next reload.
+func (r *chainRegistry) verifyChainIDs(ctx context.Context, items []*entity.Chain) map[string]error {
+	var (
+		mu  sync.Mutex
+		res = make(map[string]error)
+		eg  errgroup.Group
+	)
+	for _, item := range items {
+		if !item.Enabled || item.ChainID == 0 {
+			continue
+		}
+		chain := Chain(item.Name)
+		chainInfo := newChainInfo(item)
+		for _, upstream := range item.GetUpstreams() {
+			endpoint := upstream.Endpoint
+			eg.Go(func() error {
+				err := r.verifyChainID(ctx, chain, chainInfo, endpoint)
+				mu.Lock()
+				res[fmt.Sprintf("%s:%s", chain, endpoint)] = err
+				mu.Unlock()
+				return nil
+			})
+		}
+	}
+	_ = eg.Wait()
+	return res
+}
+
+func (r *chainRegistry) verifyChainID(ctx context.Context, chain Chain, chainInfo ChainInfo, endpoint string) error {
+	ctx, logger := u_logger.GetLogger(ctx)
+	r.RLock()
+	verified, ok := r.chainIDs[endpoint]
+	r.RUnlock()
+
+	if !ok || time.Since(verified.verifiedAt) > conf.Config.ChainIDVerifyTTL {
+		chainID, err := r.getChainID(ctx, chain, chainInfo, endpoint)
+		switch {
+		case err != nil && !ok:
+			return fmt.Errorf("failed to get chain id: %w", err)
+		case err != nil:
+			// a transient failure does not drop an upstream verified before
+			logger.Warnf("failed to verify chain id of upstream %s of chain %v again, keep chain id %d: %v",
+				endpoint, chain, verified.chainID, err)
+		default:
+			verified = verifiedChainID{chainID: chainID, verifiedAt: time.Now()}
+			r.Lock()
+			r.chainIDs[endpoint] = verified
+			r.Unlock()
+		}
+	}
+
+	if verified.chainID != chainInfo.ChainID {
+		return fmt.Errorf("%w: expected %d, endpoint reports %d", setting.ErrUnexpectedChainID, chainInfo.ChainID, verified.chainID)
+	}
+	return nil
+}
+
+func (r *chainRegistry) getChainID(ctx context.Context, chain Chain, chainInfo ChainInfo, endpoint string) (uint64, error) {
+	ctx, cancel := context.WithTimeout(ctx, conf.Config.UpstreamProbeTimeout)
+	defer cancel()
+
+	adapter, err := newUpstreamAdapter(chain, chainInfo, endpoint, r.clientPool)
+	if err != nil {
+		return 0, err
+	}
+	return adapter.GetChainID(ctx)
+}
+
+// GetChains returns every chain including disabled ones, stored rows take priority over built-in chains.
+func (r *chainRegistry) GetChains(ctx context.Context) ([]*entity.Chain, error) {
+	rows, err := r.chainRepo.GetList(ctx, r.chainRepo.S().SortBy("name", ""))
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]*entity.Chain, 0, len(rows)+len(defaultChainInfos))
+	stored := make(map[Chain]bool, len(rows))
+	for _, row := range rows {
+		stored[Chain(row.Name)] = true
+		res = append(res, row)
+	}
+	for chain := range defaultChainInfos {
+		if stored[chain] {
+			continue
+		}
+		chainInfo, ok := getDefaultChainInfo(chain)
+		if !ok {
+			continue
+		}
+		res = append(res, chainInfo.toEntity(chain))
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Name < res[j].Name
+	})
+	return res, nil
+}
+
+func (r *chainRegistry) CreateChain(ctx context.Context, item *entity.Chain) error {
+	if err := item.Validate(); err != nil {
+		return fmt.Errorf("%w: %v", setting.ErrInvalidChain, err)
+	}
+	if _, err := r.GetChain(ctx, Chain(item.Name)); err == nil {
+		return setting.ErrChainAlreadyExisted
+	} else if !errors.Is(err, setting.ErrNotSupportedChain) {
+		return err
+	}
+
+	if err := r.chainRepo.Create(ctx, item); err != nil {
+		return err
+	}
+	return r.Reload(ctx)
+}
+
+func (r *chainRegistry) UpdateChain(ctx context.Context, item *entity.Chain) error {
+	if err := item.Validate(); err != nil {
+		return fmt.Errorf("%w: %v", setting.ErrInvalidChain, err)
+	}
+	current, err := r.GetChain(ctx, Chain(item.Name))
+	if err != nil {
+		return err
+	}
+
+	if err := r.saveChain(ctx, current, item); err != nil {
+		return err
+	}
+	return r.Reload(ctx)
+}
+
+func (r *chainRegistry) DisableChain(ctx context.Context, chain Chain) error {
+	current, err := r.GetChain(ctx, chain)
+	if err != nil {
+		return err
+	}
+
+	item := *current
+	item.Enabled = false
+	if err := r.saveChain(ctx, current, &item); err != nil {
+		return err
+	}
+	return r.Reload(ctx)
+}
+
+// GetChain finds a chain in table chains first and falls back to the built-in chains, disabled chains included.
+func (r *chainRegistry) GetChain(ctx context.Context, chain Chain) (*entity.Chain, error) {
+	row, err := r.chainRepo.GetOne(ctx, r.chainRepo.S().FilterName(string(chain)))
+	if err == nil {
+		return row, nil
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	chainInfo, ok := getDefaultChainInfo(chain)
+	if !ok {
+		return nil, setting.ErrNotSupportedChain
+	}
+	return chainInfo.toEntity(chain), nil
+}
+
+// getDefaultChainInfo sets the endpoint of a built-in chain from its provider, keeping placeholder {api_key}
+// so the key is never stored with the chain. Built-in chains without provider are not served.
+func getDefaultChainInfo(chain Chain) (ChainInfo, bool) {
+	chainInfo, ok := defaultChainInfos[chain]
+	if !ok {
+		return ChainInfo{}, false
+	}
+	endpoint, ok := conf.Providers.ChainEndpointTemplate(string(chain))
+	if !ok {
+		return ChainInfo{}, false
+	}
+	chainInfo.Endpoint = endpoint
+	return chainInfo, true
+}
+
+// saveChain creates the row for a built-in chain on its first change, otherwise updates the stored row.
+func (r *chainRegistry) saveChain(ctx context.Context, current *entity.Chain, item *entity.Chain) error {
+	if current.ID == "" {
+		return r.chainRepo.Create(ctx, item)
+	}
+	item.Base = current.Base
+	return r.chainRepo.Update(ctx, item)
+}
+
+// renderChain returns a copy of the chain with the api key of its provider filled into the endpoints
+func renderChain(item *entity.Chain) *entity.Chain {
+	res := *item
+	res.Endpoint = conf.Providers.RenderChainEndpoint(item.Name, item.Endpoint)
+	res.Upstreams = nil
+	for _, upstream := range item.Upstreams {
+		upstream.Endpoint = conf.Providers.RenderChainEndpoint(item.Name, upstream.Endpoint)
+		res.Upstreams = append(res.Upstreams, upstream)
+	}
+	return &res
+}
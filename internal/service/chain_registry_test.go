@@ -0,0 +1,55 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/smartystreets/goconvey/convey"
+
+	"nimbus-enhance-api/internal/setting"
+)
+
+func TestChainRegistry_VerifyChainID(t *testing.T) {
+	convey.Convey("TestChainRegistry_VerifyChainID", t, func() {
+		ctx := context.Background()
+		upstream := newFakeUpstream(t)
+		upstream.Result("eth_chainId", "0x38")
+
+		r := &chainRegistry{
+			clientPool: newTestClientPool(t),
+			chainIDs:   make(map[string]verifiedChainID),
+		}
+		chainInfo := newTestChainInfo(t, BSC, upstream.URL)
+
+		convey.Convey("Verified chain id is cached", func() {
+			convey.So(r.verifyChainID(ctx, BSC, chainInfo, upstream.URL), convey.ShouldBeNil)
+			convey.So(r.verifyChainID(ctx, BSC, chainInfo, upstream.URL), convey.ShouldBeNil)
+			convey.So(upstream.Calls("eth_chainId"), convey.ShouldEqual, 1)
+		})
+
+		convey.Convey("Endpoint repointed to another network is refused after the ttl", func() {
+			convey.So(r.verifyChainID(ctx, BSC, chainInfo, upstream.URL), convey.ShouldBeNil)
+
+			upstream.Result("eth_chainId", "0x1")
+			r.chainIDs[upstream.URL] = verifiedChainID{chainID: 56, verifiedAt: time.Now().Add(-2 * time.Hour)}
+			err := r.verifyChainID(ctx, BSC, chainInfo, upstream.URL)
+			convey.So(errors.Is(err, setting.ErrUnexpectedChainID), convey.ShouldBeTrue)
+		})
+
+		convey.Convey("Upstream verified before is kept when it fails to answer", func() {
+			convey.So(r.verifyChainID(ctx, BSC, chainInfo, upstream.URL), convey.ShouldBeNil)
+
+			upstream.SetStatus(http.StatusBadGateway)
+			r.chainIDs[upstream.URL] = verifiedChainID{chainID: 56, verifiedAt: time.Now().Add(-2 * time.Hour)}
+			convey.So(r.verifyChainID(ctx, BSC, chainInfo, upstream.URL), convey.ShouldBeNil)
+		})
+
+		convey.Convey("Upstream never verified is refused when it fails to answer", func() {
+			upstream.SetStatus(http.StatusBadGateway)
+			convey.So(r.verifyChainID(ctx, BSC, chainInfo, upstream.URL), convey.ShouldNotBeNil)
+		})
+	})
+}
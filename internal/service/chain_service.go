@@ -3,29 +3,24 @@ package service
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"sort"
 	"strconv"
-	"strings"
 	"sync"
 	"time"
 
-	nearclient "github.com/eteu-technologies/near-api-go/pkg/client"
-	"github.com/eteu-technologies/near-api-go/pkg/client/block"
-	"github.com/ethereum/go-ethereum"
-	"github.com/ethereum/go-ethereum/core/types"
-	"github.com/ethereum/go-ethereum/rpc"
-	"github.com/portto/solana-go-sdk/client"
-	solanarpc "github.com/portto/solana-go-sdk/rpc"
 	"github.com/tikivn/ultrago/u_http_client"
 	"github.com/tikivn/ultrago/u_logger"
 	"golang.org/x/sync/errgroup"
 
+	"nimbus-enhance-api/internal/conf"
+	"nimbus-enhance-api/internal/entity"
 	"nimbus-enhance-api/internal/infra"
 	"nimbus-enhance-api/internal/repo"
 	"nimbus-enhance-api/internal/repo/redis"
 	"nimbus-enhance-api/internal/setting"
-	"nimbus-enhance-api/pkg/encoder"
 )
 
 type Chain string
@@ -40,358 +35,559 @@ const (
 	Avalance      Chain = "avalanche"
 	ArbitrumNitro Chain = "arbitrum-nitro"
 	Fantom        Chain = "fantom"
+	Base          Chain = "base"
+	ZkSyncEra     Chain = "zksync-era"
+	Linea         Chain = "linea"
+	Scroll        Chain = "scroll"
+	Gnosis        Chain = "gnosis"
+	OpBNB         Chain = "opbnb"
+	Mantle        Chain = "mantle"
 
 	// non EVM chain
 	Solana Chain = "solana"
 	Near   Chain = "near"
 	Klaytn Chain = "klaytn"
+	Aptos  Chain = "aptos"
+	Sui    Chain = "sui"
+	Tron   Chain = "tron"
+
+	// utxo chain
+	Bitcoin  Chain = "bitcoin"
+	Litecoin Chain = "litecoin"
+	Dogecoin Chain = "dogecoin"
 )
 
-// TODO: make this into env later
-var NODEREAL_API_KEY string = "5acc4f3c88f640b298c8444013d3bf43"
-
-// TODO: maybe need to add this to postgres database
+// defaultChainInfos are the built-in chains, rows of table chains override them at runtime.
+// Their endpoints are built from conf.Providers, see getDefaultChainInfo.
 // TODO: some chains has strange method so maybe we need to customize the result a little bit
-var chainInfos = map[Chain]ChainInfo{
+var defaultChainInfos = map[Chain]ChainInfo{
 	BSC: {
-		Endpoint: fmt.Sprintf("https://bsc-mainnet.nodereal.io/v1/%s", NODEREAL_API_KEY),
 		Methods: map[string]string{
+			"chainId":               "eth_chainId",
 			"getBlockByNumber":      "eth_getBlockByNumber",
+			"getBlockByHash":        "eth_getBlockByHash",
+			"feeHistory":            "eth_feeHistory",
+			"gasPrice":              "eth_gasPrice",
+			"getBlockReceipts":      "eth_getBlockReceipts",
 			"getTransactionReceipt": "eth_getTransactionReceipt",
 			"getTransactionByHash":  "eth_getTransactionByHash",
 		},
-		IsEVM: true,
+		IsEVM:          true,
+		ChainID:        56,
+		DisplayName:    "BNB Smart Chain",
+		Family:         FamilyEVM,
+		NativeCurrency: &entity.NativeCurrency{Name: "BNB", Symbol: "BNB", Decimals: 18},
+		Operations:     []string{OperationLatestBlock, OperationBlock, OperationTxSearch, OperationTxCount, OperationGas, OperationBlockRaw},
 	},
 	Ethereum: {
-		Endpoint: fmt.Sprintf("https://eth-mainnet.nodereal.io/v1/%s", NODEREAL_API_KEY),
 		Methods: map[string]string{
+			"chainId":               "eth_chainId",
 			"getBlockByNumber":      "eth_getBlockByNumber",
+			"getBlockByHash":        "eth_getBlockByHash",
+			"feeHistory":            "eth_feeHistory",
+			"gasPrice":              "eth_gasPrice",
+			"getBlockReceipts":      "eth_getBlockReceipts",
 			"getTransactionReceipt": "eth_getTransactionReceipt",
 			"getTransactionByHash":  "eth_getTransactionByHash",
 		},
-		IsEVM: true,
+		IsEVM:          true,
+		ChainID:        1,
+		DisplayName:    "Ethereum",
+		Family:         FamilyEVM,
+		NativeCurrency: &entity.NativeCurrency{Name: "Ether", Symbol: "ETH", Decimals: 18},
+		Operations:     []string{OperationLatestBlock, OperationBlock, OperationTxSearch, OperationTxCount, OperationGas, OperationBlockRaw},
 	},
 	Polygon: {
-		Endpoint: fmt.Sprintf("https://polygon-mainnet.nodereal.io/v1/%s", NODEREAL_API_KEY),
 		Methods: map[string]string{
+			"chainId":               "eth_chainId",
 			"getBlockByNumber":      "eth_getBlockByNumber",
+			"getBlockByHash":        "eth_getBlockByHash",
+			"feeHistory":            "eth_feeHistory",
+			"gasPrice":              "eth_gasPrice",
+			"getBlockReceipts":      "eth_getBlockReceipts",
 			"getTransactionReceipt": "eth_getTransactionReceipt",
 			"getTransactionByHash":  "eth_getTransactionByHash",
 		},
-		IsEVM: true,
+		IsEVM:          true,
+		ChainID:        137,
+		DisplayName:    "Polygon",
+		Family:         FamilyEVM,
+		NativeCurrency: &entity.NativeCurrency{Name: "POL", Symbol: "POL", Decimals: 18},
+		Operations:     []string{OperationLatestBlock, OperationBlock, OperationTxSearch, OperationTxCount, OperationGas, OperationBlockRaw},
 	},
 	Optimism: {
-		Endpoint: fmt.Sprintf("https://opt-mainnet.nodereal.io/v1/%s", NODEREAL_API_KEY),
 		Methods: map[string]string{
+			"chainId":               "eth_chainId",
 			"getBlockByNumber":      "eth_getBlockByNumber",
+			"getBlockByHash":        "eth_getBlockByHash",
+			"feeHistory":            "eth_feeHistory",
+			"gasPrice":              "eth_gasPrice",
+			"getBlockReceipts":      "eth_getBlockReceipts",
 			"getTransactionReceipt": "eth_getTransactionReceipt",
 			"getTransactionByHash":  "eth_getTransactionByHash",
 		},
-		IsEVM: true,
+		IsEVM:          true,
+		ChainID:        10,
+		DisplayName:    "OP Mainnet",
+		Family:         FamilyEVM,
+		NativeCurrency: &entity.NativeCurrency{Name: "Ether", Symbol: "ETH", Decimals: 18},
+		Operations:     []string{OperationLatestBlock, OperationBlock, OperationTxSearch, OperationTxCount, OperationGas, OperationBlockRaw},
 	},
 	ArbitrumNova: {
-		Endpoint: fmt.Sprintf("https://open-platform.nodereal.io/%s/arbitrum/", NODEREAL_API_KEY),
 		Methods: map[string]string{
+			"chainId":               "eth_chainId",
 			"getBlockByNumber":      "eth_getBlockByNumber",
+			"getBlockByHash":        "eth_getBlockByHash",
+			"feeHistory":            "eth_feeHistory",
+			"gasPrice":              "eth_gasPrice",
+			"getBlockReceipts":      "eth_getBlockReceipts",
 			"getTransactionReceipt": "eth_getTransactionReceipt",
 			"getTransactionByHash":  "eth_getTransactionByHash",
 		},
-		IsEVM: true,
+		IsEVM:          true,
+		ChainID:        42170,
+		DisplayName:    "Arbitrum Nova",
+		Family:         FamilyEVM,
+		NativeCurrency: &entity.NativeCurrency{Name: "Ether", Symbol: "ETH", Decimals: 18},
+		Operations:     []string{OperationLatestBlock, OperationBlock, OperationTxSearch, OperationTxCount, OperationGas, OperationBlockRaw},
 	},
 	Avalance: {
-		Endpoint: fmt.Sprintf("https://open-platform.nodereal.io/%s/avalanche-c/ext/bc/C/rpc", NODEREAL_API_KEY),
 		Methods: map[string]string{
+			"chainId":               "eth_chainId",
 			"getBlockByNumber":      "eth_getBlockByNumber",
+			"getBlockByHash":        "eth_getBlockByHash",
+			"feeHistory":            "eth_feeHistory",
+			"gasPrice":              "eth_gasPrice",
+			"getBlockReceipts":      "eth_getBlockReceipts",
 			"getTransactionReceipt": "eth_getTransactionReceipt",
 			"getTransactionByHash":  "eth_getTransactionByHash",
 		},
-		IsEVM: true,
+		IsEVM:          true,
+		ChainID:        43114,
+		DisplayName:    "Avalanche C-Chain",
+		Family:         FamilyEVM,
+		NativeCurrency: &entity.NativeCurrency{Name: "Avalanche", Symbol: "AVAX", Decimals: 18},
+		Operations:     []string{OperationLatestBlock, OperationBlock, OperationTxSearch, OperationTxCount, OperationGas, OperationBlockRaw},
 	},
 	ArbitrumNitro: {
-		Endpoint: fmt.Sprintf("https://open-platform.nodereal.io/%s/arbitrum-nitro/", NODEREAL_API_KEY),
 		Methods: map[string]string{
+			"chainId":               "eth_chainId",
 			"getBlockByNumber":      "eth_getBlockByNumber",
+			"getBlockByHash":        "eth_getBlockByHash",
+			"feeHistory":            "eth_feeHistory",
+			"gasPrice":              "eth_gasPrice",
+			"getBlockReceipts":      "eth_getBlockReceipts",
 			"getTransactionReceipt": "eth_getTransactionReceipt",
 			"getTransactionByHash":  "eth_getTransactionByHash",
 		},
-		IsEVM: true,
+		IsEVM:          true,
+		ChainID:        42161,
+		DisplayName:    "Arbitrum One",
+		Family:         FamilyEVM,
+		NativeCurrency: &entity.NativeCurrency{Name: "Ether", Symbol: "ETH", Decimals: 18},
 	},
 	Fantom: {
-		Endpoint: fmt.Sprintf("https://open-platform.nodereal.io/%s/fantom/", NODEREAL_API_KEY),
 		Methods: map[string]string{
+			"chainId":               "eth_chainId",
+			"getBlockByNumber":      "eth_getBlockByNumber",
+			"getBlockByHash":        "eth_getBlockByHash",
+			"feeHistory":            "eth_feeHistory",
+			"gasPrice":              "eth_gasPrice",
+			"getBlockReceipts":      "eth_getBlockReceipts",
+			"getTransactionReceipt": "eth_getTransactionReceipt",
+			"getTransactionByHash":  "eth_getTransactionByHash",
+		},
+		IsEVM:          true,
+		ChainID:        250,
+		DisplayName:    "Fantom",
+		Family:         FamilyEVM,
+		NativeCurrency: &entity.NativeCurrency{Name: "Fantom", Symbol: "FTM", Decimals: 18},
+		Operations:     []string{OperationLatestBlock, OperationBlock, OperationTxSearch, OperationTxCount, OperationGas, OperationBlockRaw},
+	},
+	Base: {
+		Methods: map[string]string{
+			"chainId":               "eth_chainId",
+			"getBlockByNumber":      "eth_getBlockByNumber",
+			"getBlockByHash":        "eth_getBlockByHash",
+			"feeHistory":            "eth_feeHistory",
+			"gasPrice":              "eth_gasPrice",
+			"getBlockReceipts":      "eth_getBlockReceipts",
+			"getTransactionReceipt": "eth_getTransactionReceipt",
+			"getTransactionByHash":  "eth_getTransactionByHash",
+		},
+		IsEVM:          true,
+		ChainID:        8453,
+		DisplayName:    "Base",
+		Family:         FamilyEVM,
+		NativeCurrency: &entity.NativeCurrency{Name: "Ether", Symbol: "ETH", Decimals: 18},
+		Operations:     []string{OperationLatestBlock, OperationBlock, OperationTxSearch, OperationTxCount, OperationGas, OperationBlockRaw},
+	},
+	ZkSyncEra: {
+		Methods: map[string]string{
+			"chainId":               "eth_chainId",
+			"getBlockByNumber":      "eth_getBlockByNumber",
+			"getBlockByHash":        "eth_getBlockByHash",
+			"feeHistory":            "eth_feeHistory",
+			"gasPrice":              "eth_gasPrice",
+			"getBlockReceipts":      "eth_getBlockReceipts",
+			"getTransactionReceipt": "eth_getTransactionReceipt",
+			"getTransactionByHash":  "eth_getTransactionByHash",
+		},
+		IsEVM:          true,
+		ChainID:        324,
+		DisplayName:    "zkSync Era",
+		Family:         FamilyEVM,
+		NativeCurrency: &entity.NativeCurrency{Name: "Ether", Symbol: "ETH", Decimals: 18},
+	},
+	Linea: {
+		Methods: map[string]string{
+			"chainId":               "eth_chainId",
+			"getBlockByNumber":      "eth_getBlockByNumber",
+			"getBlockByHash":        "eth_getBlockByHash",
+			"feeHistory":            "eth_feeHistory",
+			"gasPrice":              "eth_gasPrice",
+			"getBlockReceipts":      "eth_getBlockReceipts",
+			"getTransactionReceipt": "eth_getTransactionReceipt",
+			"getTransactionByHash":  "eth_getTransactionByHash",
+		},
+		IsEVM:          true,
+		ChainID:        59144,
+		DisplayName:    "Linea",
+		Family:         FamilyEVM,
+		NativeCurrency: &entity.NativeCurrency{Name: "Ether", Symbol: "ETH", Decimals: 18},
+	},
+	Scroll: {
+		Methods: map[string]string{
+			"chainId":               "eth_chainId",
+			"getBlockByNumber":      "eth_getBlockByNumber",
+			"getBlockByHash":        "eth_getBlockByHash",
+			"feeHistory":            "eth_feeHistory",
+			"gasPrice":              "eth_gasPrice",
+			"getBlockReceipts":      "eth_getBlockReceipts",
+			"getTransactionReceipt": "eth_getTransactionReceipt",
+			"getTransactionByHash":  "eth_getTransactionByHash",
+		},
+		IsEVM:          true,
+		ChainID:        534352,
+		DisplayName:    "Scroll",
+		Family:         FamilyEVM,
+		NativeCurrency: &entity.NativeCurrency{Name: "Ether", Symbol: "ETH", Decimals: 18},
+	},
+	Gnosis: {
+		Methods: map[string]string{
+			"chainId":               "eth_chainId",
+			"getBlockByNumber":      "eth_getBlockByNumber",
+			"getBlockByHash":        "eth_getBlockByHash",
+			"feeHistory":            "eth_feeHistory",
+			"gasPrice":              "eth_gasPrice",
+			"getBlockReceipts":      "eth_getBlockReceipts",
+			"getTransactionReceipt": "eth_getTransactionReceipt",
+			"getTransactionByHash":  "eth_getTransactionByHash",
+		},
+		IsEVM:          true,
+		ChainID:        100,
+		DisplayName:    "Gnosis",
+		Family:         FamilyEVM,
+		NativeCurrency: &entity.NativeCurrency{Name: "xDAI", Symbol: "XDAI", Decimals: 18},
+	},
+	OpBNB: {
+		Methods: map[string]string{
+			"chainId":               "eth_chainId",
+			"getBlockByNumber":      "eth_getBlockByNumber",
+			"getBlockByHash":        "eth_getBlockByHash",
+			"feeHistory":            "eth_feeHistory",
+			"gasPrice":              "eth_gasPrice",
+			"getBlockReceipts":      "eth_getBlockReceipts",
+			"getTransactionReceipt": "eth_getTransactionReceipt",
+			"getTransactionByHash":  "eth_getTransactionByHash",
+		},
+		IsEVM:          true,
+		ChainID:        204,
+		DisplayName:    "opBNB",
+		Family:         FamilyEVM,
+		NativeCurrency: &entity.NativeCurrency{Name: "BNB", Symbol: "BNB", Decimals: 18},
+	},
+	Mantle: {
+		Methods: map[string]string{
+			"chainId":               "eth_chainId",
 			"getBlockByNumber":      "eth_getBlockByNumber",
+			"getBlockByHash":        "eth_getBlockByHash",
+			"feeHistory":            "eth_feeHistory",
+			"gasPrice":              "eth_gasPrice",
+			"getBlockReceipts":      "eth_getBlockReceipts",
 			"getTransactionReceipt": "eth_getTransactionReceipt",
 			"getTransactionByHash":  "eth_getTransactionByHash",
 		},
-		IsEVM: true,
+		IsEVM:          true,
+		ChainID:        5000,
+		DisplayName:    "Mantle",
+		Family:         FamilyEVM,
+		NativeCurrency: &entity.NativeCurrency{Name: "Mantle", Symbol: "MNT", Decimals: 18},
 	},
 	Solana: {
-		Endpoint: fmt.Sprintf("https://open-platform.nodereal.io/%s/solana/", NODEREAL_API_KEY),
 		Methods: map[string]string{
 			"getBlockHeight":   "getBlockHeight",
 			"getBlockByNumber": "getBlock",
 		},
-		IsEVM: false,
+		IsEVM:          false,
+		DisplayName:    "Solana",
+		Family:         FamilySolana,
+		NativeCurrency: &entity.NativeCurrency{Name: "Solana", Symbol: "SOL", Decimals: 9},
+		Operations:     []string{OperationLatestBlock, OperationBlock, OperationTxSearch, OperationTxCount, OperationGas},
 	},
 	Near: {
-		Endpoint: fmt.Sprintf("https://open-platform.nodereal.io/%s/near/", NODEREAL_API_KEY),
 		Methods: map[string]string{
 			"getBlockByNumber":      "block",
 			"getTransactionReceipt": "EXPERIMENTAL_receipt",
 		},
-		IsEVM: false,
+		IsEVM:          false,
+		DisplayName:    "NEAR Protocol",
+		Family:         FamilyNear,
+		NativeCurrency: &entity.NativeCurrency{Name: "NEAR", Symbol: "NEAR", Decimals: 24},
 	},
 	Klaytn: {
-		Endpoint: fmt.Sprintf("https://open-platform.nodereal.io/%s/klaytn/", NODEREAL_API_KEY),
 		Methods: map[string]string{
+			"chainId":               "klay_chainID",
 			"getBlockByNumber":      "klay_getBlockByNumber",
+			"getBlockByHash":        "klay_getBlockByHash",
+			"feeHistory":            "klay_feeHistory",
+			"gasPrice":              "klay_gasPrice",
 			"getTransactionReceipt": "klay_getTransactionReceipt",
 			"getTransactionByHash":  "klay_getTransactionByHash",
 		},
-		IsEVM: true,
+		IsEVM:          true,
+		ChainID:        8217,
+		DisplayName:    "Klaytn",
+		Family:         FamilyEVM,
+		NativeCurrency: &entity.NativeCurrency{Name: "KLAY", Symbol: "KLAY", Decimals: 18},
+		// klaytn blocks have no ethereum header, so they can not be dumped raw
+		Operations: []string{OperationLatestBlock, OperationBlock, OperationTxSearch, OperationGas},
+	},
+	Aptos: {
+		Methods: map[string]string{
+			"getLedgerInfo":        "v1",
+			"getBlockByNumber":     "v1/blocks/by_height",
+			"getTransactionByHash": "v1/transactions/by_hash",
+		},
+		IsEVM:          false,
+		DisplayName:    "Aptos",
+		Family:         FamilyAptos,
+		NativeCurrency: &entity.NativeCurrency{Name: "Aptos Coin", Symbol: "APT", Decimals: 8},
+	},
+	Sui: {
+		Methods: map[string]string{
+			"getLatestBlockNumber":  "sui_getLatestCheckpointSequenceNumber",
+			"getBlockByNumber":      "sui_getCheckpoint",
+			"getTransactionByHash":  "sui_getTransactionBlock",
+			"getTransactionsByHash": "sui_multiGetTransactionBlocks",
+		},
+		IsEVM:          false,
+		DisplayName:    "Sui",
+		Family:         FamilySui,
+		NativeCurrency: &entity.NativeCurrency{Name: "Sui", Symbol: "SUI", Decimals: 9},
+	},
+	Tron: {
+		Methods: map[string]string{
+			"getLatestBlock":        "wallet/getnowblock",
+			"getBlockByNumber":      "wallet/getblockbynum",
+			"getBlockByHash":        "wallet/getblockbyid",
+			"getTransactionByHash":  "wallet/gettransactionbyid",
+			"getTransactionReceipt": "wallet/gettransactioninfobyid",
+		},
+		IsEVM:          false,
+		DisplayName:    "TRON",
+		Family:         FamilyTron,
+		NativeCurrency: &entity.NativeCurrency{Name: "TRON", Symbol: "TRX", Decimals: 6},
+	},
+	Bitcoin: {
+		Methods: map[string]string{
+			"getBlockchainInfo":    "getblockchaininfo",
+			"getBlockHash":         "getblockhash",
+			"getBlockByNumber":     "getblock",
+			"getTransactionByHash": "getrawtransaction",
+		},
+		IsEVM:          false,
+		DisplayName:    "Bitcoin",
+		Family:         FamilyUTXO,
+		NativeCurrency: &entity.NativeCurrency{Name: "Bitcoin", Symbol: "BTC", Decimals: 8},
+	},
+	Litecoin: {
+		Methods: map[string]string{
+			"getBlockchainInfo":    "getblockchaininfo",
+			"getBlockHash":         "getblockhash",
+			"getBlockByNumber":     "getblock",
+			"getTransactionByHash": "getrawtransaction",
+		},
+		IsEVM:          false,
+		DisplayName:    "Litecoin",
+		Family:         FamilyUTXO,
+		NativeCurrency: &entity.NativeCurrency{Name: "Litecoin", Symbol: "LTC", Decimals: 8},
+	},
+	Dogecoin: {
+		Methods: map[string]string{
+			"getBlockchainInfo":    "getblockchaininfo",
+			"getBlockHash":         "getblockhash",
+			"getBlockByNumber":     "getblock",
+			"getTransactionByHash": "getrawtransaction",
+		},
+		IsEVM:          false,
+		DisplayName:    "Dogecoin",
+		Family:         FamilyUTXO,
+		NativeCurrency: &entity.NativeCurrency{Name: "Dogecoin", Symbol: "DOGE", Decimals: 8},
 	},
 }
 
 func NewChainService(
 	redisClient *infra.RedisClient,
 	httpExecutor u_http_client.HttpExecutor,
+	chainRegistry ChainRegistry,
+	clientPool *infra.RpcClientPool,
+	abiDecoder AbiDecoder,
 ) ChainService {
 	return &chainService{
+		chainRegistry:   chainRegistry,
+		clientPool:      clientPool,
+		abiDecoder:      abiDecoder,
 		redisRepo:       redis.NewRedisRepo(redisClient, "chain", time.Minute),
+		blockRepo:       redis.NewRedisRepo(redisClient, "chain_block", 0),
 		httpExecutor:    httpExecutor,
-		chainBaseApiKey: "2MLSf73Pki3sFxxSx3ytr2TFenm", // TODO: add to config later
+		chainBaseApiKey: conf.Providers.ApiKey(conf.ProviderChainbase),
 	}
 }
 
 type ChainService interface {
-	GetLatestBlock(ctx context.Context, chain Chain) (interface{}, error)
-	SearchTransactionHash(ctx context.Context, hash string) (map[Chain]interface{}, error)
+	GetLatestBlock(ctx context.Context, chain Chain, commitment string) (*entity.Block, error)
+	GetBlock(ctx context.Context, chain Chain, id BlockID, includeTxs bool) (*entity.Block, error)
+	GetBlockRange(ctx context.Context, chain Chain, from, to uint64, limit int, cursor string) (*BlockPage, error)
+	GetBlockAtTimestamp(ctx context.Context, chain Chain, timestamp int64) (*entity.Block, error)
+	GetBlockRawLogs(ctx context.Context, chain Chain, number uint64) (*entity.BlockRawLogs, error)
+	SearchTransactionHash(ctx context.Context, hash string, chains []Chain, firstMatch, raw bool) (map[Chain]*entity.Transaction, error)
 	CountTotalTxLast24h(ctx context.Context, chain Chain) (int64, error)
+	GetGasEstimate(ctx context.Context, chain Chain) (*entity.GasEstimate, error)
+	GetChains() []*ChainCapability
+	CheckOperation(chain Chain, operation string) error
 }
 
 type chainService struct {
 	sync.RWMutex
+	chainRegistry   ChainRegistry
+	clientPool      *infra.RpcClientPool
 	redisRepo       repo.RedisRepo
+	blockRepo       repo.RedisRepo // immutable blocks without expiration
 	httpExecutor    u_http_client.HttpExecutor
 	chainBaseApiKey string
+	abiDecoder      AbiDecoder
 }
 
-func (svc *chainService) GetLatestBlock(ctx context.Context, chain Chain) (interface{}, error) {
-	if chain == "" {
-		return nil, fmt.Errorf("missing chain")
+// SearchTransactionHash asks chains, only the given chains when not empty, whose hash format matches.
+// With firstMatch the search stops once a chain finds the transaction and the other lookups are canceled.
+// Results are cached per chain in a Redis hash for an hour, chains not holding the hash are cached as null,
+// so a later search only asks the chains not searched yet. Pending transactions are cached apart for conf
+// PENDING_TX_CACHE_TTL, so they switch to mined on a later search. The raw data is dropped unless raw,
+// except on chains whose transactions are not normalized.
+func (svc *chainService) SearchTransactionHash(ctx context.Context, hash string, chains []Chain, firstMatch, raw bool) (map[Chain]*entity.Transaction, error) {
+	ctx, logger := u_logger.GetLogger(ctx)
+	if hash == "" {
+		return nil, fmt.Errorf("missing tx hash")
 	}
-	chainInfo, ok := svc.getChain(chain)
-	if !ok {
-		return nil, setting.ErrNotSupportedChain
+	var (
+		cacheKey        = fmt.Sprintf("tx_hash:%s", hash)
+		pendingCacheKey = fmt.Sprintf("tx_pending:%s", hash)
+	)
+
+	candidates := svc.chainRegistry.List()
+	if len(chains) > 0 {
+		candidates = make(map[Chain]ChainInfo, len(chains))
+		for _, chain := range chains {
+			chainInfo, ok := svc.chainRegistry.Get(chain)
+			if !ok {
+				return nil, fmt.Errorf("%w: %s", setting.ErrNotSupportedChain, chain)
+			}
+			candidates[chain] = chainInfo
+		}
 	}
-	cacheKey := fmt.Sprintf("latest_block:%s", string(chain))
 
 	// get data from cache
-	data, err := svc.redisRepo.Get(ctx, cacheKey)
-	if err == nil {
-		var res interface{}
-		err = json.Unmarshal([]byte(data), &res)
-		return res, err
+	cached, err := svc.redisRepo.HGetAll(ctx, cacheKey)
+	if err != nil {
+		cached = nil
 	}
-
-	// if no hit cache then call api
-	if chain == Solana {
-		client := client.NewClient(chainInfo.Endpoint)
-		latestBlockNumber, err := client.GetSlot(ctx)
-		if err != nil {
-			return nil, err
-		}
-
-		var (
-			maxSupportedTransactionVersion uint8 = 0
-			rewards                              = false
-		)
-		res, err := client.GetBlockWithConfig(
-			ctx,
-			latestBlockNumber,
-			solanarpc.GetBlockConfig{
-				Encoding:                       solanarpc.GetBlockConfigEncodingBase64,
-				TransactionDetails:             solanarpc.GetBlockConfigTransactionDetailsNone,
-				MaxSupportedTransactionVersion: &maxSupportedTransactionVersion,
-				Rewards:                        &rewards,
-			},
-		)
-		if err != nil {
-			return nil, err
-		}
-		_ = svc.redisRepo.Set(ctx, cacheKey, res)
-		return res, nil
-	} else if chain == Near {
-		client, err := nearclient.NewClient(chainInfo.Endpoint)
-		if err != nil {
-			return nil, setting.ErrClientConnectionFailure
-		}
-		res, err := client.BlockDetails(ctx, block.FinalityFinal())
-		if err != nil {
-			return nil, err
-		}
-		_ = svc.redisRepo.Set(ctx, cacheKey, res)
-		return res, nil
-	} else if chain == Klaytn {
-		client, cleanup, err := infra.NewRpcClient(ctx, chainInfo.Endpoint)
-		if err != nil {
-			return nil, setting.ErrClientConnectionFailure
+	if cachedPending, err := svc.redisRepo.HGetAll(ctx, pendingCacheKey); err == nil && len(cachedPending) > 0 {
+		if cached == nil {
+			cached = make(map[string]string, len(cachedPending))
 		}
-		defer cleanup()
-
-		var res interface{}
-		err = client.CallContext(ctx, &res, chainInfo.Methods["getBlockByNumber"], "latest", false)
-		if err != nil {
-			return nil, err
-		} else if err == nil && res == nil {
-			err = ethereum.NotFound
+		for chain, data := range cachedPending {
+			if _, ok := cached[chain]; !ok {
+				cached[chain] = data
+			}
 		}
-		_ = svc.redisRepo.Set(ctx, cacheKey, res)
-		return res, nil
-	} else if chainInfo.IsEVM {
-		client, cleanup, err := infra.NewRpcClient(ctx, chainInfo.Endpoint)
-		if err != nil {
-			return nil, setting.ErrClientConnectionFailure
+	}
+
+	var (
+		res       = make(map[Chain]*entity.Transaction, 0)
+		adapters  = make(map[Chain]ChainAdapter, 0)
+		remaining = make(map[Chain]ChainAdapter, 0)
+	)
+	for chain, chainInfo := range candidates {
+		if !newChainCapability(chain, chainInfo).Supports(OperationTxSearch) {
+			continue
 		}
-		defer cleanup()
-
-		var res *types.Header
-		err = client.CallContext(ctx, &res, chainInfo.Methods["getBlockByNumber"], "latest", false)
-		if err != nil {
-			return nil, err
-		} else if err == nil && res == nil {
-			err = ethereum.NotFound
+		adapter, err := newChainAdapter(chain, chainInfo, svc.clientPool)
+		if err != nil || !adapter.IsTxHash(hash) {
+			continue
 		}
-		_ = svc.redisRepo.Set(ctx, cacheKey, res)
-		return res, nil
-	} else { // other non EVM chain
-		client, cleanup, err := infra.NewRpcClient(ctx, chainInfo.Endpoint)
-		if err != nil {
-			return nil, setting.ErrClientConnectionFailure
+		adapters[chain] = adapter
+		data, ok := cached[string(chain)]
+		if !ok {
+			remaining[chain] = adapter
+			continue
 		}
-		defer cleanup()
-
-		var res interface{}
-		err = client.CallContext(ctx, &res, chainInfo.Methods["getBlockByNumber"], "latest", false)
-		if err != nil {
-			return nil, err
-		} else if err == nil && res == nil {
-			err = ethereum.NotFound
+		var item *entity.Transaction
+		if err := json.Unmarshal([]byte(data), &item); err == nil && item != nil {
+			res[chain] = item
 		}
-		_ = svc.redisRepo.Set(ctx, cacheKey, res)
-		return res, nil
 	}
-}
-
-func (svc *chainService) SearchTransactionHash(ctx context.Context, hash string) (map[Chain]interface{}, error) {
-	ctx, logger := u_logger.GetLogger(ctx)
-	if hash == "" {
-		return nil, fmt.Errorf("missing tx hash")
-	}
-	cacheKey := fmt.Sprintf("tx_hash:%s", hash)
-
-	// get data from cache
-	data, err := svc.redisRepo.Get(ctx, cacheKey)
-	if err == nil {
-		var res map[Chain]interface{}
-		err = json.Unmarshal([]byte(data), &res)
-		return res, err
+	if firstMatch && len(res) > 0 {
+		return svc.viewTransactions(ctx, firstTxMatch(res, chains), adapters, raw), nil
+	} else if len(remaining) == 0 {
+		return svc.viewTransactions(ctx, res, adapters, raw), nil
 	}
 
+	// if no hit cache then call api
 	var (
-		eg, childCtx = errgroup.WithContext(ctx)
-		res          = make(map[Chain]interface{}, 0)
+		searchCtx, cancel = context.WithCancel(ctx)
+		eg, childCtx      = errgroup.WithContext(searchCtx)
+		found             = make(map[string]interface{}, len(remaining))
+		foundPending      = make(map[string]interface{}, 0)
 	)
-
-	// chain deploy EVM always has fixed-length 66 in tx hash
-	// https://stackoverflow.com/questions/72772567/how-long-ethereum-hash-length-block-transaction-address
-	if strings.HasPrefix(hash, "0x") && len(hash) == 66 {
-		for k, v := range chainInfos {
-			if !v.IsEVM {
-				continue
-			}
-
-			chain := k
-			chainInfo := v
-			eg.Go(func() error {
-				client, cleanup, err := infra.NewRpcClient(childCtx, chainInfo.Endpoint)
-				if err != nil {
-					logger.Errorf("failed to connect rpc client of chain %v: %v", chain, err)
-					return nil
-				}
-				defer cleanup()
-
-				var data = struct {
-					Receipt map[string]interface{} `json:"receipt"`
-					Tx      map[string]interface{} `json:"tx"`
-				}{}
-				err = client.CallContext(childCtx, &data.Receipt, chainInfo.Methods["getTransactionReceipt"], hash)
-				if err != nil {
-					logger.Errorf("failed to get transaction receipt in chain %v: %v", chain, err)
-					return nil
-				}
-
-				if data.Receipt == nil {
-					return nil
-				}
-				txHash, ok := data.Receipt["transactionHash"]
-				if ok {
-					var tx map[string]interface{}
-					err = client.CallContext(childCtx, &tx, chainInfo.Methods["getTransactionByHash"], txHash)
-					if err != nil {
-						logger.Errorf("failed to get transaction in chain %v: %v", chain, err)
-						return nil
-					}
-					if tx != nil {
-						data.Tx = tx
-					}
-				}
-				if data.Receipt != nil {
-					svc.Lock()
-					res[chain] = data
-					svc.Unlock()
-				}
-				return nil
-			})
-		}
-	} else if (len(hash) >= 86 && len(hash) <= 88) && encoder.IsBase58(hash) {
+	defer cancel()
+	for k, v := range remaining {
+		chain, adapter := k, v
 		eg.Go(func() error {
-			chainInfo := chainInfos[Solana]
-			client := client.NewClient(chainInfo.Endpoint)
-
-			r, err := client.GetTransaction(ctx, hash)
-			if err == nil {
-				if r != nil {
-					svc.Lock()
-					res[Solana] = r
-					svc.Unlock()
-				}
-			} else if err != nil {
-				logger.Errorf("failed to get transaction receipt in chain solana: %v", err)
-			}
-			return nil
-		})
-	} else if len(hash) >= 42 && len(hash) <= 44 {
-		eg.Go(func() error {
-			chainInfo := chainInfos[Near]
-			client, cleanup, err := infra.NewRpcClient(childCtx, chainInfo.Endpoint)
+			data, err := svc.searchTransaction(childCtx, adapter, hash)
 			if err != nil {
-				logger.Errorf("failed to connect rpc client of chain %v: %v", Near, err)
+				// lookups canceled by the first match are neither errors nor cached
+				if childCtx.Err() == nil {
+					logger.Errorf("failed to get transaction in chain %v: %v", chain, err)
+				}
 				return nil
 			}
-			defer cleanup()
-
-			// TODO: think about make same interface for this type
-			var r interface{}
-			err = client.CallContext(childCtx, &r, chainInfo.Methods["getTransactionReceipt"], hash)
-			if err != nil && err.(rpc.DataError).ErrorData() != nil {
-				switch err.(rpc.DataError).ErrorData().(type) {
-				case string:
-					logger.Errorf("failed to get transaction receipt in chain near: %v", err)
-				default:
-					svc.Lock()
-					res[Near] = err.(rpc.DataError).ErrorData()
-					svc.Unlock()
+			if data != nil {
+				data.Chain = string(chain)
+			}
+			value, _ := json.Marshal(data)
+
+			svc.Lock()
+			defer svc.Unlock()
+			if data != nil && data.Status == entity.TxStatusPending {
+				foundPending[string(chain)] = string(value)
+			} else {
+				found[string(chain)] = string(value)
+			}
+			if data != nil {
+				res[chain] = data
+				if firstMatch {
+					cancel()
 				}
 			}
 			return nil
@@ -404,8 +600,96 @@ func (svc *chainService) SearchTransactionHash(ctx context.Context, hash string)
 	}
 
 	// cache result into redis
-	_ = svc.redisRepo.Set(ctx, cacheKey, res)
-	_ = svc.redisRepo.Expire(ctx, cacheKey, 1*time.Hour)
+	if len(found) > 0 {
+		_ = svc.redisRepo.HSet(ctx, cacheKey, found)
+		_ = svc.redisRepo.Expire(ctx, cacheKey, 1*time.Hour)
+	}
+	if len(foundPending) > 0 {
+		_ = svc.redisRepo.HSet(ctx, pendingCacheKey, foundPending)
+		_ = svc.redisRepo.Expire(ctx, pendingCacheKey, conf.Config.PendingTxCacheTTL)
+	}
+	if firstMatch {
+		res = firstTxMatch(res, chains)
+	}
+	return svc.viewTransactions(ctx, res, adapters, raw), nil
+}
+
+// firstTxMatch keeps one chain of the results, several chains answer at the same time only for the same hash on forks.
+// The chain kept is the first one of chains holding the transaction, by chain name when chains is empty,
+// so the same search answers the same chain.
+func firstTxMatch(res map[Chain]*entity.Transaction, chains []Chain) map[Chain]*entity.Transaction {
+	if len(chains) == 0 {
+		chains = make([]Chain, 0, len(res))
+		for chain := range res {
+			chains = append(chains, chain)
+		}
+		sort.Slice(chains, func(i, j int) bool { return chains[i] < chains[j] })
+	}
+	for _, chain := range chains {
+		if data, ok := res[chain]; ok {
+			return map[Chain]*entity.Transaction{chain: data}
+		}
+	}
+	return res
+}
+
+// viewTransactions decodes inputs and logs and drops raw data on copies, the results may be shared with the cache writes.
+// Inputs and logs are decoded on every answer instead of cached, so abis uploaded later apply to cached transactions.
+func (svc *chainService) viewTransactions(ctx context.Context, res map[Chain]*entity.Transaction, adapters map[Chain]ChainAdapter, raw bool) map[Chain]*entity.Transaction {
+	ctx, logger := u_logger.GetLogger(ctx)
+	for chain, item := range res {
+		tx := *item
+		if tx.Input != "" {
+			decoded, err := svc.abiDecoder.DecodeInput(ctx, chain, tx.To, tx.Input)
+			if err != nil {
+				logger.Warnf("failed to decode input of transaction %s of chain %s: %v", tx.Hash, chain, err)
+			}
+			tx.DecodedInput = decoded
+		}
+		if len(tx.Logs) > 0 {
+			tx.Logs = append([]entity.LogData(nil), tx.Logs...)
+			logs := make([]*entity.LogData, 0, len(tx.Logs))
+			for i := range tx.Logs {
+				logs = append(logs, &tx.Logs[i])
+			}
+			if err := svc.abiDecoder.DecodeLogs(ctx, chain, logs); err != nil {
+				logger.Warnf("failed to decode logs of transaction %s of chain %s: %v", tx.Hash, chain, err)
+			}
+		}
+		if _, ok := adapters[chain].(txNormalizer); ok && !raw {
+			tx.Raw = nil
+		}
+		res[chain] = &tx
+	}
+	return res
+}
+
+// searchTransaction looks up the receipt first, chains without receipt concept are looked up by transaction only.
+// A transaction found without its receipt is not mined yet and answered as pending.
+func (svc *chainService) searchTransaction(ctx context.Context, adapter ChainAdapter, hash string) (*entity.Transaction, error) {
+	var data TransactionData
+	receipt, err := adapter.GetTransactionReceipt(ctx, hash)
+	hasReceipt := err == nil
+	if err == nil {
+		data.Receipt = receipt
+	} else if !errors.Is(err, setting.ErrNotSupportedMethod) {
+		return nil, err
+	}
+
+	tx, err := adapter.GetTransactionByHash(ctx, hash)
+	if err == nil {
+		data.Tx = tx
+	} else if !errors.Is(err, setting.ErrNotSupportedMethod) {
+		return nil, err
+	}
+
+	if data.Receipt == nil && data.Tx == nil {
+		return nil, nil
+	}
+	res := normalizeTransaction(ctx, adapter, hash, &data)
+	if hasReceipt && data.Receipt == nil {
+		res.Status = entity.TxStatusPending
+	}
 	return res, nil
 }
 
@@ -431,7 +715,7 @@ func (svc *chainService) CountTotalTxLast24h(ctx context.Context, chain Chain) (
 	}
 	client := u_http_client.
 		NewRetryHttpClient(svc.httpExecutor, 60*time.Second, 3).
-		WithUrl("https://api.chainbase.online/v1/dw/query", nil).
+		WithUrl(fmt.Sprintf("%s/dw/query", conf.Providers.Url(conf.ProviderChainbase)), nil).
 		WithHeaders(headers).
 		WithPayload(payload)
 	resp, err := client.Do(ctx, http.MethodPost)
@@ -475,14 +759,73 @@ func (svc *chainService) CountTotalTxLast24h(ctx context.Context, chain Chain) (
 }
 
 func (svc *chainService) getChain(chain Chain) (ChainInfo, bool) {
-	val, ok := chainInfos[chain]
-	return val, ok
+	return svc.chainRegistry.Get(chain)
+}
+
+func (svc *chainService) getAdapter(chain Chain) (ChainAdapter, error) {
+	chainInfo, ok := svc.getChain(chain)
+	if !ok {
+		return nil, setting.ErrNotSupportedChain
+	}
+	return newChainAdapter(chain, chainInfo, svc.clientPool)
 }
 
 type ChainInfo struct {
-	Endpoint string            `json:"endpoint"`
-	Methods  map[string]string `json:"methods"`
-	IsEVM    bool              `json:"is_evm"`
+	Endpoint  string                 `json:"endpoint"`
+	Upstreams []entity.ChainUpstream `json:"upstreams,omitempty"`
+	Methods   map[string]string      `json:"methods"`
+	IsEVM     bool                   `json:"is_evm"`
+	ChainID   uint64                 `json:"chain_id,omitempty"`
+
+	DisplayName    string                 `json:"display_name,omitempty"`
+	Family         string                 `json:"family,omitempty"`
+	NativeCurrency *entity.NativeCurrency `json:"native_currency,omitempty"`
+	Operations     []string               `json:"operations,omitempty"`
+
+	// upstreams is built by the chain registry from Endpoint and Upstreams
+	upstreams *upstreamGroup
+}
+
+func newChainInfo(item *entity.Chain) ChainInfo {
+	return ChainInfo{
+		Endpoint:  item.Endpoint,
+		Upstreams: item.Upstreams,
+		Methods:   item.Methods,
+		IsEVM:     item.IsEVM,
+		ChainID:   item.ChainID,
+
+		DisplayName:    item.DisplayName,
+		Family:         item.Family,
+		NativeCurrency: item.NativeCurrency,
+		Operations:     item.Operations,
+	}
+}
+
+// toEntity copies the methods, upstreams, currency and operations, so changes of the entity do not leak into the built-in chains.
+func (c ChainInfo) toEntity(chain Chain) *entity.Chain {
+	methods := make(map[string]string, len(c.Methods))
+	for k, v := range c.Methods {
+		methods[k] = v
+	}
+	var nativeCurrency *entity.NativeCurrency
+	if c.NativeCurrency != nil {
+		currency := *c.NativeCurrency
+		nativeCurrency = &currency
+	}
+	return &entity.Chain{
+		Name:      string(chain),
+		Endpoint:  c.Endpoint,
+		Upstreams: append([]entity.ChainUpstream(nil), c.Upstreams...),
+		Methods:   methods,
+		IsEVM:     c.IsEVM,
+		ChainID:   c.ChainID,
+		Enabled:   true,
+
+		DisplayName:    c.DisplayName,
+		Family:         c.Family,
+		NativeCurrency: nativeCurrency,
+		Operations:     append([]string(nil), c.Operations...),
+	}
 }
 
 type JsonError struct {
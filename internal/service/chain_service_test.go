@@ -1,3 +1,5 @@
+//go:build integration
+
 package service
 
 import (
@@ -7,23 +9,47 @@ import (
 	"testing"
 
 	"github.com/smartystreets/goconvey/convey"
+	"github.com/tikivn/ultrago/u_http_client"
+
+	"nimbus-enhance-api/internal/infra"
+	"nimbus-enhance-api/internal/repo/gorm"
+	"nimbus-enhance-api/internal/repo/gorm_scope"
 )
 
+// TestChainService calls the live upstreams and needs postgres and redis, run it with -tags integration
 func TestChainService(t *testing.T) {
 	ctx := context.Background()
 
 	convey.FocusConvey("TestChainService", t, func() {
-		svc := NewChainService()
+		db, cleanup, err := infra.NewPostgresSession()
+		convey.So(err, convey.ShouldBeNil)
+		defer cleanup()
+		redisClient, cleanup2, err := infra.NewRedisClient()
+		convey.So(err, convey.ShouldBeNil)
+		defer cleanup2()
+		clientPool, cleanup3, err := infra.NewRpcClientPool()
+		convey.So(err, convey.ShouldBeNil)
+		defer cleanup3()
+
+		baseRepo := gorm.NewBaseRepo(db)
+		base := gorm_scope.NewBase()
+		chainRegistry, err := NewChainRegistry(ctx, gorm.NewChainRepo(baseRepo, gorm_scope.NewChain(base)), NewUpstreamHealth(), clientPool)
+		convey.So(err, convey.ShouldBeNil)
+		abiDecoder := NewAbiDecoder(redisClient, chainRegistry,
+			gorm.NewFunctionSignatureRepo(baseRepo, gorm_scope.NewFunctionSignature(base)),
+			gorm.NewContractAbiRepo(baseRepo, gorm_scope.NewContractAbi(base)),
+		)
+		svc := NewChainService(redisClient, u_http_client.NewHttpExecutor(), chainRegistry, clientPool, abiDecoder)
 
 		convey.Convey("TestChainService_GetLatestBlock", func() {
-			res, err := svc.GetLatestBlock(ctx, BSC)
+			res, err := svc.GetLatestBlock(ctx, BSC, "")
 			convey.So(err, convey.ShouldBeNil)
 			data, _ := json.Marshal(res)
 			fmt.Println(string(data))
 		})
 
 		convey.FocusConvey("TestChainService_SearchTransactionHash", func() {
-			res, err := svc.SearchTransactionHash(ctx, "0xc92daec22a20426373626b0fee73cc168b50697f30538062687b4d02bc5a52f6")
+			res, err := svc.SearchTransactionHash(ctx, "0xc92daec22a20426373626b0fee73cc168b50697f30538062687b4d02bc5a52f6", nil, false, false)
 			convey.So(err, convey.ShouldBeNil)
 			data, _ := json.Marshal(res)
 			fmt.Println(string(data))
@@ -0,0 +1,213 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/tikivn/ultrago/u_logger"
+	"golang.org/x/sync/errgroup"
+
+	"nimbus-enhance-api/internal/conf"
+	"nimbus-enhance-api/internal/entity"
+	"nimbus-enhance-api/internal/infra"
+	"nimbus-enhance-api/internal/repo"
+	"nimbus-enhance-api/internal/repo/redis"
+	"nimbus-enhance-api/internal/setting"
+)
+
+// maxBlockSamples bounds the samples kept per chain whatever the window, so fast chains sampled often stay small
+const maxBlockSamples = 2000
+
+func NewChainStatsTracker(
+	redisClient *infra.RedisClient,
+	chainRegistry ChainRegistry,
+	clientPool *infra.RpcClientPool,
+) ChainStatsTracker {
+	return &chainStatsTracker{
+		chainRegistry: chainRegistry,
+		clientPool:    clientPool,
+		statsRepo:     redis.NewRedisRepo(redisClient, "chain_stats", 0),
+	}
+}
+
+// ChainStatsTracker samples the latest conf CHAIN_STATS_BLOCKS_PER_SAMPLE blocks of every chain and keeps the samples
+// of the last conf CHAIN_STATS_WINDOW in Redis. Sampled blocks need not be contiguous, block time is measured
+// between block numbers, so fast chains are sampled without fetching every block.
+type ChainStatsTracker interface {
+	Track(ctx context.Context) error
+	// GetStats computes the stats of the samples in window, conf CHAIN_STATS_WINDOW when 0 or longer
+	GetStats(ctx context.Context, chain Chain, window time.Duration) (*entity.ChainStats, error)
+}
+
+type chainStatsTracker struct {
+	chainRegistry ChainRegistry
+	clientPool    *infra.RpcClientPool
+	statsRepo     repo.RedisRepo // block samples without expiration, trimmed to the window
+}
+
+func (t *chainStatsTracker) Track(ctx context.Context) error {
+	ctx, logger := u_logger.GetLogger(ctx)
+
+	var eg errgroup.Group
+	eg.SetLimit(conf.Config.NumWorker)
+	for k, v := range t.chainRegistry.List() {
+		chain, chainInfo := k, v
+		if !newChainCapability(chain, chainInfo).Supports(OperationBlock) {
+			continue
+		}
+		eg.Go(func() error {
+			if err := t.trackChain(ctx, chain, chainInfo); err != nil {
+				logger.Errorf("failed to sample blocks of chain %v: %v", chain, err)
+			}
+			return nil
+		})
+	}
+	return eg.Wait()
+}
+
+func (t *chainStatsTracker) GetStats(ctx context.Context, chain Chain, window time.Duration) (*entity.ChainStats, error) {
+	if _, ok := t.chainRegistry.Get(chain); !ok {
+		return nil, fmt.Errorf("%w: %s", setting.ErrNotSupportedChain, chain)
+	}
+	if window <= 0 || window > conf.Config.ChainStatsWindow {
+		window = conf.Config.ChainStatsWindow
+	}
+
+	samples := t.getSamples(ctx, chain)
+	if len(samples) == 0 {
+		return nil, fmt.Errorf("%w: no blocks of chain %s sampled yet", ethereum.NotFound, chain)
+	}
+	last := samples[len(samples)-1]
+	for len(samples) > 1 && samples[0].Timestamp < last.Timestamp-int64(window.Seconds()) {
+		samples = samples[1:]
+	}
+	return newChainStats(chain, window, samples), nil
+}
+
+// trackChain samples the blocks after the last sampled one, at most conf CHAIN_STATS_BLOCKS_PER_SAMPLE from the head
+func (t *chainStatsTracker) trackChain(ctx context.Context, chain Chain, chainInfo ChainInfo) error {
+	adapter, err := newChainAdapter(chain, chainInfo, t.clientPool)
+	if err != nil {
+		return err
+	}
+	head, err := adapter.GetLatestBlockNumber(ctx)
+	if err != nil {
+		return err
+	}
+
+	var (
+		perSample = conf.Config.ChainStatsBlocksPerSample
+		samples   = t.getSamples(ctx, chain)
+		from      = uint64(0)
+	)
+	if perSample == 0 {
+		perSample = 1
+	}
+	if head >= perSample {
+		from = head - perSample + 1
+	}
+	if n := len(samples); n > 0 {
+		if last := samples[n-1].Number; last >= head {
+			return nil
+		} else if last >= from {
+			from = last + 1
+		}
+	}
+
+	numbers := make([]uint64, 0, head-from+1)
+	for number := from; number <= head; number++ {
+		numbers = append(numbers, number)
+	}
+	blocks, err := fetchBlocksByNumber(ctx, adapter, numbers)
+	if err != nil {
+		return err
+	}
+	for _, block := range blocks {
+		if block != nil {
+			samples = append(samples, *new(entity.BlockSample).FromEntity(block))
+		}
+	}
+	if len(samples) == 0 {
+		return nil
+	}
+
+	// keep the samples of the window, a reorganized chain may have sampled numbers again
+	var (
+		last   = samples[len(samples)-1]
+		oldest = last.Timestamp - int64(conf.Config.ChainStatsWindow.Seconds())
+		kept   = make([]entity.BlockSample, 0, len(samples))
+	)
+	for i, item := range samples {
+		if item.Timestamp < oldest || (i+1 < len(samples) && item.Number >= samples[i+1].Number) {
+			continue
+		}
+		kept = append(kept, item)
+	}
+	if n := len(kept); n > maxBlockSamples {
+		kept = kept[n-maxBlockSamples:]
+	}
+	return t.statsRepo.Set(ctx, fmt.Sprintf("samples:%s", string(chain)), kept)
+}
+
+func (t *chainStatsTracker) getSamples(ctx context.Context, chain Chain) []entity.BlockSample {
+	data, err := t.statsRepo.Get(ctx, fmt.Sprintf("samples:%s", string(chain)))
+	if err != nil {
+		return nil
+	}
+	var res []entity.BlockSample
+	_ = json.Unmarshal([]byte(data), &res)
+	return res
+}
+
+// newChainStats averages block time between the first and the last sample, tps is the mean tx count
+// of the samples per block time. Gas utilization sums the samples having both gas used and gas limit.
+func newChainStats(chain Chain, window time.Duration, samples []entity.BlockSample) *entity.ChainStats {
+	var (
+		first = samples[0]
+		last  = samples[len(samples)-1]
+		res   = &entity.ChainStats{
+			Chain:         string(chain),
+			Window:        int64(window.Seconds()),
+			FromBlock:     first.Number,
+			ToBlock:       last.Number,
+			SampledBlocks: len(samples),
+			UpdatedAt:     last.Timestamp,
+		}
+		txCounts          []uint64
+		gasUsed, gasLimit float64
+	)
+	for _, item := range samples {
+		if item.TxCount != nil {
+			txCounts = append(txCounts, *item.TxCount)
+		}
+		if item.GasUsed != nil && item.GasLimit != nil && *item.GasLimit > 0 {
+			gasUsed += float64(*item.GasUsed)
+			gasLimit += float64(*item.GasLimit)
+		}
+	}
+
+	if last.Number > first.Number && last.Timestamp > first.Timestamp {
+		blockTime := float64(last.Timestamp-first.Timestamp) / float64(last.Number-first.Number)
+		res.AvgBlockTime = &blockTime
+		if len(txCounts) > 0 {
+			var total float64
+			for _, count := range txCounts {
+				total += float64(count)
+			}
+			tps := total / float64(len(txCounts)) / blockTime
+			res.TPS = &tps
+		}
+	}
+	if len(txCounts) > 0 {
+		median := percentile(txCounts, 50)
+		res.MedianTxCount = &median
+	}
+	if gasLimit > 0 {
+		utilization := gasUsed / gasLimit
+		res.GasUtilization = &utilization
+	}
+	return res
+}
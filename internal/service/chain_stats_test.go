@@ -0,0 +1,118 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/smartystreets/goconvey/convey"
+
+	"nimbus-enhance-api/internal/entity"
+)
+
+func TestChainStatsTracker_Track(t *testing.T) {
+	convey.Convey("TestChainStatsTracker_Track", t, func() {
+		ctx := context.Background()
+		chain := Chain("fake-stats")
+		adapter := newFakeAdapter(1000)
+		registerFakeChain(t, chain, adapter)
+		tracker := &chainStatsTracker{
+			chainRegistry: &chainRegistry{chains: map[Chain]ChainInfo{chain: {}}},
+			statsRepo:     newFakeRedisRepo(),
+		}
+		sampledNumbers := func() []uint64 {
+			res := make([]uint64, 0)
+			for _, item := range tracker.getSamples(ctx, chain) {
+				res = append(res, item.Number)
+			}
+			return res
+		}
+
+		convey.Convey("No stats before the first sample", func() {
+			_, err := tracker.GetStats(ctx, chain, 0)
+			convey.So(errors.Is(err, ethereum.NotFound), convey.ShouldBeTrue)
+		})
+
+		convey.Convey("Blocks after the last sample are sampled, at most CHAIN_STATS_BLOCKS_PER_SAMPLE", func() {
+			convey.So(tracker.trackChain(ctx, chain, ChainInfo{}), convey.ShouldBeNil)
+			convey.So(sampledNumbers(), convey.ShouldResemble, numberRange(991, 1000))
+
+			adapter.SetHead(1003)
+			calls := adapter.BlockCalls()
+			convey.So(tracker.trackChain(ctx, chain, ChainInfo{}), convey.ShouldBeNil)
+			convey.So(adapter.BlockCalls()-calls, convey.ShouldEqual, 3)
+			convey.So(sampledNumbers(), convey.ShouldResemble, numberRange(991, 1003))
+
+			calls = adapter.BlockCalls()
+			convey.So(tracker.trackChain(ctx, chain, ChainInfo{}), convey.ShouldBeNil)
+			convey.So(adapter.BlockCalls(), convey.ShouldEqual, calls)
+		})
+
+		convey.Convey("Samples older than CHAIN_STATS_WINDOW are dropped", func() {
+			convey.So(tracker.trackChain(ctx, chain, ChainInfo{}), convey.ShouldBeNil)
+			// one hour is 300 blocks of 12 seconds, so block 995 is the oldest one kept
+			adapter.SetHead(1295)
+			convey.So(tracker.trackChain(ctx, chain, ChainInfo{}), convey.ShouldBeNil)
+			convey.So(sampledNumbers(), convey.ShouldResemble, append(numberRange(995, 1000), numberRange(1286, 1295)...))
+		})
+
+		convey.Convey("Stats are computed over the samples of the requested window", func() {
+			for _, head := range []uint64{1000, 1100, 1200} {
+				adapter.SetHead(head)
+				convey.So(tracker.trackChain(ctx, chain, ChainInfo{}), convey.ShouldBeNil)
+			}
+
+			res, err := tracker.GetStats(ctx, chain, 0)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(res.Window, convey.ShouldEqual, 3600)
+			convey.So(res.FromBlock, convey.ShouldEqual, 991)
+			convey.So(res.ToBlock, convey.ShouldEqual, 1200)
+			convey.So(res.SampledBlocks, convey.ShouldEqual, 30)
+			convey.So(*res.AvgBlockTime, convey.ShouldEqual, 12)
+
+			res, err = tracker.GetStats(ctx, chain, 10*time.Minute)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(res.Window, convey.ShouldEqual, 600)
+			convey.So(res.FromBlock, convey.ShouldEqual, 1191)
+			convey.So(res.SampledBlocks, convey.ShouldEqual, 10)
+
+			// windows longer than CHAIN_STATS_WINDOW are cut to it
+			res, err = tracker.GetStats(ctx, chain, 24*time.Hour)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(res.Window, convey.ShouldEqual, 3600)
+		})
+	})
+}
+
+func TestNewChainStats(t *testing.T) {
+	convey.Convey("TestNewChainStats", t, func() {
+		count := func(value uint64) *uint64 { return &value }
+
+		convey.Convey("Block time is measured between sampled numbers", func() {
+			res := newChainStats(Ethereum, time.Hour, []entity.BlockSample{
+				{Number: 100, Timestamp: 1000, TxCount: count(10), GasUsed: count(50), GasLimit: count(100)},
+				{Number: 102, Timestamp: 1024, TxCount: count(30), GasUsed: count(30), GasLimit: count(100)},
+				{Number: 110, Timestamp: 1120, TxCount: count(20), GasUsed: count(70)},
+			})
+			convey.So(res.FromBlock, convey.ShouldEqual, 100)
+			convey.So(res.ToBlock, convey.ShouldEqual, 110)
+			convey.So(res.UpdatedAt, convey.ShouldEqual, 1120)
+			convey.So(*res.AvgBlockTime, convey.ShouldEqual, 12)
+			convey.So(*res.TPS, convey.ShouldAlmostEqual, 20.0/12)
+			convey.So(*res.MedianTxCount, convey.ShouldEqual, 20)
+			// the sample without gas limit is left out
+			convey.So(*res.GasUtilization, convey.ShouldAlmostEqual, 0.4)
+		})
+
+		convey.Convey("One sample has no block time", func() {
+			res := newChainStats(Ethereum, time.Hour, []entity.BlockSample{{Number: 100, Timestamp: 1000}})
+			convey.So(res.SampledBlocks, convey.ShouldEqual, 1)
+			convey.So(res.AvgBlockTime, convey.ShouldBeNil)
+			convey.So(res.TPS, convey.ShouldBeNil)
+			convey.So(res.MedianTxCount, convey.ShouldBeNil)
+			convey.So(res.GasUtilization, convey.ShouldBeNil)
+		})
+	})
+}
@@ -0,0 +1,71 @@
+package service
+
+import (
+	"context"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/tikivn/ultrago/u_logger"
+
+	"nimbus-enhance-api/internal/entity"
+)
+
+// txNormalizer is implemented by adapters reading the sender, fee and status out of their transaction data.
+// Transactions of other chains are answered with their raw data only.
+type txNormalizer interface {
+	NormalizeTransaction(ctx context.Context, hash string, data *TransactionData) (*entity.Transaction, error)
+}
+
+// normalizeTransaction keeps data as raw of the transaction, a failed normalization still answers the raw data
+func normalizeTransaction(ctx context.Context, adapter ChainAdapter, hash string, data *TransactionData) *entity.Transaction {
+	ctx, logger := u_logger.GetLogger(ctx)
+	res := &entity.Transaction{Hash: hash}
+	if normalizer, ok := adapter.(txNormalizer); ok {
+		tx, err := normalizer.NormalizeTransaction(ctx, hash, data)
+		if err != nil {
+			logger.Warnf("failed to normalize transaction %s: %v", hash, err)
+		} else {
+			res = tx
+		}
+	}
+	res.Raw = data
+	return res
+}
+
+// blockTimestamp leaves the timestamp of the transaction empty when the block can not be fetched
+func blockTimestamp(ctx context.Context, adapter ChainAdapter, id BlockID) int64 {
+	ctx, logger := u_logger.GetLogger(ctx)
+	block, err := adapter.GetBlock(ctx, id, false)
+	if err != nil {
+		logger.Warnf("failed to get block %s of transaction: %v", id, err)
+		return 0
+	}
+	return block.Timestamp
+}
+
+// mapString reads a string field of a json object decoded into a map
+func mapString(data map[string]interface{}, key string) string {
+	res, _ := data[key].(string)
+	return res
+}
+
+// mapHexBig reads a 0x prefixed quantity field of a json object, nil when missing or invalid
+func mapHexBig(data map[string]interface{}, key string) *big.Int {
+	res, err := hexutil.DecodeBig(mapString(data, key))
+	if err != nil {
+		return nil
+	}
+	return res
+}
+
+// sumDecimals adds decimal strings such as near yoctoNEAR amounts, invalid ones are skipped
+func sumDecimals(values ...string) *big.Int {
+	res := new(big.Int)
+	for _, value := range values {
+		if n, ok := new(big.Int).SetString(strings.TrimSpace(value), 10); ok {
+			res.Add(res, n)
+		}
+	}
+	return res
+}
@@ -0,0 +1,446 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/smartystreets/goconvey/convey"
+
+	"nimbus-enhance-api/internal/conf"
+	"nimbus-enhance-api/internal/entity"
+	"nimbus-enhance-api/internal/setting"
+)
+
+// loadFixture decodes testdata/name, recorded responses of the nodes of each chain
+func loadFixture(t *testing.T, name string, v interface{}) {
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEvmAdapter_NormalizeTransaction(t *testing.T) {
+	convey.Convey("TestEvmAdapter_NormalizeTransaction", t, func() {
+		ctx := context.Background()
+		var fixtures map[string]*TransactionData
+		loadFixture(t, "evm_transactions.json", &fixtures)
+		upstream := newFakeUpstream(t)
+		upstream.HandleEvmBlocks(18_000_000)
+		adapter := newEvmAdapter(newTestChainInfo(t, Ethereum, upstream.URL), newTestClientPool(t)).(txNormalizer)
+		blockNumber := func(number uint64) *uint64 { return &number }
+
+		cases := []struct {
+			name string
+			want *entity.Transaction
+			logs int
+		}{
+			{
+				name: "erc20_transfer",
+				want: &entity.Transaction{
+					Hash:        "0x5c504ed432cb51138bcf09aa5e8a410dd4a1e204ef84bfed1be16dfba1b22060",
+					BlockNumber: blockNumber(18_000_000),
+					BlockHash:   "0x8a3c5d1f5e9e1b7ad4c6d10f2b3b4e7c2f0f7f9f4a2b4d5e6f708192a3b4c5d6",
+					Timestamp:   1_600_000_000 + 12*18_000_000,
+					From:        "0x95222290dd7278aa3ddd389cc1e1d165cc4bafe5",
+					To:          "0xdac17f958d2ee523a2206206994597c13d831ec7",
+					Value:       "0",
+					Fee:         "921940000000000",
+					Status:      entity.TxStatusSuccess,
+					Method:      "0xa9059cbb",
+					Input:       "0xa9059cbb000000000000000000000000000000000000000000000000000000000000dead00000000000000000000000000000000000000000000000000000000000003e8",
+				},
+				logs: 1,
+			},
+			{
+				// a failed deployment pays all its gas at the gas price of the legacy transaction
+				name: "failed_create",
+				want: &entity.Transaction{
+					Hash:        "0x0b5b2fbc0e0e2c7c4d8b2e9a6f3e1d0c9b8a7f6e5d4c3b2a1f0e9d8c7b6a5f4e",
+					BlockNumber: blockNumber(17_999_999),
+					BlockHash:   "0x1f0e9d8c7b6a5f4e3d2c1b0a9f8e7d6c5b4a3f2e1d0c9b8a7f6e5d4c3b2a1f0e",
+					Timestamp:   1_600_000_000 + 12*17_999_999,
+					From:        "0x95222290dd7278aa3ddd389cc1e1d165cc4bafe5",
+					To:          "0x5fbdb2315678afecb367f032d93f642f64180aa3",
+					Value:       "0",
+					Fee:         "500000000000000",
+					Status:      entity.TxStatusFailed,
+					Method:      "create",
+					Input:       "0x6080604052348015600f57600080fd5b50",
+				},
+			},
+			{
+				name: "pending_transfer",
+				want: &entity.Transaction{
+					Hash:   "0x9d1a5c0f6e3b2a7d8c4f1e0b9a6d3c2f5e8b7a4d1c0f9e6b3a2d5c8f7e4b1a0d",
+					From:   "0x95222290dd7278aa3ddd389cc1e1d165cc4bafe5",
+					To:     "0x000000000000000000000000000000000000dead",
+					Value:  "1000000000000000000",
+					Status: entity.TxStatusPending,
+					Method: "transfer",
+					Input:  "0x",
+				},
+			},
+			{
+				// the l1 fee of op stack chains is added to the l2 execution fee
+				name: "optimism_l1_fee",
+				want: &entity.Transaction{
+					Hash:        "0x7e4b1a0d9d1a5c0f6e3b2a7d8c4f1e0b9a6d3c2f5e8b7a4d1c0f9e6b3a2d5c8f",
+					BlockNumber: blockNumber(17_999_998),
+					BlockHash:   "0x2e1d0c9b8a7f6e5d4c3b2a1f0e9d8c7b6a5f4e3d2c1b0a9f8e7d6c5b4a3f2e1d",
+					Timestamp:   1_600_000_000 + 12*17_999_998,
+					From:        "0x95222290dd7278aa3ddd389cc1e1d165cc4bafe5",
+					To:          "0x000000000000000000000000000000000000dead",
+					Value:       "1",
+					Fee:         "31000000000",
+					Status:      entity.TxStatusSuccess,
+					Method:      "transfer",
+					Input:       "0x",
+				},
+			},
+		}
+		for _, c := range cases {
+			c := c
+			convey.Convey(c.name, func() {
+				res, err := adapter.NormalizeTransaction(ctx, c.want.Hash, fixtures[c.name])
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(res.Logs, convey.ShouldHaveLength, c.logs)
+				res.Logs = nil
+				convey.So(res, convey.ShouldResemble, c.want)
+			})
+		}
+	})
+}
+
+func TestSolanaAdapter_NormalizeTransaction(t *testing.T) {
+	convey.Convey("TestSolanaAdapter_NormalizeTransaction", t, func() {
+		ctx := context.Background()
+		var fixtures map[string]json.RawMessage
+		loadFixture(t, "solana_transactions.json", &fixtures)
+		upstream := newFakeUpstream(t)
+		adapter := newSolanaAdapter(newTestChainInfo(t, Solana, upstream.URL), newTestClientPool(t))
+		slot := func(number uint64) *uint64 { return &number }
+
+		cases := []struct {
+			name string
+			want *entity.Transaction
+		}{
+			{
+				name: "transfer",
+				want: &entity.Transaction{
+					BlockNumber: slot(250_000_000),
+					Timestamp:   1_707_000_000,
+					From:        "AKnL4NNf3DGWZJS6cPknBuEGnVsV4A4m5tgebLHaRSZ9",
+					To:          "9hSR6S7WPtxmTojgo6GG3k4yDPecgJY292j7xrsUGWBu",
+					Value:       "1000000",
+					Fee:         "10000",
+					Status:      entity.TxStatusSuccess,
+					Method:      "compute_budget,system:transfer,memo",
+				},
+			},
+			{
+				name: "failed_transfer",
+				want: &entity.Transaction{
+					BlockNumber: slot(250_000_001),
+					Timestamp:   1_707_000_001,
+					From:        "AKnL4NNf3DGWZJS6cPknBuEGnVsV4A4m5tgebLHaRSZ9",
+					To:          "9hSR6S7WPtxmTojgo6GG3k4yDPecgJY292j7xrsUGWBu",
+					Value:       "1000000",
+					Fee:         "10000",
+					Status:      entity.TxStatusFailed,
+					Method:      "compute_budget,system:transfer,memo",
+				},
+			},
+		}
+		for _, c := range cases {
+			c := c
+			convey.Convey(c.name, func() {
+				// the sdk decodes the transaction out of the recorded getTransaction response
+				upstream.Result("getTransaction", fixtures[c.name])
+				hash := "2tN3u5yXJbBdbSGoqbGPxLs7Qo1vHSu3n3ZGwiyY5TKC9bu8KgzD6EWLwuV4XUMqj8KpwSyZLs4XDKYqGDNP4gbZ"
+				tx, err := adapter.GetTransactionByHash(ctx, hash)
+				convey.So(err, convey.ShouldBeNil)
+
+				res, err := adapter.(txNormalizer).NormalizeTransaction(ctx, hash, &TransactionData{Tx: tx})
+				convey.So(err, convey.ShouldBeNil)
+				c.want.Hash = hash
+				convey.So(res, convey.ShouldResemble, c.want)
+			})
+		}
+	})
+}
+
+func TestNearAdapter_NormalizeTransaction(t *testing.T) {
+	convey.Convey("TestNearAdapter_NormalizeTransaction", t, func() {
+		ctx := context.Background()
+		var fixtures map[string]interface{}
+		loadFixture(t, "near_transactions.json", &fixtures)
+		upstream := newFakeUpstream(t)
+		upstream.Result("block", fixtures["block"])
+		adapter := newNearAdapter(newTestChainInfo(t, Near, upstream.URL), newTestClientPool(t)).(txNormalizer)
+		height := func(number uint64) *uint64 { return &number }
+		const (
+			hash      = "2qo2mC7GvBBEPZTmZeYXsc5KhzboKSen6DekBytar1fy"
+			receiptID = "8V4wPcrdG9WWedNvPuTUZ6a3uHT8SpeddJGCNWSt1wj2"
+		)
+		// the node knows the status of the ft transfer sent by alice only
+		upstream.Handle("EXPERIMENTAL_tx_status", func(params []json.RawMessage) (interface{}, error) {
+			var txHash, signer string
+			if len(params) == 2 {
+				_ = json.Unmarshal(params[0], &txHash)
+				_ = json.Unmarshal(params[1], &signer)
+			}
+			if txHash != hash || signer != "alice.near" {
+				return nil, errors.New("UNKNOWN_TRANSACTION")
+			}
+			return fixtures["ft_transfer_status"], nil
+		})
+
+		cases := []struct {
+			name string
+			hash string
+			want *entity.Transaction
+		}{
+			{
+				// the fee sums the tokens burnt by the transaction and every receipt
+				name: "ft_transfer_status",
+				want: &entity.Transaction{
+					Hash:        hash,
+					BlockNumber: height(110_000_000),
+					BlockHash:   "5wbD6GsVBReHetMUw17QcNne8BjB1xSKRoU4JYpafEx5",
+					Timestamp:   1_700_000_000,
+					From:        "alice.near",
+					To:          "wrap.near",
+					Value:       "1",
+					Fee:         "592830000000000000000",
+					Status:      entity.TxStatusSuccess,
+					Method:      "FunctionCall:ft_transfer",
+				},
+			},
+			{
+				name: "failed_create_account_status",
+				want: &entity.Transaction{
+					Hash:        hash,
+					BlockNumber: height(110_000_000),
+					BlockHash:   "5wbD6GsVBReHetMUw17QcNne8BjB1xSKRoU4JYpafEx5",
+					Timestamp:   1_700_000_000,
+					From:        "alice.near",
+					To:          "bob.near",
+					Value:       "1000000000000000000000000",
+					Fee:         "84911012500000000000",
+					Status:      entity.TxStatusFailed,
+					Method:      "CreateAccount,Transfer",
+				},
+			},
+			{
+				// status, fee and block of the receipt view come from the status view asked with its signer
+				name: "ft_transfer_receipt",
+				want: &entity.Transaction{
+					Hash:        hash,
+					BlockNumber: height(110_000_000),
+					BlockHash:   "5wbD6GsVBReHetMUw17QcNne8BjB1xSKRoU4JYpafEx5",
+					Timestamp:   1_700_000_000,
+					From:        "alice.near",
+					To:          "wrap.near",
+					Value:       "1",
+					Fee:         "592830000000000000000",
+					Status:      entity.TxStatusSuccess,
+					Method:      "FunctionCall:ft_transfer",
+				},
+			},
+			{
+				// a receipt id is no transaction hash, the receipt view alone has no status, fee and block
+				name: "transfer_receipt",
+				hash: receiptID,
+				want: &entity.Transaction{
+					Hash:   receiptID,
+					From:   "alice.near",
+					To:     "bob.near",
+					Value:  "2500000000000000000000000",
+					Method: "Transfer",
+				},
+			},
+		}
+		for _, c := range cases {
+			c := c
+			convey.Convey(c.name, func() {
+				txHash := c.hash
+				if txHash == "" {
+					txHash = hash
+				}
+				res, err := adapter.NormalizeTransaction(ctx, txHash, &TransactionData{Receipt: fixtures[c.name]})
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(res, convey.ShouldResemble, c.want)
+			})
+		}
+	})
+}
+
+func TestChainService_SearchTransactionHash(t *testing.T) {
+	convey.Convey("TestChainService_SearchTransactionHash", t, func() {
+		ctx := context.Background()
+		chain := Chain("fake-tx-search")
+		var fixtures map[string]*TransactionData
+		loadFixture(t, "evm_transactions.json", &fixtures)
+		mined := fixtures["erc20_transfer"]
+		hash := "0x5c504ed432cb51138bcf09aa5e8a410dd4a1e204ef84bfed1be16dfba1b22060"
+
+		// the same transaction waiting in the mempool, before it gets a block and a receipt
+		pending := make(map[string]interface{})
+		for k, v := range mined.Tx.(map[string]interface{}) {
+			pending[k] = v
+		}
+		pending["blockHash"], pending["blockNumber"], pending["transactionIndex"] = nil, nil, nil
+
+		upstream := newFakeUpstream(t)
+		upstream.HandleEvmBlocks(18_000_000)
+		upstream.Result("eth_getTransactionByHash", pending)
+		upstream.Result("eth_getTransactionReceipt", nil)
+		svc := registerFakeChain(t, chain, newEvmAdapter(newTestChainInfo(t, Ethereum, upstream.URL), newTestClientPool(t)))
+		svc.chainRegistry = &chainRegistry{chains: map[Chain]ChainInfo{chain: {Family: FamilyEVM}}}
+		redisRepo := svc.redisRepo.(*fakeRedisRepo)
+		pendingKey, minedKey := "tx_pending:"+hash, "tx_hash:"+hash
+
+		res, err := svc.SearchTransactionHash(ctx, hash, nil, false, false)
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(res[chain].Status, convey.ShouldEqual, entity.TxStatusPending)
+
+		convey.Convey("Pending transaction is cached apart for PENDING_TX_CACHE_TTL", func() {
+			convey.So(redisRepo.Keys(), convey.ShouldResemble, []string{pendingKey})
+			ttl, _ := redisRepo.GetTTL(ctx, pendingKey)
+			convey.So(ttl, convey.ShouldEqual, conf.Config.PendingTxCacheTTL)
+
+			res, err := svc.SearchTransactionHash(ctx, hash, nil, false, false)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(res[chain].Status, convey.ShouldEqual, entity.TxStatusPending)
+			convey.So(upstream.Calls("eth_getTransactionReceipt"), convey.ShouldEqual, 1)
+		})
+
+		convey.Convey("Transaction mined after the pending cache expired is cached for an hour", func() {
+			upstream.Result("eth_getTransactionByHash", mined.Tx)
+			upstream.Result("eth_getTransactionReceipt", mined.Receipt)
+			_ = redisRepo.Invalidate(ctx, pendingKey)
+
+			res, err := svc.SearchTransactionHash(ctx, hash, nil, false, false)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(res[chain].Status, convey.ShouldEqual, entity.TxStatusSuccess)
+			convey.So(*res[chain].BlockNumber, convey.ShouldEqual, 18_000_000)
+			convey.So(redisRepo.Keys(), convey.ShouldResemble, []string{minedKey})
+			ttl, _ := redisRepo.GetTTL(ctx, minedKey)
+			convey.So(ttl, convey.ShouldEqual, time.Hour)
+
+			res, err = svc.SearchTransactionHash(ctx, hash, nil, false, false)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(res[chain].Status, convey.ShouldEqual, entity.TxStatusSuccess)
+			convey.So(upstream.Calls("eth_getTransactionReceipt"), convey.ShouldEqual, 2)
+		})
+
+		convey.Convey("Mined transaction cached wins over a pending one not expired yet", func() {
+			cached, err := redisRepo.HGet(ctx, pendingKey, string(chain))
+			convey.So(err, convey.ShouldBeNil)
+			var item *entity.Transaction
+			convey.So(json.Unmarshal([]byte(cached), &item), convey.ShouldBeNil)
+			item.Status = entity.TxStatusSuccess
+			_ = redisRepo.HSet(ctx, minedKey, map[string]interface{}{string(chain): fakeRedisValue(item)})
+
+			res, err := svc.SearchTransactionHash(ctx, hash, nil, false, false)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(res[chain].Status, convey.ShouldEqual, entity.TxStatusSuccess)
+			convey.So(upstream.Calls("eth_getTransactionReceipt"), convey.ShouldEqual, 1)
+		})
+	})
+}
+
+func TestChainService_SearchTransactionHashAcrossChains(t *testing.T) {
+	convey.Convey("TestChainService_SearchTransactionHashAcrossChains", t, func() {
+		ctx := context.Background()
+		var fixtures map[string]*TransactionData
+		loadFixture(t, "evm_transactions.json", &fixtures)
+		mined := fixtures["erc20_transfer"]
+		hash := "0x5c504ed432cb51138bcf09aa5e8a410dd4a1e204ef84bfed1be16dfba1b22060"
+
+		var (
+			found    = Chain("fake-search-a")
+			notFound = Chain("fake-search-b")
+			hanging  = Chain("fake-search-c")
+			release  = make(chan struct{})
+		)
+		upstreams := map[Chain]*fakeUpstream{found: newFakeUpstream(t), notFound: newFakeUpstream(t), hanging: newFakeUpstream(t)}
+		t.Cleanup(func() { close(release) })
+		upstreams[found].HandleEvmBlocks(18_000_000)
+		upstreams[found].Result("eth_getTransactionByHash", mined.Tx)
+		upstreams[found].Result("eth_getTransactionReceipt", mined.Receipt)
+		upstreams[notFound].Result("eth_getTransactionByHash", nil)
+		upstreams[notFound].Result("eth_getTransactionReceipt", nil)
+		// a node which answers once the search is over
+		upstreams[hanging].Handle("eth_getTransactionReceipt", func([]json.RawMessage) (interface{}, error) {
+			<-release
+			return nil, nil
+		})
+
+		var svc *chainService
+		chains := make(map[Chain]ChainInfo, len(upstreams))
+		for chain, upstream := range upstreams {
+			svc = registerFakeChain(t, chain, newEvmAdapter(newTestChainInfo(t, Ethereum, upstream.URL), newTestClientPool(t)))
+			chains[chain] = ChainInfo{Family: FamilyEVM}
+		}
+		svc.chainRegistry = &chainRegistry{chains: chains}
+		redisRepo := svc.redisRepo.(*fakeRedisRepo)
+		cacheKey := "tx_hash:" + hash
+
+		convey.Convey("Unknown chain of the hint is refused", func() {
+			_, err := svc.SearchTransactionHash(ctx, hash, []Chain{found, Chain("unknown")}, false, false)
+			convey.So(errors.Is(err, setting.ErrNotSupportedChain), convey.ShouldBeTrue)
+			convey.So(upstreams[found].Calls("eth_getTransactionReceipt"), convey.ShouldEqual, 0)
+		})
+
+		convey.Convey("Hint narrows the search to the given chains", func() {
+			res, err := svc.SearchTransactionHash(ctx, hash, []Chain{found, notFound}, false, false)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(res, convey.ShouldHaveLength, 1)
+			convey.So(res[found].Status, convey.ShouldEqual, entity.TxStatusSuccess)
+			convey.So(upstreams[notFound].Calls("eth_getTransactionReceipt"), convey.ShouldEqual, 1)
+			convey.So(upstreams[hanging].Calls("eth_getTransactionReceipt"), convey.ShouldEqual, 0)
+
+			// chains without the transaction are cached as null
+			cached, _ := redisRepo.HGetAll(ctx, cacheKey)
+			convey.So(cached[string(notFound)], convey.ShouldEqual, "null")
+			convey.So(cached, convey.ShouldHaveLength, 2)
+		})
+
+		convey.Convey("First match cancels the other lookups and leaves them uncached", func() {
+			res, err := svc.SearchTransactionHash(ctx, hash, []Chain{found, hanging}, true, false)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(res, convey.ShouldHaveLength, 1)
+			convey.So(res[found], convey.ShouldNotBeNil)
+
+			cached, _ := redisRepo.HGetAll(ctx, cacheKey)
+			convey.So(cached, convey.ShouldHaveLength, 1)
+			convey.So(cached, convey.ShouldContainKey, string(found))
+		})
+
+		convey.Convey("First match picks chains found together in the order of the hint, by name without it", func() {
+			var item *entity.Transaction
+			convey.So(json.Unmarshal([]byte(`{"hash":"`+hash+`","status":"success"}`), &item), convey.ShouldBeNil)
+			_ = redisRepo.HSet(ctx, cacheKey, map[string]interface{}{string(found): fakeRedisValue(item), string(notFound): fakeRedisValue(item)})
+
+			for i := 0; i < 20; i++ {
+				res, err := svc.SearchTransactionHash(ctx, hash, []Chain{notFound, found}, true, false)
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(res, convey.ShouldContainKey, notFound)
+
+				res, err = svc.SearchTransactionHash(ctx, hash, nil, true, false)
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(res, convey.ShouldContainKey, found)
+			}
+			convey.So(upstreams[found].Calls("eth_getTransactionReceipt"), convey.ShouldEqual, 0)
+		})
+	})
+}
@@ -7,5 +7,12 @@ import (
 
 var GraphSet = wire.NewSet(
 	u_http_client.NewHttpExecutor,
+	NewUpstreamHealth,
+	NewChainRegistry,
+	NewUpstreamMonitor,
 	NewChainService,
+	NewBlockStream,
+	NewReorgTracker,
+	NewChainStatsTracker,
+	NewAbiDecoder,
 )
@@ -0,0 +1,198 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+
+	"nimbus-enhance-api/internal/entity"
+	"nimbus-enhance-api/internal/infra"
+)
+
+// fakeAdapter serves blocks numbered from 0 to head, block n has hash "0x<n>" and timestamp genesisTime + n * blockTime.
+// After Reorg the blocks above the fork move to a new branch b and have hash "0x<n>-<b>", every block lists one
+// transaction "<block hash>:tx". Transactions and receipts are served from maps keyed by hash, missing ones answer nil.
+type fakeAdapter struct {
+	sync.Mutex
+	head        uint64
+	genesisTime int64
+	blockTime   int64
+	forks       []uint64 // fork number of every branch after the first one
+	lost        map[uint64]bool
+	txs         map[string]interface{}
+	receipts    map[string]interface{}
+
+	blockCalls int32 // GetBlock and GetBlockByNumber calls
+	headCalls  int32
+}
+
+func newFakeAdapter(head uint64) *fakeAdapter {
+	return &fakeAdapter{
+		head:        head,
+		genesisTime: 1_600_000_000,
+		blockTime:   12,
+		lost:        make(map[uint64]bool),
+		txs:         make(map[string]interface{}),
+		receipts:    make(map[string]interface{}),
+	}
+}
+
+// registerFakeChain serves chain with adapter through the chain service
+func registerFakeChain(t *testing.T, chain Chain, adapter ChainAdapter) *chainService {
+	RegisterChainAdapter(chain, func(ChainInfo, *infra.RpcClientPool) ChainAdapter {
+		return adapter
+	})
+	t.Cleanup(func() {
+		chainAdaptersMu.Lock()
+		delete(chainAdapters, chain)
+		chainAdaptersMu.Unlock()
+	})
+	return &chainService{
+		chainRegistry: &chainRegistry{chains: map[Chain]ChainInfo{chain: {}}},
+		redisRepo:     newFakeRedisRepo(),
+		blockRepo:     newFakeRedisRepo(),
+		abiDecoder:    &abiDecoder{contractAbiRepo: &fakeContractAbiRepo{}, functionSignatureRepo: &fakeFunctionSignatureRepo{}, redisRepo: newFakeRedisRepo()},
+	}
+}
+
+func (a *fakeAdapter) SetHead(head uint64) {
+	a.Lock()
+	defer a.Unlock()
+	a.head = head
+}
+
+// Reorg replaces the blocks above fork with a new branch
+func (a *fakeAdapter) Reorg(fork uint64) {
+	a.Lock()
+	defer a.Unlock()
+	a.forks = append(a.forks, fork)
+}
+
+// Lose answers number as not found, as an upstream which dropped an orphaned block and has not got the new one yet
+func (a *fakeAdapter) Lose(number uint64) {
+	a.Lock()
+	defer a.Unlock()
+	a.lost[number] = true
+}
+
+// branch is the latest branch holding number among the first branches
+func (a *fakeAdapter) branch(number uint64, branches int) int {
+	res := 0
+	for i, fork := range a.forks[:branches] {
+		if number > fork {
+			res = i + 1
+		}
+	}
+	return res
+}
+
+// block is the canonical block numbered number
+func (a *fakeAdapter) block(number uint64) *entity.Block {
+	return a.branchBlock(number, a.branch(number, len(a.forks)))
+}
+
+func (a *fakeAdapter) blockHash(number uint64, branch int) string {
+	if branch == 0 {
+		return fmt.Sprintf("0x%x", number)
+	}
+	return fmt.Sprintf("0x%x-%d", number, branch)
+}
+
+func (a *fakeAdapter) branchBlock(number uint64, branch int) *entity.Block {
+	res := &entity.Block{
+		Number:    number,
+		Hash:      a.blockHash(number, branch),
+		Timestamp: a.genesisTime + int64(number)*a.blockTime,
+	}
+	res.Raw = map[string]interface{}{"transactions": []interface{}{res.Hash + ":tx"}}
+	if number > 0 {
+		// the parent is on the same branch unless the branch forked right above it
+		parentBranch := branch
+		if branch > 0 && a.forks[branch-1] == number-1 {
+			parentBranch = a.branch(number-1, branch-1)
+		}
+		res.ParentHash = a.blockHash(number-1, parentBranch)
+	}
+	return res
+}
+
+func (a *fakeAdapter) IsTxHash(hash string) bool {
+	return true
+}
+
+func (a *fakeAdapter) GetChainID(ctx context.Context) (uint64, error) {
+	return 1, nil
+}
+
+func (a *fakeAdapter) GetLatestBlock(ctx context.Context) (*entity.Block, error) {
+	number, _ := a.GetLatestBlockNumber(ctx)
+	return a.GetBlockByNumber(ctx, number)
+}
+
+func (a *fakeAdapter) GetLatestBlockNumber(ctx context.Context) (uint64, error) {
+	atomic.AddInt32(&a.headCalls, 1)
+	a.Lock()
+	defer a.Unlock()
+	return a.head, nil
+}
+
+func (a *fakeAdapter) GetBlockByNumber(ctx context.Context, number uint64) (*entity.Block, error) {
+	atomic.AddInt32(&a.blockCalls, 1)
+	a.Lock()
+	defer a.Unlock()
+	if number > a.head || a.lost[number] {
+		return nil, ethereum.NotFound
+	}
+	return a.block(number), nil
+}
+
+func (a *fakeAdapter) GetBlock(ctx context.Context, id BlockID, includeTxs bool) (*entity.Block, error) {
+	switch {
+	case id.Number != nil:
+		return a.GetBlockByNumber(ctx, *id.Number)
+	case id.Hash != "":
+		// blocks of every branch are served by hash, as nodes keep the orphaned blocks they saw
+		var (
+			number uint64
+			branch int
+		)
+		numberHash, branchHash, _ := strings.Cut(id.Hash, "-")
+		if _, err := fmt.Sscanf(numberHash, "0x%x", &number); err != nil {
+			return nil, ethereum.NotFound
+		}
+		if branchHash != "" {
+			if _, err := fmt.Sscanf(branchHash, "%d", &branch); err != nil {
+				return nil, ethereum.NotFound
+			}
+		}
+		atomic.AddInt32(&a.blockCalls, 1)
+		a.Lock()
+		defer a.Unlock()
+		if number > a.head || branch > len(a.forks) {
+			return nil, ethereum.NotFound
+		}
+		return a.branchBlock(number, branch), nil
+	}
+	return a.GetLatestBlock(ctx)
+}
+
+func (a *fakeAdapter) GetTransactionByHash(ctx context.Context, hash string) (interface{}, error) {
+	a.Lock()
+	defer a.Unlock()
+	return a.txs[hash], nil
+}
+
+func (a *fakeAdapter) GetTransactionReceipt(ctx context.Context, hash string) (interface{}, error) {
+	a.Lock()
+	defer a.Unlock()
+	return a.receipts[hash], nil
+}
+
+func (a *fakeAdapter) BlockCalls() int {
+	return int(atomic.LoadInt32(&a.blockCalls))
+}
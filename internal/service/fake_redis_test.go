@@ -0,0 +1,190 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+
+	"nimbus-enhance-api/internal/repo"
+)
+
+// fakeRedisRepo keeps values in memory the way redisRepo stores them: values are json encoded,
+// misses answer redis.Nil. Expirations are recorded but not enforced, tests expire keys with Invalidate.
+type fakeRedisRepo struct {
+	sync.Mutex
+	values      map[string]string
+	hashes      map[string]map[string]string
+	expirations map[string]time.Duration
+}
+
+var _ repo.RedisRepo = (*fakeRedisRepo)(nil)
+
+func newFakeRedisRepo() *fakeRedisRepo {
+	return &fakeRedisRepo{
+		values:      make(map[string]string),
+		hashes:      make(map[string]map[string]string),
+		expirations: make(map[string]time.Duration),
+	}
+}
+
+func fakeRedisValue(value interface{}) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(data)
+}
+
+func (r *fakeRedisRepo) Set(ctx context.Context, key string, value interface{}) error {
+	r.Lock()
+	defer r.Unlock()
+	r.values[key] = fakeRedisValue(value)
+	return nil
+}
+
+func (r *fakeRedisRepo) Get(ctx context.Context, key string) (string, error) {
+	r.Lock()
+	defer r.Unlock()
+	value, ok := r.values[key]
+	if !ok {
+		return "", redis.Nil
+	}
+	return value, nil
+}
+
+func (r *fakeRedisRepo) MSet(ctx context.Context, values map[string]interface{}, bulkSize int) error {
+	for key, value := range values {
+		_ = r.Set(ctx, key, value)
+	}
+	return nil
+}
+
+func (r *fakeRedisRepo) MGet(ctx context.Context, keys []string) ([]interface{}, error) {
+	res := make([]interface{}, 0, len(keys))
+	for _, key := range keys {
+		value, err := r.Get(ctx, key)
+		if err != nil {
+			res = append(res, nil)
+			continue
+		}
+		res = append(res, value)
+	}
+	return res, nil
+}
+
+// HSet stores fields as given, as go-redis does with string values
+func (r *fakeRedisRepo) HSet(ctx context.Context, key string, values map[string]interface{}) error {
+	r.Lock()
+	defer r.Unlock()
+	hash, ok := r.hashes[key]
+	if !ok {
+		hash = make(map[string]string, len(values))
+		r.hashes[key] = hash
+	}
+	for field, value := range values {
+		hash[field] = fmt.Sprint(value)
+	}
+	return nil
+}
+
+func (r *fakeRedisRepo) HGet(ctx context.Context, key string, field string) (string, error) {
+	r.Lock()
+	defer r.Unlock()
+	value, ok := r.hashes[key][field]
+	if !ok {
+		return "", redis.Nil
+	}
+	return value, nil
+}
+
+func (r *fakeRedisRepo) HMSet(ctx context.Context, hashKey string, values map[string]interface{}, bulkSize int) error {
+	return r.HSet(ctx, hashKey, values)
+}
+
+func (r *fakeRedisRepo) HGetAll(ctx context.Context, key string) (map[string]string, error) {
+	r.Lock()
+	defer r.Unlock()
+	res := make(map[string]string, len(r.hashes[key]))
+	for field, value := range r.hashes[key] {
+		res[field] = value
+	}
+	return res, nil
+}
+
+func (r *fakeRedisRepo) Invalidate(ctx context.Context, key string) error {
+	r.Lock()
+	defer r.Unlock()
+	delete(r.values, key)
+	delete(r.hashes, key)
+	delete(r.expirations, key)
+	return nil
+}
+
+func (r *fakeRedisRepo) InvalidatePrefix(ctx context.Context, prefix string) error {
+	r.Lock()
+	defer r.Unlock()
+	for key := range r.values {
+		if strings.HasPrefix(key, prefix) {
+			delete(r.values, key)
+		}
+	}
+	for key := range r.hashes {
+		if strings.HasPrefix(key, prefix) {
+			delete(r.hashes, key)
+		}
+	}
+	return nil
+}
+
+func (r *fakeRedisRepo) FlushDB(ctx context.Context) error {
+	r.Lock()
+	defer r.Unlock()
+	r.values = make(map[string]string)
+	r.hashes = make(map[string]map[string]string)
+	r.expirations = make(map[string]time.Duration)
+	return nil
+}
+
+func (r *fakeRedisRepo) Expire(ctx context.Context, key string, duration time.Duration) error {
+	r.Lock()
+	defer r.Unlock()
+	r.expirations[key] = duration
+	return nil
+}
+
+func (r *fakeRedisRepo) GetTTL(ctx context.Context, key string) (time.Duration, error) {
+	r.Lock()
+	defer r.Unlock()
+	return r.expirations[key], nil
+}
+
+func (r *fakeRedisRepo) Exist(ctx context.Context, key string) bool {
+	r.Lock()
+	defer r.Unlock()
+	_, ok := r.values[key]
+	_, okHash := r.hashes[key]
+	return ok || okHash
+}
+
+func (r *fakeRedisRepo) Close(ctx context.Context) error {
+	return nil
+}
+
+// Keys lists the plain and hash keys, for assertions on what was cached
+func (r *fakeRedisRepo) Keys() []string {
+	r.Lock()
+	defer r.Unlock()
+	res := make([]string, 0, len(r.values)+len(r.hashes))
+	for key := range r.values {
+		res = append(res, key)
+	}
+	for key := range r.hashes {
+		res = append(res, key)
+	}
+	return res
+}
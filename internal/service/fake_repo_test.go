@@ -0,0 +1,92 @@
+package service
+
+import (
+	"context"
+	"sync"
+
+	"gorm.io/gorm"
+
+	"nimbus-enhance-api/internal/entity"
+	"nimbus-enhance-api/internal/repo"
+	"nimbus-enhance-api/internal/repo/gorm_scope"
+)
+
+// fakeFunctionSignatureRepo ignores scopes and answers every signature, tests store signatures of one selector
+type fakeFunctionSignatureRepo struct {
+	signatures []string
+}
+
+var _ repo.FunctionSignatureRepo = (*fakeFunctionSignatureRepo)(nil)
+
+func (r *fakeFunctionSignatureRepo) S() *gorm_scope.FunctionSignatureScope {
+	return gorm_scope.NewFunctionSignature(gorm_scope.NewBase())
+}
+
+func (r *fakeFunctionSignatureRepo) GetList(ctx context.Context, scopes ...func(db *gorm.DB) *gorm.DB) ([]*entity.FunctionSignature, error) {
+	res := make([]*entity.FunctionSignature, 0, len(r.signatures))
+	for _, signature := range r.signatures {
+		res = append(res, &entity.FunctionSignature{Signature: signature})
+	}
+	return res, nil
+}
+
+func (r *fakeFunctionSignatureRepo) CreateBatch(ctx context.Context, entities []*entity.FunctionSignature) error {
+	for _, item := range entities {
+		r.signatures = append(r.signatures, item.Signature)
+	}
+	return nil
+}
+
+// fakeContractAbiRepo ignores scopes and answers every stored abi, tests store abis of one chain
+type fakeContractAbiRepo struct {
+	sync.Mutex
+	abis    []*entity.ContractAbi
+	queries int
+	err     error
+}
+
+var _ repo.ContractAbiRepo = (*fakeContractAbiRepo)(nil)
+
+func (r *fakeContractAbiRepo) S() *gorm_scope.ContractAbiScope {
+	return gorm_scope.NewContractAbi(gorm_scope.NewBase())
+}
+
+func (r *fakeContractAbiRepo) GetOne(ctx context.Context, scopes ...func(db *gorm.DB) *gorm.DB) (*entity.ContractAbi, error) {
+	res, err := r.GetList(ctx, scopes...)
+	if err != nil {
+		return nil, err
+	}
+	if len(res) == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return res[0], nil
+}
+
+func (r *fakeContractAbiRepo) GetList(ctx context.Context, scopes ...func(db *gorm.DB) *gorm.DB) ([]*entity.ContractAbi, error) {
+	r.Lock()
+	defer r.Unlock()
+	r.queries++
+	if r.err != nil {
+		return nil, r.err
+	}
+	return append([]*entity.ContractAbi(nil), r.abis...), nil
+}
+
+func (r *fakeContractAbiRepo) Save(ctx context.Context, item *entity.ContractAbi) error {
+	r.Lock()
+	defer r.Unlock()
+	for i, stored := range r.abis {
+		if stored.Chain == item.Chain && stored.Address == item.Address {
+			r.abis[i] = item
+			return nil
+		}
+	}
+	r.abis = append(r.abis, item)
+	return nil
+}
+
+func (r *fakeContractAbiRepo) Queries() int {
+	r.Lock()
+	defer r.Unlock()
+	return r.queries
+}
@@ -0,0 +1,209 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+
+	"nimbus-enhance-api/internal/entity"
+	"nimbus-enhance-api/internal/infra"
+)
+
+// fakeRpcMethod answers the params of a json-rpc call, a non nil error is answered as json-rpc error
+type fakeRpcMethod func(params []json.RawMessage) (interface{}, error)
+
+// fakeUpstream is a json-rpc node answering registered methods, batches included.
+// Status other than 200 answers every request with that http status, as a failing node,
+// and batches larger than maxBatch are refused with 413 as most nodes do.
+type fakeUpstream struct {
+	*httptest.Server
+	sync.Mutex
+	methods  map[string]fakeRpcMethod
+	calls    map[string]int
+	status   int
+	maxBatch int
+	batches  []int
+}
+
+type fakeRpcRequest struct {
+	ID     json.RawMessage   `json:"id"`
+	Method string            `json:"method"`
+	Params []json.RawMessage `json:"params"`
+}
+
+func newFakeUpstream(t *testing.T) *fakeUpstream {
+	u := &fakeUpstream{
+		methods: make(map[string]fakeRpcMethod),
+		calls:   make(map[string]int),
+		status:  http.StatusOK,
+	}
+	u.Server = httptest.NewServer(http.HandlerFunc(u.serve))
+	t.Cleanup(u.Close)
+	return u
+}
+
+// Handle registers a method, replacing the one registered before
+func (u *fakeUpstream) Handle(method string, fn fakeRpcMethod) {
+	u.Lock()
+	defer u.Unlock()
+	u.methods[method] = fn
+}
+
+// Result registers a method always answering res
+func (u *fakeUpstream) Result(method string, res interface{}) {
+	u.Handle(method, func([]json.RawMessage) (interface{}, error) {
+		return res, nil
+	})
+}
+
+// HandleEvmBlocks serves eth_getBlockByNumber with blocks numbered from 0 to head, newer blocks answer null.
+// Block n has hash 0x<n> and timestamp 1_600_000_000 + 12 * n, as the blocks of fakeAdapter.
+func (u *fakeUpstream) HandleEvmBlocks(head uint64) {
+	u.Handle("eth_getBlockByNumber", func(params []json.RawMessage) (interface{}, error) {
+		var tag string
+		if len(params) > 0 {
+			_ = json.Unmarshal(params[0], &tag)
+		}
+		number := head
+		if tag != BlockTagLatest {
+			parsed, err := hexutil.DecodeUint64(tag)
+			if err != nil {
+				return nil, err
+			}
+			number = parsed
+		}
+		if number > head {
+			return nil, nil
+		}
+		return fakeEvmBlock(number), nil
+	})
+}
+
+func fakeEvmBlock(number uint64) map[string]interface{} {
+	res := map[string]interface{}{
+		"number":       hexutil.EncodeUint64(number),
+		"hash":         fmt.Sprintf("0x%x", number),
+		"parentHash":   "0x0",
+		"timestamp":    hexutil.EncodeUint64(1_600_000_000 + 12*number),
+		"miner":        "0x0000000000000000000000000000000000000000",
+		"gasUsed":      "0x0",
+		"gasLimit":     "0x1c9c380",
+		"transactions": []interface{}{},
+	}
+	if number > 0 {
+		res["parentHash"] = fmt.Sprintf("0x%x", number-1)
+	}
+	return res
+}
+
+func (u *fakeUpstream) SetStatus(status int) {
+	u.Lock()
+	defer u.Unlock()
+	u.status = status
+}
+
+func (u *fakeUpstream) SetMaxBatch(maxBatch int) {
+	u.Lock()
+	defer u.Unlock()
+	u.maxBatch = maxBatch
+}
+
+// Batches returns the size of every batch received
+func (u *fakeUpstream) Batches() []int {
+	u.Lock()
+	defer u.Unlock()
+	return append([]int(nil), u.batches...)
+}
+
+// Calls counts the calls of method, calls of a batch are counted one by one
+func (u *fakeUpstream) Calls(method string) int {
+	u.Lock()
+	defer u.Unlock()
+	return u.calls[method]
+}
+
+func (u *fakeUpstream) serve(w http.ResponseWriter, r *http.Request) {
+	u.Lock()
+	status, maxBatch := u.status, u.maxBatch
+	u.Unlock()
+	if status != http.StatusOK {
+		w.WriteHeader(status)
+		return
+	}
+
+	body, _ := io.ReadAll(r.Body)
+	w.Header().Set("Content-Type", "application/json")
+	var batch []fakeRpcRequest
+	if err := json.Unmarshal(body, &batch); err == nil {
+		u.Lock()
+		u.batches = append(u.batches, len(batch))
+		u.Unlock()
+		if maxBatch > 0 && len(batch) > maxBatch {
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+			return
+		}
+		res := make([]interface{}, 0, len(batch))
+		for _, req := range batch {
+			res = append(res, u.call(req))
+		}
+		_ = json.NewEncoder(w).Encode(res)
+		return
+	}
+	var req fakeRpcRequest
+	_ = json.Unmarshal(body, &req)
+	_ = json.NewEncoder(w).Encode(u.call(req))
+}
+
+func (u *fakeUpstream) call(req fakeRpcRequest) interface{} {
+	u.Lock()
+	fn, ok := u.methods[req.Method]
+	u.calls[req.Method]++
+	u.Unlock()
+
+	res := map[string]interface{}{"jsonrpc": "2.0", "id": req.ID}
+	if !ok {
+		res["error"] = map[string]interface{}{"code": -32601, "message": "the method " + req.Method + " does not exist"}
+		return res
+	}
+	result, err := fn(req.Params)
+	if err != nil {
+		res["error"] = map[string]interface{}{"code": -32000, "message": err.Error()}
+		return res
+	}
+	res["result"] = result
+	return res
+}
+
+// newTestClientPool is a client pool closed with the test
+func newTestClientPool(t *testing.T) *infra.RpcClientPool {
+	clientPool, cleanup, err := infra.NewRpcClientPool()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(cleanup)
+	return clientPool
+}
+
+// newTestChainInfo is a built-in chain served by the given endpoints with the same weight
+func newTestChainInfo(t *testing.T, chain Chain, endpoints ...string) ChainInfo {
+	chainInfo := defaultChainInfos[chain]
+	upstreams := make([]entity.ChainUpstream, 0, len(endpoints))
+	for _, endpoint := range endpoints {
+		upstreams = append(upstreams, entity.ChainUpstream{Endpoint: endpoint, Weight: 1})
+	}
+	chainInfo.Endpoint = endpoints[0]
+	chainInfo.Upstreams = upstreams
+
+	group, err := newUpstreamGroup(chain, upstreams, NewUpstreamHealth())
+	if err != nil {
+		t.Fatal(err)
+	}
+	chainInfo.upstreams = group
+	return chainInfo
+}
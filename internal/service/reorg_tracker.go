@@ -0,0 +1,315 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/tikivn/ultrago/u_logger"
+	"golang.org/x/sync/errgroup"
+
+	"nimbus-enhance-api/internal/conf"
+	"nimbus-enhance-api/internal/entity"
+	"nimbus-enhance-api/internal/infra"
+	"nimbus-enhance-api/internal/repo"
+	"nimbus-enhance-api/internal/repo/redis"
+	"nimbus-enhance-api/internal/setting"
+)
+
+// maxReorgEvents is the number of latest reorg events kept per chain
+const maxReorgEvents = 100
+
+func NewReorgTracker(
+	redisClient *infra.RedisClient,
+	chainRegistry ChainRegistry,
+	clientPool *infra.RpcClientPool,
+) ReorgTracker {
+	return &reorgTracker{
+		chainRegistry: chainRegistry,
+		clientPool:    clientPool,
+		reorgRepo:     redis.NewRedisRepo(redisClient, "chain_reorg", 0),
+		redisRepo:     redis.NewRedisRepo(redisClient, "chain", time.Minute),
+		blockRepo:     redis.NewRedisRepo(redisClient, "chain_block", 0),
+	}
+}
+
+// ReorgTracker keeps the hashes of the last conf REORG_TRACK_DEPTH blocks of every chain in Redis. A new block whose
+// parent hash is not the tracked hash before it means the chain reorganized: the tracked blocks are compared with
+// the canonical ones down to the fork, caches of the orphaned blocks and their transactions are invalidated
+// and the reorg is recorded.
+type ReorgTracker interface {
+	Track(ctx context.Context) error
+	GetReorgs(ctx context.Context, chain Chain, limit int) ([]*entity.Reorg, error)
+}
+
+type reorgTracker struct {
+	chainRegistry ChainRegistry
+	clientPool    *infra.RpcClientPool
+	reorgRepo     repo.RedisRepo // tracked blocks and reorg events without expiration
+	redisRepo     repo.RedisRepo // caches of chain service
+	blockRepo     repo.RedisRepo // immutable block caches of chain service
+}
+
+func (t *reorgTracker) Track(ctx context.Context) error {
+	ctx, logger := u_logger.GetLogger(ctx)
+
+	var eg errgroup.Group
+	eg.SetLimit(conf.Config.NumWorker)
+	for k, v := range t.chainRegistry.List() {
+		chain, chainInfo := k, v
+		if !newChainCapability(chain, chainInfo).Supports(OperationBlock) {
+			continue
+		}
+		eg.Go(func() error {
+			if err := t.trackChain(ctx, chain, chainInfo); err != nil {
+				logger.Errorf("failed to track blocks of chain %v: %v", chain, err)
+			}
+			return nil
+		})
+	}
+	return eg.Wait()
+}
+
+func (t *reorgTracker) GetReorgs(ctx context.Context, chain Chain, limit int) ([]*entity.Reorg, error) {
+	if _, ok := t.chainRegistry.Get(chain); !ok {
+		return nil, fmt.Errorf("%w: %s", setting.ErrNotSupportedChain, chain)
+	}
+	res, err := t.getReorgs(ctx, chain)
+	if err != nil {
+		return nil, err
+	}
+	if limit > 0 && len(res) > limit {
+		res = res[:limit]
+	}
+	return res, nil
+}
+
+// trackChain appends the blocks after the last tracked one, at most conf REORG_TRACK_DEPTH from the head.
+// Tracking starts over when the chain moved further since the last run.
+func (t *reorgTracker) trackChain(ctx context.Context, chain Chain, chainInfo ChainInfo) error {
+	adapter, err := newChainAdapter(chain, chainInfo, t.clientPool)
+	if err != nil {
+		return err
+	}
+	head, err := adapter.GetLatestBlockNumber(ctx)
+	if err != nil {
+		return err
+	}
+
+	var (
+		depth   = conf.Config.ReorgTrackDepth
+		tracked = t.getTracked(ctx, chain)
+		from    = uint64(0)
+	)
+	if depth == 0 {
+		depth = 1
+	}
+	if head >= depth {
+		from = head - depth + 1
+	}
+	if n := len(tracked); n > 0 {
+		if last := tracked[n-1].Number; last >= head {
+			return nil
+		} else if last >= from {
+			from = last + 1
+		} else {
+			tracked = nil
+		}
+	}
+
+	numbers := make([]uint64, 0, head-from+1)
+	for number := from; number <= head; number++ {
+		numbers = append(numbers, number)
+	}
+	blocks, err := fetchBlocksByNumber(ctx, adapter, numbers)
+	if err != nil {
+		return err
+	}
+
+	for _, block := range blocks {
+		if block == nil {
+			continue
+		}
+		// chains without parent hash, such as aptos, are only tracked
+		if n := len(tracked); n > 0 && block.ParentHash != "" && block.ParentHash != tracked[n-1].Hash {
+			if tracked, err = t.handleReorg(ctx, chain, adapter, tracked); err != nil {
+				return err
+			}
+		}
+		tracked = append(tracked, entity.ReorgBlock{Number: block.Number, Hash: block.Hash})
+	}
+	if n := uint64(len(tracked)); n > depth {
+		tracked = tracked[n-depth:]
+	}
+	return t.reorgRepo.Set(ctx, fmt.Sprintf("tracked:%s", string(chain)), tracked)
+}
+
+// handleReorg walks the tracked blocks back to the last one still canonical and returns the tracked blocks
+// with the orphaned ones replaced by the canonical blocks
+func (t *reorgTracker) handleReorg(ctx context.Context, chain Chain, adapter ChainAdapter, tracked []entity.ReorgBlock) ([]entity.ReorgBlock, error) {
+	ctx, logger := u_logger.GetLogger(ctx)
+	var (
+		orphaned  []entity.ReorgBlock
+		canonical []entity.ReorgBlock
+		fork      = len(tracked) - 1
+	)
+	for ; fork >= 0; fork-- {
+		block, err := adapter.GetBlockByNumber(ctx, tracked[fork].Number)
+		if errors.Is(err, ethereum.NotFound) {
+			orphaned = append(orphaned, tracked[fork])
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		if block.Hash == tracked[fork].Hash {
+			break
+		}
+		orphaned = append(orphaned, tracked[fork])
+		canonical = append(canonical, entity.ReorgBlock{Number: block.Number, Hash: block.Hash})
+	}
+	if len(orphaned) == 0 {
+		// the upstream answering the new block is behind or on another branch, the next run checks again
+		return tracked, nil
+	}
+	reverse(orphaned)
+	reverse(canonical)
+
+	event := &entity.Reorg{
+		Chain:          string(chain),
+		DetectedAt:     time.Now().Unix(),
+		Depth:          len(orphaned),
+		OrphanedBlocks: orphaned,
+		NewBlocks:      canonical,
+		OrphanedTxs:    t.getOrphanedTxs(ctx, adapter, orphaned),
+	}
+	if fork >= 0 {
+		event.ForkNumber = tracked[fork].Number
+	} else if orphaned[0].Number > 0 {
+		// deeper than the tracked blocks, the fork is at most the block before the first tracked one
+		event.ForkNumber = orphaned[0].Number - 1
+	}
+	logger.Warnf("detected reorg of chain %s with depth %d after block %d", chain, event.Depth, event.ForkNumber)
+
+	t.invalidate(ctx, chain, adapter, event)
+	if err := t.addReorg(ctx, chain, event); err != nil {
+		logger.Errorf("failed to record reorg of chain %s: %v", chain, err)
+	}
+	return append(tracked[:fork+1], canonical...), nil
+}
+
+// getOrphanedTxs looks up the orphaned blocks by hash, blocks the upstream no longer serves,
+// or chains without lookup by hash such as solana, leave their transactions out.
+func (t *reorgTracker) getOrphanedTxs(ctx context.Context, adapter ChainAdapter, orphaned []entity.ReorgBlock) []string {
+	ctx, logger := u_logger.GetLogger(ctx)
+	var res []string
+	for _, item := range orphaned {
+		block, err := adapter.GetBlock(ctx, BlockID{Hash: item.Hash}, false)
+		if err != nil {
+			logger.Warnf("failed to get orphaned block %s: %v", item.Hash, err)
+			continue
+		}
+		res = append(res, blockTxHashes(block)...)
+	}
+	return res
+}
+
+// invalidate drops every cache which may hold data of the orphaned blocks
+func (t *reorgTracker) invalidate(ctx context.Context, chain Chain, adapter ChainAdapter, event *entity.Reorg) {
+	ctx, logger := u_logger.GetLogger(ctx)
+	levels, _ := chainCommitments(adapter)
+	keys := make([]string, 0, len(levels)+2*len(event.OrphanedTxs))
+	for _, level := range levels {
+		keys = append(keys, fmt.Sprintf("latest_block:%s:%s", string(chain), level))
+	}
+	for _, hash := range event.OrphanedTxs {
+		keys = append(keys, fmt.Sprintf("tx_hash:%s", hash), fmt.Sprintf("tx_pending:%s", hash))
+	}
+	for _, key := range keys {
+		if err := t.redisRepo.Invalidate(ctx, key); err != nil {
+			logger.Warnf("failed to invalidate cache %s: %v", key, err)
+		}
+	}
+
+	keys = []string{fmt.Sprintf("block_time:%s", string(chain))}
+	for _, item := range event.OrphanedBlocks {
+		keys = append(keys,
+			fmt.Sprintf("block:%s:%d:%t", string(chain), item.Number, true),
+			fmt.Sprintf("block:%s:%d:%t", string(chain), item.Number, false),
+		)
+	}
+	for _, key := range keys {
+		if err := t.blockRepo.Invalidate(ctx, key); err != nil {
+			logger.Warnf("failed to invalidate cache %s: %v", key, err)
+		}
+	}
+}
+
+func (t *reorgTracker) getTracked(ctx context.Context, chain Chain) []entity.ReorgBlock {
+	data, err := t.reorgRepo.Get(ctx, fmt.Sprintf("tracked:%s", string(chain)))
+	if err != nil {
+		return nil
+	}
+	var res []entity.ReorgBlock
+	_ = json.Unmarshal([]byte(data), &res)
+	return res
+}
+
+// getReorgs returns the recorded reorgs of chain, latest first
+func (t *reorgTracker) getReorgs(ctx context.Context, chain Chain) ([]*entity.Reorg, error) {
+	data, err := t.reorgRepo.Get(ctx, fmt.Sprintf("events:%s", string(chain)))
+	if err != nil {
+		return []*entity.Reorg{}, nil
+	}
+	var res []*entity.Reorg
+	if err := json.Unmarshal([]byte(data), &res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (t *reorgTracker) addReorg(ctx context.Context, chain Chain, event *entity.Reorg) error {
+	events, err := t.getReorgs(ctx, chain)
+	if err != nil {
+		return err
+	}
+	events = append([]*entity.Reorg{event}, events...)
+	if len(events) > maxReorgEvents {
+		events = events[:maxReorgEvents]
+	}
+	return t.reorgRepo.Set(ctx, fmt.Sprintf("events:%s", string(chain)), events)
+}
+
+// blockTxHashes reads the transaction hashes listed by the upstream block,
+// as hashes (evm, utxo, sui) or as transactions carrying their hash (tron).
+func blockTxHashes(block *entity.Block) []string {
+	raw, _ := block.Raw.(map[string]interface{})
+	items, ok := raw["transactions"].([]interface{})
+	if !ok {
+		items, _ = raw["tx"].([]interface{})
+	}
+
+	res := make([]string, 0, len(items))
+	for _, item := range items {
+		switch tx := item.(type) {
+		case string:
+			res = append(res, tx)
+		case map[string]interface{}:
+			for _, key := range []string{"hash", "txID", "txid", "digest"} {
+				if hash, ok := tx[key].(string); ok {
+					res = append(res, hash)
+					break
+				}
+			}
+		}
+	}
+	return res
+}
+
+func reverse(items []entity.ReorgBlock) {
+	for i, j := 0, len(items)-1; i < j; i, j = i+1, j-1 {
+		items[i], items[j] = items[j], items[i]
+	}
+}
@@ -0,0 +1,134 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+
+	"nimbus-enhance-api/internal/conf"
+	"nimbus-enhance-api/internal/entity"
+	"nimbus-enhance-api/internal/setting"
+)
+
+func TestReorgTracker_Track(t *testing.T) {
+	convey.Convey("TestReorgTracker_Track", t, func() {
+		ctx := context.Background()
+		chain := Chain("fake-reorg")
+		depth := conf.Config.ReorgTrackDepth
+		conf.Config.ReorgTrackDepth = 10
+		defer func() { conf.Config.ReorgTrackDepth = depth }()
+
+		adapter := newFakeAdapter(100)
+		registerFakeChain(t, chain, adapter)
+		var (
+			redisRepo = newFakeRedisRepo()
+			blockRepo = newFakeRedisRepo()
+			tracker   = &reorgTracker{
+				chainRegistry: &chainRegistry{chains: map[Chain]ChainInfo{chain: {Family: FamilyEVM}}},
+				reorgRepo:     newFakeRedisRepo(),
+				redisRepo:     redisRepo,
+				blockRepo:     blockRepo,
+			}
+		)
+		tracked := func() []entity.ReorgBlock {
+			return tracker.getTracked(ctx, chain)
+		}
+		reorgs := func() []*entity.Reorg {
+			res, err := tracker.GetReorgs(ctx, chain, 0)
+			convey.So(err, convey.ShouldBeNil)
+			for _, item := range res {
+				item.DetectedAt = 0
+			}
+			return res
+		}
+		keys := func(repo *fakeRedisRepo) []string {
+			res := repo.Keys()
+			sort.Strings(res)
+			return res
+		}
+
+		convey.So(tracker.Track(ctx), convey.ShouldBeNil)
+		convey.So(tracked(), convey.ShouldHaveLength, 10)
+		convey.So(tracked()[0], convey.ShouldResemble, entity.ReorgBlock{Number: 91, Hash: "0x5b"})
+		convey.So(reorgs(), convey.ShouldBeEmpty)
+
+		// caches of blocks 98 to 100 and of their transactions
+		for _, key := range []string{"latest_block:fake-reorg:latest", "tx_hash:0x62:tx", "tx_hash:0x63:tx", "tx_hash:0x64:tx", "tx_pending:0x64:tx"} {
+			_ = redisRepo.Set(ctx, key, "cached")
+		}
+		for _, key := range []string{"block_time:fake-reorg", "block:fake-reorg:98:false", "block:fake-reorg:99:true", "block:fake-reorg:100:false"} {
+			_ = blockRepo.Set(ctx, key, "cached")
+		}
+
+		convey.Convey("Blocks extending the chain are appended within the depth", func() {
+			adapter.SetHead(103)
+			convey.So(tracker.Track(ctx), convey.ShouldBeNil)
+			convey.So(tracked(), convey.ShouldHaveLength, 10)
+			convey.So(tracked()[9], convey.ShouldResemble, entity.ReorgBlock{Number: 103, Hash: "0x67"})
+			convey.So(reorgs(), convey.ShouldBeEmpty)
+			convey.So(redisRepo.Keys(), convey.ShouldHaveLength, 5)
+		})
+
+		convey.Convey("Parent hash mismatch walks back to the fork and invalidates the orphaned caches", func() {
+			adapter.Reorg(98)
+			adapter.SetHead(102)
+			convey.So(tracker.Track(ctx), convey.ShouldBeNil)
+
+			convey.So(reorgs(), convey.ShouldResemble, []*entity.Reorg{{
+				Chain:          string(chain),
+				Depth:          2,
+				ForkNumber:     98,
+				OrphanedBlocks: []entity.ReorgBlock{{Number: 99, Hash: "0x63"}, {Number: 100, Hash: "0x64"}},
+				NewBlocks:      []entity.ReorgBlock{{Number: 99, Hash: "0x63-1"}, {Number: 100, Hash: "0x64-1"}},
+				OrphanedTxs:    []string{"0x63:tx", "0x64:tx"},
+			}})
+			convey.So(tracked()[7:], convey.ShouldResemble, []entity.ReorgBlock{
+				{Number: 100, Hash: "0x64-1"}, {Number: 101, Hash: "0x65-1"}, {Number: 102, Hash: "0x66-1"},
+			})
+			convey.So(keys(redisRepo), convey.ShouldResemble, []string{"tx_hash:0x62:tx"})
+			convey.So(keys(blockRepo), convey.ShouldResemble, []string{"block:fake-reorg:98:false"})
+
+			convey.Convey("Tracked block the upstream no longer serves is orphaned", func() {
+				adapter.Reorg(101)
+				adapter.SetHead(103)
+				adapter.Lose(102)
+				convey.So(tracker.Track(ctx), convey.ShouldBeNil)
+
+				res := reorgs()
+				convey.So(res, convey.ShouldHaveLength, 2)
+				convey.So(res[0], convey.ShouldResemble, &entity.Reorg{
+					Chain:          string(chain),
+					Depth:          1,
+					ForkNumber:     101,
+					OrphanedBlocks: []entity.ReorgBlock{{Number: 102, Hash: "0x66-1"}},
+					OrphanedTxs:    []string{"0x66-1:tx"},
+				})
+				convey.So(tracked()[8:], convey.ShouldResemble, []entity.ReorgBlock{
+					{Number: 101, Hash: "0x65-1"}, {Number: 103, Hash: "0x67-2"},
+				})
+			})
+		})
+
+		convey.Convey("Only the latest events are kept", func() {
+			for i := 0; i <= maxReorgEvents; i++ {
+				convey.So(tracker.addReorg(ctx, chain, &entity.Reorg{Chain: string(chain), ForkNumber: uint64(i)}), convey.ShouldBeNil)
+			}
+			res := reorgs()
+			convey.So(res, convey.ShouldHaveLength, maxReorgEvents)
+			convey.So(res[0].ForkNumber, convey.ShouldEqual, maxReorgEvents)
+			convey.So(res[maxReorgEvents-1].ForkNumber, convey.ShouldEqual, 1)
+
+			res, err := tracker.GetReorgs(ctx, chain, 5)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(res, convey.ShouldHaveLength, 5)
+		})
+
+		convey.Convey("Unknown chain is refused", func() {
+			_, err := tracker.GetReorgs(ctx, Chain("unknown"), 0)
+			convey.So(errors.Is(err, setting.ErrNotSupportedChain), convey.ShouldBeTrue)
+		})
+	})
+}
@@ -0,0 +1,181 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/hedzr/lb/lbapi"
+	"github.com/tikivn/ultrago/u_logger"
+
+	"nimbus-enhance-api/internal/conf"
+	"nimbus-enhance-api/internal/entity"
+	"nimbus-enhance-api/internal/setting"
+	"nimbus-enhance-api/pkg/load_balancer"
+)
+
+// upstreamCooldown is how long a failed upstream is moved to the end of the failover order
+const upstreamCooldown = 30 * time.Second
+
+func newUpstreamGroup(chain Chain, upstreams []entity.ChainUpstream, health *UpstreamHealth) (*upstreamGroup, error) {
+	nodes := make([]*load_balancer.Node, 0, len(upstreams))
+	for _, upstream := range upstreams {
+		nodes = append(nodes, &load_balancer.Node{
+			Addr:     upstream.Endpoint,
+			Weighted: upstream.Weight,
+		})
+	}
+	balancer, err := load_balancer.NewWeightedRoundRobinBalancer(nodes)
+	if err != nil {
+		return nil, err
+	}
+
+	// failover order after the balanced pick is by weight
+	sort.SliceStable(nodes, func(i, j int) bool {
+		return nodes[i].Weighted > nodes[j].Weighted
+	})
+	return &upstreamGroup{
+		chain:    chain,
+		nodes:    nodes,
+		balancer: balancer,
+		health:   health,
+	}, nil
+}
+
+// upstreamGroup spreads calls of a chain over its weighted upstreams
+// and retries on the next healthy one when an upstream is unreachable or answers 5xx.
+type upstreamGroup struct {
+	chain    Chain
+	nodes    []*load_balancer.Node
+	balancer lbapi.Balancer
+	health   *UpstreamHealth
+}
+
+// Do returns the error with api keys of endpoints redacted, the cause is kept for errors.Is and errors.As.
+func (g *upstreamGroup) Do(ctx context.Context, fn func(endpoint string) error) (err error) {
+	ctx, logger := u_logger.GetLogger(ctx)
+	for _, endpoint := range g.candidates() {
+		err = fn(endpoint)
+		if err == nil {
+			g.health.markHealthy(endpoint)
+			return nil
+		}
+		if ctx.Err() != nil || !isRetryableError(err) {
+			return redactError(err)
+		}
+
+		g.health.markUnhealthy(endpoint)
+		logger.Warnf("upstream of chain %v failed, try next upstream: %v", g.chain, err)
+	}
+	return redactError(err)
+}
+
+// candidates puts the balanced pick first, then the remaining upstreams with unhealthy ones at the end.
+func (g *upstreamGroup) candidates() []string {
+	res := make([]string, 0, len(g.nodes))
+	first, _ := g.balancer.Next(lbapi.DummyFactor)
+	if first != nil {
+		res = append(res, first.String())
+	}
+	for _, node := range g.nodes {
+		if first != nil && node.Addr == first.String() {
+			continue
+		}
+		res = append(res, node.Addr)
+	}
+
+	sort.SliceStable(res, func(i, j int) bool {
+		return g.health.isHealthy(res[i]) && !g.health.isHealthy(res[j])
+	})
+	return res
+}
+
+func redactError(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &redactedError{err: err}
+}
+
+// redactedError hides api keys in the message of errors which may include an endpoint, such as *url.Error
+type redactedError struct {
+	err error
+}
+
+func (e *redactedError) Error() string {
+	return conf.Redact(e.err.Error())
+}
+
+func (e *redactedError) Unwrap() error {
+	return e.err
+}
+
+func NewUpstreamHealth() *UpstreamHealth {
+	return &UpstreamHealth{
+		unhealthyUntil: make(map[string]time.Time),
+	}
+}
+
+// UpstreamHealth is shared by every upstream group, so health survives chain reloads.
+type UpstreamHealth struct {
+	sync.RWMutex
+	unhealthyUntil map[string]time.Time
+}
+
+func (h *UpstreamHealth) isHealthy(endpoint string) bool {
+	h.RLock()
+	defer h.RUnlock()
+	until, ok := h.unhealthyUntil[endpoint]
+	return !ok || time.Now().After(until)
+}
+
+func (h *UpstreamHealth) markHealthy(endpoint string) {
+	h.Lock()
+	defer h.Unlock()
+	delete(h.unhealthyUntil, endpoint)
+}
+
+func (h *UpstreamHealth) markUnhealthy(endpoint string) {
+	h.Lock()
+	defer h.Unlock()
+	h.unhealthyUntil[endpoint] = time.Now().Add(upstreamCooldown)
+}
+
+// solana sdk flattens transport errors into its message
+var retryableMessageRegex = regexp.MustCompile(`failed to do request|get status code: (5\d\d|429)`)
+
+// isRetryableError reports connection failures and 5xx answers, json-rpc errors are returned as they are.
+func isRetryableError(err error) bool {
+	var (
+		netErr    net.Error
+		urlErr    *url.Error
+		httpErr   rpc.HTTPError
+		syntaxErr *json.SyntaxError
+	)
+	switch {
+	case err == nil, errors.Is(err, context.Canceled):
+		return false
+	case errors.Is(err, setting.ErrClientConnectionFailure),
+		errors.Is(err, io.EOF),
+		errors.Is(err, io.ErrUnexpectedEOF),
+		errors.As(err, &netErr),
+		errors.As(err, &urlErr):
+		return true
+	case errors.As(err, &httpErr):
+		return httpErr.StatusCode >= http.StatusInternalServerError ||
+			httpErr.StatusCode == http.StatusTooManyRequests
+	case errors.As(err, &syntaxErr):
+		// gateways in front of nodes answer 5xx with html body
+		return true
+	}
+	return retryableMessageRegex.MatchString(err.Error())
+}
@@ -0,0 +1,230 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tikivn/ultrago/u_logger"
+	"golang.org/x/sync/errgroup"
+
+	"nimbus-enhance-api/internal/conf"
+	"nimbus-enhance-api/internal/infra"
+)
+
+// probeWindow is the number of recent probes used to compute error rate of an upstream
+const probeWindow = 20
+
+func NewUpstreamMonitor(
+	chainRegistry ChainRegistry,
+	health *UpstreamHealth,
+	clientPool *infra.RpcClientPool,
+) UpstreamMonitor {
+	return &upstreamMonitor{
+		chainRegistry: chainRegistry,
+		health:        health,
+		clientPool:    clientPool,
+		statuses:      make(map[string]*upstreamProbes),
+	}
+}
+
+// UpstreamMonitor probes the latest block of every upstream in background,
+// so slow, lagging or down upstreams are visible and skipped by failover.
+type UpstreamMonitor interface {
+	Probe(ctx context.Context) error
+	GetStatuses() map[Chain][]*UpstreamStatus
+	Check(ctx context.Context) error
+}
+
+type UpstreamStatus struct {
+	Endpoint     string  `json:"endpoint"`
+	Healthy      bool    `json:"healthy"`
+	LatencyMs    int64   `json:"latency_ms"`
+	AvgLatencyMs int64   `json:"avg_latency_ms"`
+	ErrorRate    float64 `json:"error_rate"`
+	HeadNumber   uint64  `json:"head_number"`
+	HeadLag      uint64  `json:"head_lag"`
+	LastError    string  `json:"last_error,omitempty"`
+	CheckedAt    int64   `json:"checked_at"`
+}
+
+type upstreamMonitor struct {
+	sync.RWMutex
+	chainRegistry ChainRegistry
+	health        *UpstreamHealth
+	clientPool    *infra.RpcClientPool
+	statuses      map[string]*upstreamProbes // key is chain:endpoint
+	chains        map[Chain][]string
+}
+
+type upstreamProbes struct {
+	results []probeResult
+	status  UpstreamStatus
+}
+
+type probeResult struct {
+	latency time.Duration
+	head    uint64
+	err     error
+}
+
+func (m *upstreamMonitor) Probe(ctx context.Context) error {
+	ctx, logger := u_logger.GetLogger(ctx)
+
+	var (
+		mu      sync.Mutex
+		results = make(map[Chain]map[string]probeResult)
+		eg      errgroup.Group
+	)
+	for k, v := range m.chainRegistry.List() {
+		chain := k
+		chainInfo := v
+		results[chain] = make(map[string]probeResult)
+		for _, upstream := range chainInfo.toEntity(chain).GetUpstreams() {
+			endpoint := upstream.Endpoint
+			eg.Go(func() error {
+				res := m.probe(ctx, chain, chainInfo, endpoint)
+				if res.err != nil {
+					logger.Warnf("failed to probe upstream of chain %v: %v", chain, res.err)
+				}
+				mu.Lock()
+				results[chain][endpoint] = res
+				mu.Unlock()
+				return nil
+			})
+		}
+	}
+	_ = eg.Wait()
+
+	m.record(results)
+	return nil
+}
+
+// probe calls the latest block of one upstream only, without failover to the others.
+func (m *upstreamMonitor) probe(ctx context.Context, chain Chain, chainInfo ChainInfo, endpoint string) probeResult {
+	ctx, cancel := context.WithTimeout(ctx, conf.Config.UpstreamProbeTimeout)
+	defer cancel()
+
+	adapter, err := newUpstreamAdapter(chain, chainInfo, endpoint, m.clientPool)
+	if err != nil {
+		return probeResult{err: err}
+	}
+
+	start := time.Now()
+	head, err := adapter.GetLatestBlockNumber(ctx)
+	return probeResult{
+		latency: time.Since(start),
+		head:    head,
+		err:     err,
+	}
+}
+
+// record computes head lag against the highest head of the same chain and updates upstream health for failover.
+func (m *upstreamMonitor) record(results map[Chain]map[string]probeResult) {
+	m.Lock()
+	defer m.Unlock()
+
+	var (
+		now      = time.Now()
+		statuses = make(map[string]*upstreamProbes, len(m.statuses))
+		chains   = make(map[Chain][]string, len(results))
+	)
+	for chain, probes := range results {
+		var maxHead uint64
+		for _, res := range probes {
+			if res.err == nil && res.head > maxHead {
+				maxHead = res.head
+			}
+		}
+
+		for endpoint, res := range probes {
+			key := fmt.Sprintf("%s:%s", chain, endpoint)
+			item, ok := m.statuses[key]
+			if !ok {
+				item = &upstreamProbes{}
+			}
+			item.results = append(item.results, res)
+			if len(item.results) > probeWindow {
+				item.results = item.results[len(item.results)-probeWindow:]
+			}
+
+			var (
+				failures     int
+				totalLatency time.Duration
+			)
+			for _, r := range item.results {
+				if r.err != nil {
+					failures++
+				}
+				totalLatency += r.latency
+			}
+
+			status := UpstreamStatus{
+				Endpoint:     conf.Redact(endpoint),
+				LatencyMs:    res.latency.Milliseconds(),
+				AvgLatencyMs: (totalLatency / time.Duration(len(item.results))).Milliseconds(),
+				ErrorRate:    float64(failures) / float64(len(item.results)),
+				HeadNumber:   res.head,
+				CheckedAt:    now.Unix(),
+			}
+			if res.err != nil {
+				status.LastError = res.err.Error()
+			} else {
+				status.HeadLag = maxHead - res.head
+				status.Healthy = status.HeadLag <= conf.Config.UpstreamMaxHeadLag
+			}
+			item.status = status
+
+			if status.Healthy {
+				m.health.markHealthy(endpoint)
+			} else {
+				m.health.markUnhealthy(endpoint)
+			}
+			statuses[key] = item
+			chains[chain] = append(chains[chain], key)
+		}
+	}
+	m.statuses = statuses
+	m.chains = chains
+}
+
+func (m *upstreamMonitor) GetStatuses() map[Chain][]*UpstreamStatus {
+	m.RLock()
+	defer m.RUnlock()
+
+	res := make(map[Chain][]*UpstreamStatus, len(m.chains))
+	for chain, keys := range m.chains {
+		items := make([]*UpstreamStatus, 0, len(keys))
+		for _, key := range keys {
+			status := m.statuses[key].status
+			items = append(items, &status)
+		}
+		sort.Slice(items, func(i, j int) bool {
+			return items[i].Endpoint < items[j].Endpoint
+		})
+		res[chain] = items
+	}
+	return res
+}
+
+// Check fails when any chain has no healthy upstream in the last probe.
+func (m *upstreamMonitor) Check(ctx context.Context) error {
+	var downChains []string
+	for chain, statuses := range m.GetStatuses() {
+		healthy := false
+		for _, status := range statuses {
+			healthy = healthy || status.Healthy
+		}
+		if !healthy {
+			downChains = append(downChains, string(chain))
+		}
+	}
+	if len(downChains) > 0 {
+		sort.Strings(downChains)
+		return fmt.Errorf("no healthy upstream of chains: %s", strings.Join(downChains, ","))
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestUpstreamMonitor_Probe(t *testing.T) {
+	convey.Convey("TestUpstreamMonitor_Probe", t, func() {
+		ctx := context.Background()
+		leading, behind, lagging := newFakeUpstream(t), newFakeUpstream(t), newFakeUpstream(t)
+		leading.HandleEvmBlocks(1000)
+		behind.HandleEvmBlocks(990)
+		lagging.HandleEvmBlocks(900)
+		chainInfo := newTestChainInfo(t, Ethereum, leading.URL, behind.URL, lagging.URL)
+		health := chainInfo.upstreams.health
+
+		m := NewUpstreamMonitor(&chainRegistry{chains: map[Chain]ChainInfo{Ethereum: chainInfo}}, health, newTestClientPool(t)).(*upstreamMonitor)
+		statusOf := func(endpoint string) *UpstreamStatus {
+			for _, status := range m.GetStatuses()[Ethereum] {
+				if status.Endpoint == endpoint {
+					return status
+				}
+			}
+			return nil
+		}
+
+		convey.Convey("Head lag is counted from the highest head of the chain", func() {
+			convey.So(m.Probe(ctx), convey.ShouldBeNil)
+			convey.So(m.GetStatuses()[Ethereum], convey.ShouldHaveLength, 3)
+
+			convey.So(statusOf(leading.URL).HeadNumber, convey.ShouldEqual, 1000)
+			convey.So(statusOf(leading.URL).HeadLag, convey.ShouldEqual, 0)
+			convey.So(statusOf(leading.URL).Healthy, convey.ShouldBeTrue)
+			convey.So(statusOf(behind.URL).HeadLag, convey.ShouldEqual, 10)
+			convey.So(statusOf(behind.URL).Healthy, convey.ShouldBeTrue)
+			convey.So(statusOf(lagging.URL).HeadLag, convey.ShouldEqual, 100)
+			convey.So(statusOf(lagging.URL).Healthy, convey.ShouldBeFalse)
+
+			// lagging upstreams are tried last on failover
+			convey.So(health.isHealthy(behind.URL), convey.ShouldBeTrue)
+			convey.So(health.isHealthy(lagging.URL), convey.ShouldBeFalse)
+			convey.So(m.Check(ctx), convey.ShouldBeNil)
+		})
+
+		convey.Convey("Failed probes count in the error rate and are left out of the highest head", func() {
+			convey.So(m.Probe(ctx), convey.ShouldBeNil)
+			leading.SetStatus(http.StatusBadGateway)
+			convey.So(m.Probe(ctx), convey.ShouldBeNil)
+
+			convey.So(statusOf(leading.URL).Healthy, convey.ShouldBeFalse)
+			convey.So(statusOf(leading.URL).LastError, convey.ShouldNotBeEmpty)
+			convey.So(statusOf(leading.URL).ErrorRate, convey.ShouldEqual, 0.5)
+			convey.So(statusOf(behind.URL).HeadLag, convey.ShouldEqual, 0)
+			convey.So(statusOf(lagging.URL).HeadLag, convey.ShouldEqual, 90)
+			convey.So(health.isHealthy(leading.URL), convey.ShouldBeFalse)
+		})
+
+		convey.Convey("Check fails when no upstream of a chain is healthy", func() {
+			for _, upstream := range []*fakeUpstream{leading, behind, lagging} {
+				upstream.SetStatus(http.StatusServiceUnavailable)
+			}
+			convey.So(m.Probe(ctx), convey.ShouldBeNil)
+			convey.So(m.Check(ctx), convey.ShouldNotBeNil)
+		})
+	})
+}
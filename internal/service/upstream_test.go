@@ -0,0 +1,101 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/smartystreets/goconvey/convey"
+
+	"nimbus-enhance-api/internal/setting"
+)
+
+func TestUpstreamGroup_Do(t *testing.T) {
+	convey.Convey("TestUpstreamGroup_Do", t, func() {
+		ctx := context.Background()
+		failing, healthy := newFakeUpstream(t), newFakeUpstream(t)
+		for _, upstream := range []*fakeUpstream{failing, healthy} {
+			upstream.Result("eth_chainId", "0x1")
+		}
+		chainInfo := newTestChainInfo(t, Ethereum, failing.URL, healthy.URL)
+		group := chainInfo.upstreams
+		adapter := newEvmAdapter(chainInfo, newTestClientPool(t))
+
+		convey.Convey("Calls fail over to the next upstream and the failed one cools down", func() {
+			failing.SetStatus(http.StatusBadGateway)
+			for i := 0; i < 4; i++ {
+				chainID, err := adapter.GetChainID(ctx)
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(chainID, convey.ShouldEqual, 1)
+			}
+			convey.So(healthy.Calls("eth_chainId"), convey.ShouldEqual, 4)
+			convey.So(group.health.isHealthy(failing.URL), convey.ShouldBeFalse)
+			// the unhealthy upstream is tried last whatever the balancer picks
+			for i := 0; i < 4; i++ {
+				convey.So(group.candidates(), convey.ShouldResemble, []string{healthy.URL, failing.URL})
+			}
+
+			convey.Convey("Upstream is tried again after the cooldown", func() {
+				failing.SetStatus(http.StatusOK)
+				group.health.unhealthyUntil[failing.URL] = time.Now().Add(-time.Second)
+				for i := 0; i < 4; i++ {
+					_, err := adapter.GetChainID(ctx)
+					convey.So(err, convey.ShouldBeNil)
+				}
+				convey.So(failing.Calls("eth_chainId"), convey.ShouldEqual, 2)
+				convey.So(group.health.isHealthy(failing.URL), convey.ShouldBeTrue)
+			})
+		})
+
+		convey.Convey("Json-rpc errors are answered without failover", func() {
+			for _, upstream := range []*fakeUpstream{failing, healthy} {
+				upstream.Handle("eth_chainId", func(params []json.RawMessage) (interface{}, error) {
+					return nil, errors.New("method not allowed")
+				})
+			}
+			_, err := adapter.GetChainID(ctx)
+			convey.So(err, convey.ShouldNotBeNil)
+			convey.So(failing.Calls("eth_chainId")+healthy.Calls("eth_chainId"), convey.ShouldEqual, 1)
+			convey.So(group.health.isHealthy(failing.URL), convey.ShouldBeTrue)
+			convey.So(group.health.isHealthy(healthy.URL), convey.ShouldBeTrue)
+		})
+
+		convey.Convey("Last error is answered when every upstream fails", func() {
+			failing.SetStatus(http.StatusBadGateway)
+			healthy.SetStatus(http.StatusServiceUnavailable)
+			_, err := adapter.GetChainID(ctx)
+			var httpErr rpc.HTTPError
+			convey.So(errors.As(err, &httpErr), convey.ShouldBeTrue)
+			convey.So(group.health.isHealthy(failing.URL), convey.ShouldBeFalse)
+			convey.So(group.health.isHealthy(healthy.URL), convey.ShouldBeFalse)
+		})
+	})
+}
+
+func TestIsRetryableError(t *testing.T) {
+	convey.Convey("TestIsRetryableError", t, func() {
+		cases := []struct {
+			err  error
+			want bool
+		}{
+			{err: nil, want: false},
+			{err: context.Canceled, want: false},
+			{err: fmt.Errorf("dial: %w", setting.ErrClientConnectionFailure), want: true},
+			{err: io.ErrUnexpectedEOF, want: true},
+			{err: rpc.HTTPError{StatusCode: http.StatusBadGateway}, want: true},
+			{err: rpc.HTTPError{StatusCode: http.StatusTooManyRequests}, want: true},
+			{err: rpc.HTTPError{StatusCode: http.StatusBadRequest}, want: false},
+			{err: errors.New("rpc call getSlot() on https://node: get status code: 503"), want: true},
+			{err: errors.New("execution reverted"), want: false},
+		}
+		for _, c := range cases {
+			convey.So(isRetryableError(c.err), convey.ShouldEqual, c.want)
+		}
+	})
+}
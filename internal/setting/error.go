@@ -13,6 +13,18 @@ var (
 	// chain service
 	ErrClientConnectionFailure error
 	ErrNotSupportedChain       error
+	ErrChainAlreadyExisted     error
+	ErrInvalidChain            error
+	ErrNotSupportedMethod      error
+	ErrUnexpectedChainID       error
+	ErrNotSupportedOperation   error
+	ErrInvalidBlockRange       error
+	ErrStreamClosed            error
+	ErrInvalidCommitment       error
+
+	// abi decoder
+	ErrInvalidAbi  error
+	ErrAbiNotFound error
 )
 
 func init() {
@@ -22,4 +34,15 @@ func init() {
 
 	ErrClientConnectionFailure = errors.New("failed to connect to node rpc endpoint")
 	ErrNotSupportedChain = errors.New("not supported chain")
+	ErrChainAlreadyExisted = errors.New("chain already existed")
+	ErrInvalidChain = errors.New("invalid chain")
+	ErrNotSupportedMethod = errors.New("not supported method")
+	ErrUnexpectedChainID = errors.New("unexpected chain id")
+	ErrNotSupportedOperation = errors.New("not supported operation")
+	ErrInvalidBlockRange = errors.New("invalid block range")
+	ErrStreamClosed = errors.New("stream closed")
+	ErrInvalidCommitment = errors.New("invalid commitment")
+
+	ErrInvalidAbi = errors.New("invalid abi")
+	ErrAbiNotFound = errors.New("abi not found")
 }
@@ -0,0 +1,14 @@
+package encoder
+
+import (
+	"encoding/hex"
+)
+
+// IsHex checks the hex string without 0x prefix
+func IsHex(str string) bool {
+	if len(str)%2 != 0 {
+		return false
+	}
+	_, err := hex.DecodeString(str)
+	return err == nil
+}